@@ -5,11 +5,13 @@ package cli
 import (
 	"errors"
 	"fmt"
+	"os"
 	"runtime"
 	"strconv"
 	"strings"
 	"time"
 
+	"dns.froth.zone/awl/pkg/query"
 	"dns.froth.zone/awl/pkg/util"
 	"github.com/miekg/dns"
 	flag "github.com/stefansundin/go-zflag"
@@ -36,14 +38,16 @@ func ParseCLI(args []string, version string) (opts *util.Options, err error) {
 
 	// Special options and exceptions time
 
-	if opts.Request.Port == 0 {
-		if opts.TLS || opts.QUIC {
-			opts.Request.Port = 853
-		} else {
-			opts.Request.Port = 53
-		}
+	if opts.Compare && len(opts.Servers) < 2 {
+		return opts, fmt.Errorf("compare: %w", errTooFewServers)
+	}
+
+	for i, srv := range opts.Servers {
+		opts.Servers[i].Port = defaultPort(opts.Request.Port, srv.TLS || srv.QUIC)
 	}
 
+	opts.Request.Port = defaultPort(opts.Request.Port, opts.TLS || opts.QUIC)
+
 	opts.Logger.Info("Port set to", opts.Request.Port)
 
 	// Set timeout to 0.5 seconds if set below 0.5
@@ -55,6 +59,23 @@ func ParseCLI(args []string, version string) (opts *util.Options, err error) {
 		opts.Request.Retries = 0
 	}
 
+	if opts.Format != "" {
+		f, err := query.LoadFormatter(opts)
+		if err != nil {
+			return opts, fmt.Errorf("format: %w", err)
+		}
+
+		if query.OnlyResponses(f) && printsReport(opts) {
+			return opts, fmt.Errorf("format: %s: %w", opts.Format, query.ErrOnlyResponses)
+		}
+	}
+
+	if opts.FromJSON != "" {
+		if err = fromJSON(opts); err != nil {
+			return opts, err
+		}
+	}
+
 	if opts.Trace {
 		if opts.TLS || opts.HTTPS || opts.QUIC {
 			opts.Logger.Warn("Every query after the root query will only use UDP/TCP")
@@ -63,6 +84,29 @@ func ParseCLI(args []string, version string) (opts *util.Options, err error) {
 		opts.RD = true
 	}
 
+	if opts.WalkDict != "" {
+		opts.Walk = true
+	}
+
+	if opts.KeyFile != "" {
+		opts.KeyInfo = true
+	}
+
+	if opts.KeyInfo && opts.Request.Type != dns.TypeCDNSKEY {
+		opts.Request.Type = dns.TypeDNSKEY
+	}
+
+	if opts.Sigchase {
+		opts.Validate = true
+	}
+
+	if opts.Validate {
+		// Get the records even if the resolver thinks they are bogus
+		opts.EDNS.EnableEDNS = true
+		opts.EDNS.DNSSEC = true
+		opts.CD = true
+	}
+
 	opts.Logger.Info("Options fully populated")
 	opts.Logger.Debug(fmt.Sprintf("%+v", opts))
 
@@ -101,6 +145,23 @@ func parseFlags(args []string, version string) (opts *util.Options, flags []stri
 		reverse = flagSet.Bool("reverse", false, "do a reverse lookup", flag.OptShorthand('x'))
 		trace   = flagSet.Bool("trace", false, "trace from the root")
 
+		validate    = flagSet.Bool("validate", false, "validate the response with DNSSEC")
+		sigchase    = flagSet.Bool("sigchase", false, "validate and show the DNSSEC chain of trust")
+		trustAnchor = flagSet.String("trust-anchor", "", "`file` to read DNSSEC trust anchors from (default: built-in root anchors)")
+
+		checkExpiry    = flagSet.Bool("check-expiry", false, "check when the DNSSEC signatures of the zone expire on every authoritative server")
+		expiryWarning  = flagSet.Duration("expiry-warning", 7*24*time.Hour, "warn when a signature expires within `duration`")
+		expiryCritical = flagSet.Duration("expiry-critical", 3*24*time.Hour, "go critical when a signature expires within `duration`")
+
+		keyInfo = flagSet.Bool("key-info", false, "describe DNSKEY records and compute their DS records")
+		keyFile = flagSet.String("key-file", "", "zone `file` to read DNSKEY records from instead of querying, - for stdin")
+
+		nssearch   = flagSet.Bool("nssearch", false, "query every authoritative server of the zone, checking that they agree")
+		delegation = flagSet.Bool("delegation", false, "check that the NS records, glue and DS records of the parent match the zone")
+
+		walk     = flagSet.Bool("walk", false, "walk the NSEC or NSEC3 chain of the zone")
+		walkDict = flagSet.String("walk-dict", "", "dictionary `file` of labels to try against NSEC3 hashes")
+
 		timeout = flagSet.Float32("timeout", 5, "Timeout, in `seconds`")
 		retry   = flagSet.Int("retries", 2, "number of `times` to retry")
 
@@ -142,6 +203,22 @@ func parseFlags(args []string, version string) (opts *util.Options, flags []stri
 		xml   = flagSet.Bool("xml", false, "print the result(s) as XML", flag.OptShorthand('X'))
 		yaml  = flagSet.Bool("yaml", false, "print the result(s) as yaml", flag.OptShorthand('y'))
 
+		format       = flagSet.String("format", "", "output `format`: json, xml, yaml, rfc8427, table, csv, tsv, ndjson, template or zone")
+		tmpl         = flagSet.String("template", "", "Go `template` to print the result(s) with")
+		tmplFile     = flagSet.String("template-file", "", "`file` to read a Go template from")
+		wire         = flagSet.Bool("wire", false, "print a hex dump of the query and response")
+		annotate     = flagSet.Bool("annotate", false, "annotate every field of the hex dump, implies --wire")
+		color        = flagSet.String("color", "auto", "colour the output: `when` auto, always or never", flag.OptNoOptDefVal("always"))
+		keylog       = flagSet.String("keylog", "", "append TLS session keys to `file` (default: $SSLKEYLOGFILE)")
+		pcapFile     = flagSet.String("pcap", "", "write every query and response to a pcap `file`")
+		dnstap       = flagSet.String("dnstap", "", "write every query and response as dnstap to a `file` or unix socket")
+		decode       = flagSet.String("decode", "", "decode and print a `message` in hex or base64, a DoH URL, or a file, - for stdin")
+		compare      = flagSet.Bool("compare", false, "send the query to every @server given, comparing the responses")
+		replayFile   = flagSet.String("replay", "", "replay the queries in a pcap or dnstap `file`, comparing the responses")
+		replayRate   = flagSet.Float64("replay-rate", 0, "replay at most `qps` queries a second")
+		replayTiming = flagSet.Bool("replay-timing", false, "replay queries at the times they were recorded at")
+		fromJSON     = flagSet.String("from-json", "", "RFC 8427 JSON `file` to take the question and flags from, - for stdin")
+
 		noC     = flagSet.Bool("no-comments", false, "disable printing the comments")
 		noQ     = flagSet.Bool("no-question", false, "disable printing the question section")
 		noOpt   = flagSet.Bool("no-opt", false, "disable printing the OPT pseudosection")
@@ -173,6 +250,9 @@ func parseFlags(args []string, version string) (opts *util.Options, flags []stri
 		IPv4:        *ipv4,
 		IPv6:        *ipv6,
 		Trace:       *trace,
+		Validate:    *validate,
+		Sigchase:    *sigchase,
+		TrustAnchor: *trustAnchor,
 		Short:       *short,
 		TCP:         *tcp,
 		DNSCrypt:    *dnscrypt,
@@ -184,9 +264,27 @@ func parseFlags(args []string, version string) (opts *util.Options, flags []stri
 		Truncate:    *truncate,
 		BadCookie:   *badCookie,
 		Reverse:     *reverse,
-		JSON:        *json,
-		XML:         *xml,
-		YAML:        *yaml,
+
+		CheckExpiry:    *checkExpiry,
+		ExpiryWarning:  *expiryWarning,
+		ExpiryCritical: *expiryCritical,
+		KeyInfo:        *keyInfo,
+		KeyFile:        *keyFile,
+		NSSearch:       *nssearch,
+		Delegation:     *delegation,
+		Walk:           *walk,
+		WalkDict:       *walkDict,
+		Format:         strings.ToLower(*format),
+		Template:       *tmpl,
+		FromJSON:       *fromJSON,
+		Decode:         *decode,
+		Compare:        *compare,
+		Replay:         *replayFile,
+		ReplayRate:     *replayRate,
+		ReplayTiming:   *replayTiming,
+		Pcap:           *pcapFile,
+		Dnstap:         *dnstap,
+		KeyLog:         *keylog,
 		HeaderFlags: util.HeaderFlags{
 			AA: *aaflag,
 			AD: *adflag,
@@ -218,6 +316,8 @@ func parseFlags(args []string, version string) (opts *util.Options, flags []stri
 			ShowQuery:      false,
 			HumanTTL:       false,
 			UcodeTranslate: true,
+			Wire:           *wire,
+			Annotate:       *annotate,
 		},
 		EDNS: util.EDNS{
 			EnableEDNS: !*edns,
@@ -237,6 +337,43 @@ func parseFlags(args []string, version string) (opts *util.Options, flags []stri
 		},
 	}
 
+	if opts.Display.Color, err = useColor(*color); err != nil {
+		return opts, nil, err
+	}
+
+	if opts.ReplayRate < 0 {
+		return opts, nil, fmt.Errorf("replay-rate: %w: %v", errInvalidRate, opts.ReplayRate)
+	}
+
+	if opts.KeyLog == "" {
+		opts.KeyLog = os.Getenv("SSLKEYLOGFILE")
+	}
+
+	if *tmplFile != "" {
+		text, err := os.ReadFile(*tmplFile)
+		if err != nil {
+			return opts, nil, fmt.Errorf("template: %w", err)
+		}
+
+		opts.Template = string(text)
+	}
+
+	if opts.Template != "" {
+		opts.Format = "template"
+	}
+
+	// -j, -X and -y are shorthands for --format
+	if opts.Format == "" {
+		switch {
+		case *json:
+			opts.Format = "json"
+		case *xml:
+			opts.Format = "xml"
+		case *yaml:
+			opts.Format = "yaml"
+		}
+	}
+
 	// TODO: DRY
 	if *subnet != "" {
 		if err = util.ParseSubnet(*subnet, opts); err != nil {
@@ -258,7 +395,92 @@ func parseFlags(args []string, version string) (opts *util.Options, flags []stri
 	return
 }
 
-var errNoArg = errors.New("no argument given")
+// fromJSON takes the question and flags of the query from an RFC 8427 message.
+func fromJSON(opts *util.Options) error {
+	msg, err := query.LoadRFC8427(opts.FromJSON)
+	if err != nil {
+		return fmt.Errorf("from-json: %w", err)
+	}
+
+	if len(msg.Question) == 0 {
+		return fmt.Errorf("from-json: %w", errNoQuestion)
+	}
+
+	opts.Request.Name = msg.Question[0].Name
+	opts.Request.Type = msg.Question[0].Qtype
+	opts.Request.Class = msg.Question[0].Qclass
+	opts.Request.ID = msg.Id
+	opts.Request.FixedID = true
+
+	opts.RD = msg.RecursionDesired
+	opts.CD = msg.CheckingDisabled
+	opts.AD = msg.AuthenticatedData
+
+	opt := msg.IsEdns0()
+	opts.EDNS.EnableEDNS = opt != nil
+
+	if opt != nil {
+		opts.EDNS.BufSize = opt.UDPSize()
+		opts.EDNS.DNSSEC = opt.Do()
+		opts.EDNS.Version = opt.Version()
+	}
+
+	opts.Logger.Info("Replaying", opts.Request.Name, dns.TypeToString[opts.Request.Type], "from", opts.FromJSON)
+
+	return nil
+}
+
+// defaultPort gives the port of the transport, unless one was given.
+func defaultPort(port int, tls bool) int {
+	switch {
+	case port != 0:
+		return port
+	case tls:
+		return 853
+	default:
+		return 53
+	}
+}
+
+// useColor decides whether to colour the output.
+//
+// auto colours it when stdout is a terminal, unless NO_COLOR is set.
+func useColor(when string) (bool, error) {
+	switch strings.ToLower(when) {
+	case "always", "yes", "force":
+		return true, nil
+	case "never", "no", "none":
+		return false, nil
+	case "auto", "tty", "if-tty":
+		if os.Getenv("NO_COLOR") != "" {
+			return false, nil
+		}
+
+		stat, err := os.Stdout.Stat()
+		if err != nil {
+			return false, nil //nolint:nilerr // Not a terminal then
+		}
+
+		return stat.Mode()&os.ModeCharDevice != 0, nil
+	default:
+		return false, fmt.Errorf("color: %w %q, use auto, always or never", errInvalidColor, when)
+	}
+}
+
+// printsReport reports whether the options select a mode that prints something
+// other than a DNS response, eg. a trace or a zone walk.
+func printsReport(opts *util.Options) bool {
+	return opts.Trace || opts.CheckExpiry || opts.KeyInfo || opts.Walk || opts.NSSearch ||
+		opts.Delegation || opts.Replay != "" || opts.Compare
+}
+
+var (
+	errNoArg         = errors.New("no argument given")
+	errNoQuestion    = errors.New("no question in message")
+	errInvalidColor  = errors.New("invalid value")
+	errInvalidRate   = errors.New("rate can't be negative")
+	errTooFewServers = errors.New("at least two servers must be given with @")
+)
 
 type errInvalidArg struct {
 	arg string
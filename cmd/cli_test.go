@@ -3,11 +3,15 @@
 package cli_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	cli "dns.froth.zone/awl/cmd"
+	"dns.froth.zone/awl/pkg/query"
 	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
 	"gotest.tools/v3/assert"
 )
 
@@ -172,6 +176,308 @@ func TestSetHTTPS(t *testing.T) {
 	}
 }
 
+func TestQID(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"awl", "+qid=0x1234", "+cookie=24a5ac5a4c2de4e4"}
+
+	opt, err := cli.ParseCLI(args, "TEST")
+
+	assert.NilError(t, err)
+	assert.Assert(t, opt.Request.FixedID)
+	assert.Equal(t, opt.Request.ID, uint16(0x1234))
+	assert.Equal(t, opt.EDNS.CookieValue, "24a5ac5a4c2de4e4")
+
+	for _, args := range [][]string{
+		{"awl", "+cookie=24a5ac5a4c2de4e4", "+nocookie"},
+		{"awl", "+nocookie=24a5ac5a4c2de4e4"},
+	} {
+		opt, err = cli.ParseCLI(args, "TEST")
+
+		assert.NilError(t, err)
+		assert.Assert(t, !opt.EDNS.Cookie)
+		assert.Equal(t, opt.EDNS.CookieValue, "")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"awl", "+validate", "--trust-anchor", "root.key"}
+
+	opt, err := cli.ParseCLI(args, "TEST")
+
+	assert.NilError(t, err)
+	assert.Assert(t, opt.Validate)
+	assert.Assert(t, opt.EDNS.DNSSEC)
+	assert.Assert(t, opt.CD)
+	assert.Equal(t, opt.TrustAnchor, "root.key")
+}
+
+func TestSigchase(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"awl", "+sigchase"}
+
+	opt, err := cli.ParseCLI(args, "TEST")
+
+	assert.NilError(t, err)
+	assert.Assert(t, opt.Sigchase)
+	assert.Assert(t, opt.Validate)
+	assert.Assert(t, opt.EDNS.DNSSEC)
+}
+
+func TestCheckExpiry(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"awl", "--check-expiry", "--expiry-warning", "240h", "example.com"}
+
+	opt, err := cli.ParseCLI(args, "TEST")
+
+	assert.NilError(t, err)
+	assert.Assert(t, opt.CheckExpiry)
+	assert.Equal(t, opt.ExpiryWarning, 240*time.Hour)
+	assert.Equal(t, opt.ExpiryCritical, 72*time.Hour)
+}
+
+func TestNSSearch(t *testing.T) {
+	t.Parallel()
+
+	opt, err := cli.ParseCLI([]string{"awl", "+nssearch", "example.com"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Assert(t, opt.NSSearch)
+
+	opt, err = cli.ParseCLI([]string{"awl", "--nssearch", "example.com", "+nonssearch"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Assert(t, !opt.NSSearch)
+}
+
+func TestDelegation(t *testing.T) {
+	t.Parallel()
+
+	opt, err := cli.ParseCLI([]string{"awl", "--delegation", "example.com"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Assert(t, opt.Delegation)
+	assert.Equal(t, opt.Request.Name, "example.com.")
+}
+
+func TestWalk(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"awl", "--walk-dict", "words.txt", "example.com"}
+
+	opt, err := cli.ParseCLI(args, "TEST")
+
+	assert.NilError(t, err)
+	assert.Assert(t, opt.Walk)
+	assert.Equal(t, opt.WalkDict, "words.txt")
+}
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+
+	opt, err := cli.ParseCLI([]string{"awl", "--format", "RFC8427", "example.com"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Equal(t, opt.Format, "rfc8427")
+
+	opt, err = cli.ParseCLI([]string{"awl", "--format", "yaml", "example.com"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Equal(t, opt.Format, "yaml")
+
+	opt, err = cli.ParseCLI([]string{"awl", "-j", "example.com"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Equal(t, opt.Format, "json")
+
+	opt, err = cli.ParseCLI([]string{"awl", "--format", "ndjson", "+noxml", "example.com"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Equal(t, opt.Format, "ndjson")
+
+	opt, err = cli.ParseCLI([]string{"awl", "-X", "+noxml", "example.com"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Equal(t, opt.Format, "")
+
+	_, err = cli.ParseCLI([]string{"awl", "--format", "toml", "example.com"}, "TEST")
+
+	assert.ErrorContains(t, err, "unknown format \"toml\"")
+}
+
+func TestReportFormat(t *testing.T) {
+	t.Parallel()
+
+	for _, args := range [][]string{
+		{"--format", "csv", "+trace"},
+		{"--format", "table", "--walk"},
+		{"--format", "zone", "--nssearch"},
+		{"--format", "tsv", "--compare", "@1.1.1.1", "@8.8.8.8"},
+	} {
+		_, err := cli.ParseCLI(append(append([]string{"awl"}, args...), "example.com"), "TEST")
+		assert.ErrorIs(t, err, query.ErrOnlyResponses, args)
+	}
+
+	opt, err := cli.ParseCLI([]string{"awl", "--format", "ndjson", "+trace", "example.com"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Equal(t, opt.Format, "ndjson")
+}
+
+func TestTemplate(t *testing.T) {
+	t.Parallel()
+
+	opt, err := cli.ParseCLI([]string{"awl", "-j", "--template", "{{.Status}}", "example.com"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Equal(t, opt.Format, "template")
+	assert.Equal(t, opt.Template, "{{.Status}}")
+
+	file := filepath.Join(t.TempDir(), "answers.tmpl")
+	assert.NilError(t, os.WriteFile(file, []byte("{{range .AnswerRRs}}{{.Value}}\n{{end}}"), 0o600))
+
+	opt, err = cli.ParseCLI([]string{"awl", "--template-file", file, "example.com"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Equal(t, opt.Format, "template")
+	assert.Equal(t, opt.Template, "{{range .AnswerRRs}}{{.Value}}\n{{end}}")
+
+	_, err = cli.ParseCLI([]string{"awl", "--template-file", filepath.Join(t.TempDir(), "missing"), "example.com"}, "TEST")
+
+	assert.ErrorContains(t, err, "template")
+}
+
+func TestColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	opt, err := cli.ParseCLI([]string{"awl", "example.com"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Equal(t, opt.Display.Color, false)
+
+	opt, err = cli.ParseCLI([]string{"awl", "--color", "example.com"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Equal(t, opt.Display.Color, true)
+
+	opt, err = cli.ParseCLI([]string{"awl", "--color=always", "+nocolor", "example.com"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Equal(t, opt.Display.Color, false)
+
+	opt, err = cli.ParseCLI([]string{"awl", "--color=never", "+color", "example.com"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Equal(t, opt.Display.Color, true)
+
+	_, err = cli.ParseCLI([]string{"awl", "--color=sometimes", "example.com"}, "TEST")
+
+	assert.ErrorContains(t, err, "color")
+}
+
+func TestWire(t *testing.T) {
+	t.Parallel()
+
+	opt, err := cli.ParseCLI([]string{"awl", "--wire", "example.com"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Equal(t, opt.Display.Wire, true)
+	assert.Equal(t, opt.Display.Annotate, false)
+
+	opt, err = cli.ParseCLI([]string{"awl", "+hex", "+annotate", "example.com"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Equal(t, opt.Display.Wire, true)
+	assert.Equal(t, opt.Display.Annotate, true)
+}
+
+func TestPcap(t *testing.T) {
+	t.Parallel()
+
+	opt, err := cli.ParseCLI([]string{"awl", "--pcap", "out.pcap", "example.com"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Equal(t, opt.Pcap, "out.pcap")
+}
+
+func TestDnstap(t *testing.T) {
+	t.Parallel()
+
+	opt, err := cli.ParseCLI([]string{"awl", "--dnstap", "unix:/run/dnstap.sock", "example.com"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Equal(t, opt.Dnstap, "unix:/run/dnstap.sock")
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	opt, err := cli.ParseCLI([]string{"awl", "--compare", "@1.1.1.1", "@tls://dns.google", "example.com"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Assert(t, opt.Compare)
+	assert.Equal(t, opt.Servers[0].Port, 53)
+	assert.Equal(t, opt.Servers[1].Port, 853)
+
+	_, err = cli.ParseCLI([]string{"awl", "--compare", "@1.1.1.1", "example.com"}, "TEST")
+	assert.ErrorContains(t, err, "two servers")
+}
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+
+	opt, err := cli.ParseCLI([]string{"awl", "--replay", "in.pcap", "--replay-rate", "2.5", "--replay-timing", "@1.1.1.1"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Equal(t, opt.Replay, "in.pcap")
+	assert.Equal(t, opt.ReplayRate, 2.5)
+	assert.Assert(t, opt.ReplayTiming)
+
+	_, err = cli.ParseCLI([]string{"awl", "--replay", "in.pcap", "--replay-rate", "-1"}, "TEST")
+	assert.ErrorContains(t, err, "negative")
+}
+
+func TestKeyLog(t *testing.T) {
+	t.Setenv("SSLKEYLOGFILE", "env.keys")
+
+	opt, err := cli.ParseCLI([]string{"awl", "example.com"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Equal(t, opt.KeyLog, "env.keys")
+
+	opt, err = cli.ParseCLI([]string{"awl", "--keylog", "flag.keys", "example.com"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Equal(t, opt.KeyLog, "flag.keys")
+}
+
+func TestFromJSON(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "query.json")
+	err := os.WriteFile(file, []byte(`{"ID": 1234, "RD": false, "CD": true, "QNAME": "example.com", "QTYPE": 28, "QCLASS": 1}`), 0o600)
+	assert.NilError(t, err)
+
+	opt, err := cli.ParseCLI([]string{"awl", "--from-json", file, "@1.1.1.1"}, "TEST")
+
+	assert.NilError(t, err)
+	assert.Equal(t, opt.Request.Name, "example.com.")
+	assert.Equal(t, opt.Request.Type, dns.TypeAAAA)
+	assert.Equal(t, opt.Request.ID, uint16(1234))
+	assert.Assert(t, opt.Request.FixedID)
+	assert.Assert(t, !opt.RD)
+	assert.Assert(t, opt.CD)
+	assert.Assert(t, !opt.EDNS.EnableEDNS)
+
+	_, err = cli.ParseCLI([]string{"awl", "--from-json", filepath.Join(t.TempDir(), "missing.json")}, "TEST")
+
+	assert.ErrorContains(t, err, "from-json")
+}
+
 func FuzzFlags(f *testing.F) {
 	testcases := []string{"git.froth.zone", "", "!12345", "google.com.edu.org.fr"}
 
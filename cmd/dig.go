@@ -64,14 +64,33 @@ func ParseDig(arg string, opts *util.Options) error {
 		opts.Display.TTL = isNo
 	case "class":
 		opts.Display.ShowClass = isNo
+	case "rrcomments":
+		opts.Display.RRComments = isNo
+	case "multiline":
+		// As with dig, multiline output comes with comments
+		opts.Display.Multiline = isNo
+		opts.Display.RRComments = isNo
+	case "color", "colour":
+		opts.Display.Color = isNo
+	case "hex", "wire":
+		opts.Display.Wire = isNo
+	case "annotate":
+		opts.Display.Annotate = isNo
 
 	// EDNS queries
 	case "do", "dnssec":
 		opts.EDNS.DNSSEC = isNo
+	case "validate":
+		opts.Validate = isNo
+	case "sigchase":
+		opts.Sigchase = isNo
+	case "nssearch":
+		opts.NSSearch = isNo
 	case "expire":
 		opts.EDNS.Expire = isNo
 	case "cookie":
 		opts.EDNS.Cookie = isNo
+		opts.EDNS.CookieValue = ""
 	case "keepopen", "keepalive":
 		opts.EDNS.KeepOpen = isNo
 	case "nsid":
@@ -100,12 +119,8 @@ func ParseDig(arg string, opts *util.Options) error {
 		opts.Short = isNo
 	case "identify":
 		opts.Identify = isNo
-	case "json":
-		opts.JSON = isNo
-	case "xml":
-		opts.XML = isNo
-	case "yaml":
-		opts.YAML = isNo
+	case "json", "xml", "yaml":
+		setFormat(opts, arg, isNo)
 	// End formatting
 
 	// Output
@@ -143,6 +158,16 @@ func ParseDig(arg string, opts *util.Options) error {
 	return nil
 }
 
+// setFormat sets or unsets an output format, eg. for +json and +nojson.
+func setFormat(opts *util.Options, format string, set bool) {
+	switch {
+	case set:
+		opts.Format = format
+	case opts.Format == format:
+		opts.Format = ""
+	}
+}
+
 // For flags that contain "=".
 func parseDigEq(startNo bool, arg string, opts *util.Options) error {
 	// Recursive switch statements WOO
@@ -228,6 +253,31 @@ func parseDigEq(startNo bool, arg string, opts *util.Options) error {
 			opts.HTTPSOptions.Get = true
 		}
 
+	case "cookie":
+		opts.EDNS.Cookie = startNo
+		opts.EDNS.CookieValue = ""
+
+		// The value of +nocookie=... is ignored
+		if startNo && isSplit && val != "" {
+			err := util.ParseCookie(val, opts)
+			if err != nil {
+				return fmt.Errorf("digflags: EDNS Cookie: %w", err)
+			}
+		}
+
+	case "qid":
+		if isSplit && val != "" {
+			id, err := strconv.ParseUint(val, 0, 16)
+			if err != nil {
+				return fmt.Errorf("digflags: query ID: %w", err)
+			}
+
+			opts.Request.ID = uint16(id)
+			opts.Request.FixedID = startNo
+		} else {
+			return fmt.Errorf("digflags: query ID: %w", errNoArg)
+		}
+
 	case "subnet":
 		if isSplit && val != "" {
 			err := util.ParseSubnet(val, opts)
@@ -30,7 +30,8 @@ func FuzzDig(f *testing.F) {
 		"expire", "noexpire",
 		"ednsflags", "ednsflags=\"", "ednsflags=1", "noednsflags",
 		"subnet=0.0.0.0/0", "subnet=::0/0", "subnet=b", "subnet=0", "subnet",
-		"cookie", "nocookie",
+		"cookie", "nocookie", "cookie=24a5ac5a4c2de4e4", "cookie=abc", "cookie=zz",
+		"qid=1234", "qid=0x10", "qid=a", "qid",
 		"keepopen", "keepalive", "nokeepopen", "nokeepalive",
 		"nsid", "nonsid",
 		"padding", "nopadding",
@@ -60,7 +61,15 @@ func FuzzDig(f *testing.F) {
 		"all", "noall",
 		"idnout", "noidnout",
 		"class", "noclass",
+		"rrcomments", "norrcomments",
+		"multiline", "nomultiline",
+		"color", "nocolor",
+		"hex", "nohex",
+		"annotate", "noannotate",
 		"trace", "notrace",
+		"validate", "novalidate",
+		"sigchase", "nosigchase",
+		"nssearch", "nonssearch",
 		"invalid",
 	}
 
@@ -16,6 +16,17 @@ import (
 // ParseMiscArgs parses the wildcard arguments, dig style.
 // Only one command is supported at a time, so any extra information overrides previous.
 func ParseMiscArgs(args []string, opts *util.Options) error {
+	var servers []string
+
+	// A single server is set where it is given, several are set at the end
+	count := 0
+
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "@") {
+			count++
+		}
+	}
+
 	for _, arg := range args {
 		r, ok := dns.StringToType[strings.ToUpper(arg)]
 
@@ -23,50 +34,13 @@ func ParseMiscArgs(args []string, opts *util.Options) error {
 		// If it starts with @, it's a DNS server
 		case strings.HasPrefix(arg, "@"):
 			arg = arg[1:]
-			// Automatically set flags based on URI header
 			opts.Logger.Info(arg, "detected as a server")
 
-			switch {
-			case strings.HasPrefix(arg, "tls://"):
-				opts.TLS = true
-				opts.Request.Server = strings.TrimPrefix(arg, "tls://")
-				opts.Logger.Info("DNS-over-TLS implicitly set")
-			case strings.HasPrefix(arg, "https://"):
-				opts.HTTPS = true
-				opts.Request.Server = arg
-				opts.Logger.Info("DNS-over-HTTPS implicitly set")
-
-				_, endpoint, isSplit := strings.Cut(arg, "/")
-				if isSplit {
-					opts.HTTPSOptions.Endpoint = "/" + endpoint
-				}
-			case strings.HasPrefix(arg, "quic://"):
-				opts.QUIC = true
-				opts.Request.Server = strings.TrimPrefix(arg, "quic://")
-				opts.Logger.Info("DNS-over-QUIC implicitly set.")
-			case strings.HasPrefix(arg, "sdns://"):
-				opts.DNSCrypt = true
-				opts.Request.Server = arg
-				opts.Logger.Info("DNSCrypt implicitly set")
-			case strings.HasPrefix(arg, "tcp://"):
-				opts.TCP = true
-				opts.Request.Server = strings.TrimPrefix(arg, "tcp://")
-				opts.Logger.Info("TCP implicitly set")
-			case strings.HasPrefix(arg, "udp://"):
-				opts.Request.Server = strings.TrimPrefix(arg, "udp://")
-			default:
-				// Allow HTTPS queries to have a fallback default
-				if opts.HTTPS {
-					server, endpoint, isSplit := strings.Cut(arg, "/")
-					if isSplit {
-						opts.HTTPSOptions.Endpoint = "/" + endpoint
-						opts.Request.Server = server
-					} else {
-						opts.Request.Server = server
-					}
-				} else {
-					opts.Request.Server = arg
-				}
+			servers = append(servers, arg)
+
+			// Automatically set flags based on URI header
+			if count == 1 {
+				ParseServer(arg, opts)
 			}
 
 		// Dig-style +queries
@@ -104,6 +78,29 @@ func ParseMiscArgs(args []string, opts *util.Options) error {
 		}
 	}
 
+	// Several servers, as given to --compare, are parsed last, so that the
+	// transport of one isn't used for the others
+	if count > 1 {
+		for _, arg := range servers {
+			srv := *opts
+			ParseServer(arg, &srv)
+
+			opts.Servers = append(opts.Servers, util.Server{
+				Name:     arg,
+				Address:  srv.Request.Server,
+				Endpoint: srv.HTTPSOptions.Endpoint,
+				TCP:      srv.TCP,
+				TLS:      srv.TLS,
+				HTTPS:    srv.HTTPS,
+				QUIC:     srv.QUIC,
+				DNSCrypt: srv.DNSCrypt,
+			})
+		}
+
+		// Only the last server is queried
+		ParseServer(servers[len(servers)-1], opts)
+	}
+
 	// If nothing was set, set a default
 	if opts.Request.Name == "" {
 		opts.Logger.Info("Domain not specified, making a default")
@@ -194,3 +191,51 @@ func ParseMiscArgs(args []string, opts *util.Options) error {
 
 	return nil
 }
+
+// ParseServer sets the server to query, given as with @ but without it.
+// A scheme, eg. tls://, sets the transport as well.
+func ParseServer(arg string, opts *util.Options) {
+	switch {
+	case strings.HasPrefix(arg, "tls://"):
+		opts.TLS = true
+		opts.Request.Server = strings.TrimPrefix(arg, "tls://")
+		opts.Logger.Info("DNS-over-TLS implicitly set")
+	case strings.HasPrefix(arg, "https://"):
+		opts.HTTPS = true
+		opts.Request.Server = arg
+		opts.Logger.Info("DNS-over-HTTPS implicitly set")
+
+		// The path is the endpoint, or the default one if there isn't any
+		_, endpoint, isSplit := strings.Cut(strings.TrimPrefix(arg, "https://"), "/")
+		if isSplit {
+			opts.HTTPSOptions.Endpoint = "/" + endpoint
+		}
+	case strings.HasPrefix(arg, "quic://"):
+		opts.QUIC = true
+		opts.Request.Server = strings.TrimPrefix(arg, "quic://")
+		opts.Logger.Info("DNS-over-QUIC implicitly set.")
+	case strings.HasPrefix(arg, "sdns://"):
+		opts.DNSCrypt = true
+		opts.Request.Server = arg
+		opts.Logger.Info("DNSCrypt implicitly set")
+	case strings.HasPrefix(arg, "tcp://"):
+		opts.TCP = true
+		opts.Request.Server = strings.TrimPrefix(arg, "tcp://")
+		opts.Logger.Info("TCP implicitly set")
+	case strings.HasPrefix(arg, "udp://"):
+		opts.Request.Server = strings.TrimPrefix(arg, "udp://")
+	default:
+		// Allow HTTPS queries to have a fallback default
+		if opts.HTTPS {
+			server, endpoint, isSplit := strings.Cut(arg, "/")
+			if isSplit {
+				opts.HTTPSOptions.Endpoint = "/" + endpoint
+				opts.Request.Server = server
+			} else {
+				opts.Request.Server = server
+			}
+		} else {
+			opts.Request.Server = arg
+		}
+	}
+}
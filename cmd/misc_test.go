@@ -170,6 +170,27 @@ func TestFlagSetting(t *testing.T) {
 	}
 }
 
+func TestHTTPSEndpoint(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		server   string
+		endpoint string
+	}{
+		{"@https://dns.example.net/a", "/a"},
+		// Left for the default
+		{"@https://dns.quad9.net", "/dns-query"},
+	} {
+		opts := new(util.Options)
+		opts.Logger = util.InitLogger(0)
+		opts.HTTPSOptions.Endpoint = "/dns-query"
+
+		err := cli.ParseMiscArgs([]string{test.server}, opts)
+		assert.NilError(t, err)
+		assert.Equal(t, opts.HTTPSOptions.Endpoint, test.endpoint, test.server)
+	}
+}
+
 func FuzzParseArgs(f *testing.F) {
 	cases := []string{
 		"go.dev",
@@ -193,3 +214,26 @@ func FuzzParseArgs(f *testing.F) {
 		cli.ParseMiscArgs(args, opts)
 	})
 }
+
+func TestServers(t *testing.T) {
+	t.Parallel()
+
+	opts := new(util.Options)
+	opts.Logger = util.InitLogger(0)
+
+	err := cli.ParseMiscArgs([]string{"@1.1.1.1", "@https://dns.quad9.net", "@tls://dns.google", "example.com"}, opts)
+	assert.NilError(t, err)
+	assert.Equal(t, len(opts.Servers), 3)
+
+	// The transport of one server isn't used for the others
+	assert.Equal(t, opts.Servers[0].Address, "1.1.1.1")
+	assert.Assert(t, !opts.Servers[0].HTTPS && !opts.Servers[0].TLS)
+	assert.Assert(t, opts.Servers[1].HTTPS)
+	assert.Equal(t, opts.Servers[1].Address, "https://dns.quad9.net")
+	assert.Equal(t, opts.Servers[1].Endpoint, "")
+	assert.Assert(t, opts.Servers[2].TLS && !opts.Servers[2].HTTPS)
+
+	// The last one is queried
+	assert.Equal(t, opts.Request.Server, "dns.google")
+	assert.Assert(t, opts.TLS && !opts.HTTPS)
+}
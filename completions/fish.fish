@@ -30,6 +30,21 @@ complete -c awl -s Q -l quic -a '+quic +noquic'  -d 'Use DNS-over-QUIC'
 complete -c awl -s j -l json -a '+json +nojson' -d 'Print as JSON'
 complete -c awl -s j -l xml -a '+xml +noxml' -d 'Print as XML'
 complete -c awl -s j -l yaml -a '+yaml +noyaml' -d 'Print as YAML'
+complete -f -c awl -l format -x -a 'csv json ndjson rfc8427 table tsv xml yaml zone' -d 'Output format'
+complete -f -c awl -l template -x -d 'Print with a Go template'
+complete -c awl -l template-file -r -d 'Print with a Go template from a file'
+complete -f -c awl -l wire -a '+hex +nohex +wire +nowire' -d 'Print a hex dump of the query and response'
+complete -f -c awl -l annotate -a '+annotate +noannotate' -d 'Annotate every field of the hex dump'
+complete -f -c awl -l color -a 'auto always never' -d 'Colour the output'
+complete -c awl -l keylog -r -d 'Append TLS session keys to a file'
+complete -c awl -l dnstap -r -d 'Write queries and responses as dnstap to a file or socket'
+complete -c awl -l compare -d 'Compare the responses of every server given'
+complete -c awl -l replay -r -d 'Replay the queries in a pcap or dnstap file'
+complete -c awl -l replay-rate -x -d 'Queries a second to replay at'
+complete -c awl -l replay-timing -d 'Replay queries at the times they were recorded at'
+complete -c awl -l pcap -r -d 'Write queries and responses to a pcap file'
+complete -c awl -l decode -r -d 'Decode a DNS message instead of querying'
+complete -c awl -l from-json -r -d 'Take the query from an RFC 8427 JSON file'
 
 complete -c awl -s x -l reverse -x -d 'Reverse lookup'
 complete -f -c awl -s h -l help -d 'Print help and exit'
@@ -48,6 +63,7 @@ complete -f -c awl -a '+cdflag +nocdflag' -d 'Set CD flag in query'
 complete -f -c awl -a '+cl +nocl' -d 'Control display of class in records'
 # complete -f -c awl -a '+cmd +nocmd' -d 'Control display of command line'
 complete -f -c awl -a '+comments +nocomments' -d 'Control display of comment lines'
+complete -f -c awl -a '+rrcomments +norrcomments' -d 'Control display of per-record comments'
 complete -f -c awl -a '+question +noquestion' -d 'Control display of question'
 complete -f -c awl -a '+answer +noanswer' -d 'Control display of answer'
 complete -f -c awl -a '+authority +noauthority' -d 'Control display of authority'
@@ -57,12 +73,24 @@ complete -f -c awl -s s -l short -a '+short +noshort' -d 'Disable everything exc
 complete -f -c awl -a '+ttlid +nottlid' -d 'Control display of ttls in records'
 complete -f -c awl -a '+all +noall' -d 'Set or clear all display flags'
 complete -f -c awl -a '+qr +noqr' -d 'Print question before sending'
-# complete -f -c awl -a '+nssearch +nonssearch' -d 'Search all authoritative nameservers'
+complete -f -c awl -l nssearch -a '+nssearch +nonssearch' -d 'Search all authoritative nameservers'
 complete -f -c awl -a '+identify +noidentify' -d 'ID responders in short answers'
 complete -f -c awl -a '+trace +notrace' -d 'Trace delegation down from root'
 complete -f -c awl -l dnssec -a '+dnssec +nodnssec +do +nodo' -d 'Request DNSSEC records'
+complete -f -c awl -l validate -a '+validate +novalidate' -d 'Validate the response with DNSSEC'
+complete -f -c awl -l sigchase -a '+sigchase +nosigchase' -d 'Show the DNSSEC chain of trust'
+complete -c awl -l trust-anchor -r -d 'Read DNSSEC trust anchors from file'
+complete -f -c awl -l check-expiry -d 'Check when DNSSEC signatures expire'
+complete -f -c awl -l delegation -d 'Check the delegation of the zone from its parent'
+complete -f -c awl -l key-info -d 'Describe DNSKEY records and their DS records'
+complete -c awl -l key-file -r -d 'Read DNSKEY records from a zone file'
+complete -f -c awl -l walk -d 'Walk the NSEC or NSEC3 chain of the zone'
+complete -c awl -l walk-dict -r -d 'Try labels from a file against NSEC3 hashes'
+complete -f -c awl -l expiry-warning -x -d 'Warn when a signature expires within duration'
+complete -f -c awl -l expiry-critical -x -d 'Go critical when a signature expires within duration'
 complete -f -c awl -a '+nsid +nonsid' -d 'Request Name Server ID'
-# complete -f -c awl -a '+multiline +nomultiline' -d 'Print records in an expanded format'
+complete -f -c awl -a '+multiline +nomultiline' -d 'Print records in an expanded format'
+complete -f -c awl -a '+color +nocolor' -d 'Colour the output'
 # complete -f -c awl -a '+onesoa +noonesoa' -d 'AXFR prints only one soa record'
 
 complete -f -c awl -a '+tries=' -d 'Set number of UDP attempts'
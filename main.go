@@ -5,21 +5,29 @@ package main
 import (
 	"errors"
 	"fmt"
-	"math/rand"
 	"os"
 	"strings"
-	"time"
 
 	cli "dns.froth.zone/awl/cmd"
+	"dns.froth.zone/awl/pkg/delegation"
+	"dns.froth.zone/awl/pkg/dnskey"
+	"dns.froth.zone/awl/pkg/dnstap"
+	"dns.froth.zone/awl/pkg/expiry"
+	"dns.froth.zone/awl/pkg/nssearch"
+	"dns.froth.zone/awl/pkg/pcap"
 	"dns.froth.zone/awl/pkg/query"
+	"dns.froth.zone/awl/pkg/replay"
+	"dns.froth.zone/awl/pkg/trace"
 	"dns.froth.zone/awl/pkg/util"
+	"dns.froth.zone/awl/pkg/walk"
 	"github.com/miekg/dns"
 )
 
 var version = "DEV"
 
 func main() {
-	if opts, code, err := run(os.Args); err != nil {
+	opts, code, err := run(os.Args)
+	if err != nil {
 		// TODO: Make not ew
 		if errors.Is(err, util.ErrNotError) || strings.Contains(err.Error(), "help requested") {
 			os.Exit(0)
@@ -28,108 +36,411 @@ func main() {
 			os.Exit(code)
 		}
 	}
+
+	// Checks can fail without an error
+	os.Exit(code)
 }
 
 func run(args []string) (opts *util.Options, code int, err error) {
-	//nolint:gosec //Secure source not needed
-	r := rand.New(rand.NewSource(time.Now().Unix()))
-
 	opts, err = cli.ParseCLI(args, version)
 	if err != nil {
 		return opts, 1, fmt.Errorf("parse: %w", err)
 	}
 
-	var (
-		resp          util.Response
-		keepTracing   bool
-		tempDomain    string
-		tempQueryType uint16
-	)
+	if opts.Pcap != "" {
+		var capture *pcap.Writer
+
+		capture, err = pcap.Create(opts.Pcap)
+		if err != nil {
+			return opts, 1, err //nolint:wrapcheck // Already says what failed
+		}
+
+		defer func() {
+			if closeErr := capture.Close(); closeErr != nil && err == nil {
+				err = closeErr
+
+				code = max(code, 1)
+			}
+		}()
+
+		opts.Recorders = append(opts.Recorders, capture)
+	}
+
+	if opts.Dnstap != "" {
+		var tap *dnstap.Writer
+
+		tap, err = dnstap.Open(opts.Dnstap)
+		if err != nil {
+			return opts, 1, err //nolint:wrapcheck // Already says what failed
+		}
+
+		defer func() {
+			if closeErr := tap.Close(); closeErr != nil && err == nil {
+				err = closeErr
 
-	for ok := true; ok; ok = keepTracing {
-		if opts.Trace {
-			if keepTracing {
-				opts.Request.Name = tempDomain
-				opts.Request.Type = tempQueryType
-			} else {
-				tempDomain = opts.Request.Name
-				tempQueryType = opts.Request.Type
-
-				// Override the query because it needs to be done
-				opts.Request.Name = "."
-				opts.Request.Type = dns.TypeNS
+				code = max(code, 1)
 			}
+		}()
+
+		tap.Version = "awl " + version
+		opts.Recorders = append(opts.Recorders, tap)
+	}
+
+	if opts.KeyLog != "" {
+		var keylog *os.File
+
+		keylog, err = os.OpenFile(opts.KeyLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return opts, 1, fmt.Errorf("keylog: %w", err)
 		}
-		// Retry queries if a query fails
-		for i := 0; i <= opts.Request.Retries; i++ {
-			resp, err = query.CreateQuery(opts)
-			if err == nil {
-				keepTracing = opts.Trace && (!resp.DNS.Authoritative || (opts.Request.Name == "." && tempDomain != ".")) && resp.DNS.MsgHdr.Rcode == 0
 
-				break
-			} else if i != opts.Request.Retries {
-				opts.Logger.Warn("Retrying request, error:", err)
+		defer func() {
+			if closeErr := keylog.Close(); closeErr != nil && err == nil {
+				err = fmt.Errorf("keylog: %w", closeErr)
+
+				code = max(code, 1)
 			}
+		}()
+
+		opts.Logger.Info("Writing TLS session keys to", opts.KeyLog)
+
+		opts.KeyLogWriter = keylog
+	}
+
+	if opts.Trace {
+		return runTrace(opts)
+	}
+
+	if opts.CheckExpiry {
+		return runExpiry(opts)
+	}
+
+	if opts.KeyInfo {
+		return runKeys(opts)
+	}
+
+	if opts.NSSearch {
+		return runNSSearch(opts)
+	}
+
+	if opts.Delegation {
+		return runDelegation(opts)
+	}
+
+	if opts.Walk {
+		return runWalk(opts)
+	}
+
+	if opts.Decode != "" {
+		return runDecode(opts)
+	}
+
+	if opts.Replay != "" {
+		return runReplay(opts)
+	}
+
+	if opts.Compare {
+		return runCompare(opts)
+	}
+
+	resp, err := exchange(opts)
+	// Query failed, make it fail
+	if err != nil {
+		return opts, 9, fmt.Errorf("query: %w", err)
+	}
+
+	if opts.Validate {
+		resp.DNSSEC, err = query.Validate(resp, opts)
+		if err != nil {
+			return opts, 9, fmt.Errorf("validate: %w", err)
+		}
+	}
+
+	str, code, err := format(resp, opts)
+	if err != nil {
+		return opts, code, err
+	}
+
+	fmt.Println(str)
+
+	return opts, 0, nil
+}
+
+// exchange makes the query, retrying it if it fails.
+func exchange(opts *util.Options) (resp util.Response, err error) {
+	for i := 0; i <= opts.Request.Retries; i++ {
+		resp, err = query.CreateQuery(opts)
+		if err == nil {
+			resp.Retries = i
+
+			break
+		} else if i != opts.Request.Retries {
+			opts.Logger.Warn("Retrying request, error:", err)
+		}
+	}
+
+	return resp, err
+}
+
+// runTrace traces the query from the root, printing every step.
+func runTrace(opts *util.Options) (*util.Options, int, error) {
+	steps, traceErr := trace.Trace(opts)
+	if len(steps) == 0 {
+		return opts, 9, fmt.Errorf("trace: %w", traceErr)
+	}
+
+	if opts.Format != "" {
+		str, err := trace.PrintSpecial(steps, opts)
+		if err != nil {
+			return opts, 10, fmt.Errorf("format print: %w", err)
 		}
 
-		// Query failed, make it fail
+		fmt.Println(str)
+	} else {
+		str, err := trace.ToString(steps, opts)
+		if err != nil {
+			return opts, 15, fmt.Errorf("standard print: %w", err)
+		}
+
+		fmt.Println(str)
+	}
+
+	// Print what could be traced before failing
+	if traceErr != nil {
+		return opts, 9, fmt.Errorf("trace: %w", traceErr)
+	}
+
+	return opts, 0, nil
+}
+
+// runExpiry checks when the signatures of a zone expire, exiting like a Nagios plugin.
+func runExpiry(opts *util.Options) (*util.Options, int, error) {
+	report, err := expiry.Check(opts)
+	if err != nil {
+		report = &expiry.Report{
+			Zone:     opts.Request.Name,
+			State:    expiry.Unknown,
+			Summary:  err.Error(),
+			Warning:  opts.ExpiryWarning,
+			Critical: opts.ExpiryCritical,
+		}
+	}
+
+	if opts.Format != "" {
+		str, err := query.Marshal(report, opts)
+		if err != nil {
+			return opts, int(expiry.Unknown), fmt.Errorf("format print: %w", err)
+		}
+
+		fmt.Println(str)
+	} else {
+		fmt.Println(expiry.ToString(report))
+	}
+
+	return opts, int(report.State), nil
+}
+
+// runKeys describes DNSKEY records, either from a file or from a query.
+func runKeys(opts *util.Options) (*util.Options, int, error) {
+	var (
+		keys []*dns.DNSKEY
+		err  error
+	)
+
+	if opts.KeyFile != "" {
+		keys, err = dnskey.Load(opts.KeyFile)
+		if err != nil {
+			return opts, 1, fmt.Errorf("keys: %w", err)
+		}
+	} else {
+		resp, err := exchange(opts)
 		if err != nil {
 			return opts, 9, fmt.Errorf("query: %w", err)
 		}
 
-		var str string
-		if opts.JSON || opts.XML || opts.YAML {
-			str, err = query.PrintSpecial(resp, opts)
-			if err != nil {
-				return opts, 10, fmt.Errorf("format print: %w", err)
-			}
-		} else {
-			str, err = query.ToString(resp, opts)
-			if err != nil {
-				return opts, 15, fmt.Errorf("standard print: %w", err)
-			}
+		keys, err = dnskey.FromMsg(resp.DNS)
+		if err != nil {
+			return opts, 9, fmt.Errorf("keys: %w", err)
+		}
+	}
+
+	info := dnskey.Keys{}
+	for _, key := range keys {
+		info.Keys = append(info.Keys, dnskey.Describe(key))
+	}
+
+	if opts.Format != "" {
+		str, err := query.Marshal(info, opts)
+		if err != nil {
+			return opts, 10, fmt.Errorf("format print: %w", err)
 		}
 
 		fmt.Println(str)
+	} else {
+		fmt.Println(dnskey.ToString(info.Keys))
+	}
 
-		if keepTracing {
-			var records []dns.RR
+	return opts, 0, nil
+}
 
-			if opts.Request.Name == "." {
-				records = resp.DNS.Answer
-			} else {
-				records = resp.DNS.Ns
-			}
+// runNSSearch queries every authoritative server of the zone, failing if any of them has a problem.
+func runNSSearch(opts *util.Options) (*util.Options, int, error) {
+	res, err := nssearch.Search(opts)
+	if err != nil {
+		return opts, 9, fmt.Errorf("nssearch: %w", err)
+	}
 
-			want := func(rr dns.RR) bool {
-				temp := strings.Split(rr.String(), "\t")
+	if opts.Format != "" {
+		str, err := query.Marshal(res, opts)
+		if err != nil {
+			return opts, 10, fmt.Errorf("format print: %w", err)
+		}
 
-				return temp[len(temp)-2] == "NS"
-			}
+		fmt.Println(str)
+	} else {
+		fmt.Println(nssearch.ToString(res))
+	}
 
-			i := 0
+	if !res.Consistent {
+		return opts, 1, nil
+	}
 
-			for _, x := range records {
-				if want(x) {
-					records[i] = x
-					i++
-				}
-			}
+	return opts, 0, nil
+}
 
-			records = records[:i]
-			randomRR := records[r.Intn(len(records))]
+// runDelegation checks the delegation of the zone, exiting with the worst severity found.
+func runDelegation(opts *util.Options) (*util.Options, int, error) {
+	report, err := delegation.Check(opts)
+	if err != nil {
+		return opts, 9, fmt.Errorf("delegation: %w", err)
+	}
 
-			v := strings.Split(randomRR.String(), "\t")
-			opts.Request.Server = strings.TrimSuffix(v[len(v)-1], ".")
+	if opts.Format != "" {
+		str, err := query.Marshal(report, opts)
+		if err != nil {
+			return opts, 10, fmt.Errorf("format print: %w", err)
+		}
 
-			opts.TLS = false
-			opts.HTTPS = false
-			opts.QUIC = false
+		fmt.Println(str)
+	} else {
+		fmt.Println(delegation.ToString(report))
+	}
 
-			opts.RD = false
-			opts.Request.Port = 53
+	return opts, int(report.Severity), nil
+}
+
+// runWalk walks the NSEC or NSEC3 chain of a zone, printing what was found.
+func runWalk(opts *util.Options) (*util.Options, int, error) {
+	res, walkErr := walk.Walk(opts)
+
+	if opts.WalkDict != "" {
+		found, err := walk.CrackFile(res, opts.WalkDict)
+		if err != nil {
+			return opts, 1, fmt.Errorf("walk: %w", err)
 		}
+
+		opts.Logger.Info("Found", found, "names in the dictionary")
+	}
+
+	if opts.Format != "" {
+		str, err := query.Marshal(res, opts)
+		if err != nil {
+			return opts, 10, fmt.Errorf("format print: %w", err)
+		}
+
+		fmt.Println(str)
+	} else {
+		fmt.Println(walk.ToString(res))
+	}
+
+	// Print what could be walked before failing
+	if walkErr != nil {
+		return opts, 9, fmt.Errorf("walk: %w", walkErr)
+	}
+
+	return opts, 0, nil
+}
+
+// runDecode prints a message given on the command line, without sending anything.
+func runDecode(opts *util.Options) (*util.Options, int, error) {
+	msg, err := query.Decode(opts.Decode)
+	if err != nil {
+		return opts, 1, err //nolint:wrapcheck // Already says what failed
+	}
+
+	// Nothing was sent, so there's nothing to measure
+	opts.Display.Statistics = false
+
+	str, code, err := format(util.Response{DNS: msg}, opts)
+	if err != nil {
+		return opts, code, err
+	}
+
+	fmt.Println(str)
+
+	return opts, 0, nil
+}
+
+// runReplay replays the queries of a capture, failing if any response differs from the recorded one.
+func runReplay(opts *util.Options) (*util.Options, int, error) {
+	res, err := replay.Replay(opts)
+	if err != nil {
+		return opts, 1, err //nolint:wrapcheck // Already says what failed
+	}
+
+	if opts.Format != "" {
+		str, err := query.Marshal(res, opts)
+		if err != nil {
+			return opts, 10, fmt.Errorf("format print: %w", err)
+		}
+
+		fmt.Println(str)
+	} else {
+		fmt.Println(replay.ToString(res))
+	}
+
+	if res.Different > 0 || res.Failed > 0 {
+		return opts, 1, nil
 	}
 
 	return opts, 0, nil
 }
+
+// runCompare sends the query to every server given, failing if their responses differ.
+func runCompare(opts *util.Options) (*util.Options, int, error) {
+	res := query.Compare(opts)
+
+	if opts.Format != "" {
+		str, err := query.Marshal(res, opts)
+		if err != nil {
+			return opts, 10, fmt.Errorf("format print: %w", err)
+		}
+
+		fmt.Println(str)
+	} else {
+		fmt.Println(query.CompareString(res, opts))
+	}
+
+	if !res.Same {
+		return opts, 1, nil
+	}
+
+	return opts, 0, nil
+}
+
+// format formats a response as requested, returning an exit code on failure.
+func format(resp util.Response, opts *util.Options) (str string, code int, err error) {
+	if opts.Format != "" {
+		str, err = query.PrintSpecial(resp, opts)
+		if err != nil {
+			return "", 10, fmt.Errorf("format print: %w", err)
+		}
+	} else {
+		str, err = query.ToString(resp, opts)
+		if err != nil {
+			return "", 15, fmt.Errorf("standard print: %w", err)
+		}
+	}
+
+	return str, 0, nil
+}
@@ -46,3 +46,17 @@ func TestHelp(t *testing.T) {
 	assert.ErrorIs(t, err, zflag.ErrHelp)
 	assert.Equal(t, code, 1)
 }
+
+func TestDecode(t *testing.T) {
+	// t.Parallel()
+	// A query for example.com. A, as sent by DoH GET
+	args := []string{"awl", "--decode", "https://dns.example/dns-query?dns=AAABAAABAAAAAAAAB2V4YW1wbGUDY29tAAABAAE", "+short"}
+
+	_, code, err := run(args)
+	assert.NilError(t, err)
+	assert.Equal(t, code, 0)
+
+	_, code, err = run([]string{"awl", "--decode", "zz"})
+	assert.ErrorContains(t, err, "decode")
+	assert.Equal(t, code, 1)
+}
@@ -0,0 +1,337 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package delegation
+
+import (
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"dns.froth.zone/awl/pkg/query"
+	"dns.froth.zone/awl/pkg/trace"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+)
+
+// What a finding is about.
+const (
+	// CheckNS compares the NS records of the parent and the child.
+	CheckNS = "ns"
+	// CheckGlue compares the glue of the parent with the address records of the child.
+	CheckGlue = "glue"
+	// CheckDS compares the DS records of the parent with the DNSKEY records of the child.
+	CheckDS = "ds"
+)
+
+// Report is the result of checking the delegation of a zone.
+//
+//nolint:govet // Better looking output is worth a few bytes.
+type Report struct {
+	XMLName xml.Name `json:"-" xml:"delegation" yaml:"-"`
+	// Zone that was checked
+	Zone string `json:"zone" xml:"zone" yaml:"zone" example:"example.com."`
+	// Zone that delegates to it
+	Parent string `json:"parent" xml:"parent" yaml:"parent" example:"com."`
+	// Server of the parent that gave the referral
+	ParentServer  string `json:"parentServer" xml:"parentServer" yaml:"parentServer" example:"a.gtld-servers.net."`
+	ParentAddress string `json:"parentAddress" xml:"parentAddress" yaml:"parentAddress" example:"192.5.6.30"`
+	// The worst severity of every finding
+	Severity Severity `json:"severity" xml:"severity" yaml:"severity" example:"INFO"`
+	// Name servers of the zone, as the parent and the child see them
+	Servers []Server `json:"servers" xml:"server" yaml:"servers"`
+	// Everything found, the worst first
+	Findings []Finding `json:"findings" xml:"finding" yaml:"findings"`
+}
+
+// Server is a name server of the zone.
+//
+//nolint:govet // Better looking output is worth a few bytes.
+type Server struct {
+	Name string `json:"name" xml:"name" yaml:"name" example:"ns1.example.com."`
+	// Whether the server is in the NS records of the parent and of the child
+	Parent bool `json:"parent" xml:"parent" yaml:"parent" example:"true"`
+	Child  bool `json:"child" xml:"child" yaml:"child" example:"true"`
+	// Addresses given as glue by the parent
+	Glue []string `json:"glue,omitempty" xml:"glue,omitempty" yaml:"glue,omitempty" example:"192.0.2.1"`
+	// Addresses the child gives, only looked up for servers in the zone
+	Addrs []string `json:"addresses,omitempty" xml:"address,omitempty" yaml:"addresses,omitempty" example:"192.0.2.1"`
+}
+
+// Finding is a single thing found while checking.
+type Finding struct {
+	Severity Severity `json:"severity" xml:"severity" yaml:"severity" example:"ERROR"`
+	// ns, glue or ds
+	Check   string `json:"check" xml:"check" yaml:"check" example:"glue"`
+	Message string `json:"message" xml:"message" yaml:"message"`
+}
+
+// Checker checks the delegation of a zone.
+type Checker struct {
+	opts   *util.Options
+	tracer *trace.Tracer
+}
+
+// New creates a new Checker from the options given.
+func New(opts *util.Options) *Checker {
+	return NewWith(opts, query.CreateQuery)
+}
+
+// NewWith creates a new Checker making every query with exchange, as with [trace.NewWith].
+func NewWith(opts *util.Options, exchange func(*util.Options) (util.Response, error)) *Checker {
+	return &Checker{
+		opts:   opts,
+		tracer: trace.NewWith(opts, exchange),
+	}
+}
+
+// Check checks the delegation of the zone the requested name is in.
+func Check(opts *util.Options) (*Report, error) {
+	return New(opts).Check()
+}
+
+// Check finds the zone the requested name is in and the referral to it from
+// its parent, then checks that:
+//   - the NS records of the referral and of the zone are the same,
+//   - every name server in the zone has glue, which matches its address records in the zone,
+//   - every DS record of the parent matches a DNSKEY record of the zone.
+func (c *Checker) Check() (*Report, error) {
+	zone, srvs, err := c.tracer.Authoritative(c.opts.Request.Name)
+	if err != nil {
+		return nil, fmt.Errorf("finding servers: %w", err)
+	}
+
+	steps, err := c.tracer.Resolve(zone, dns.TypeNS)
+	if err != nil {
+		return nil, fmt.Errorf("finding referral: %w", err)
+	}
+
+	var (
+		referral trace.Step
+		glue     []trace.Server
+	)
+
+	// The last referral is the one from the parent, earlier ones may be for aliases
+	for _, step := range steps {
+		if cut, next := trace.Referral(step.Response.DNS, step.Zone, zone); cut != "" && util.EqualNames(cut, zone) {
+			referral, glue = step, next
+		}
+	}
+
+	if referral.Response.DNS == nil {
+		return nil, fmt.Errorf("%s: %w", zone, errNoReferral)
+	}
+
+	r := &Report{
+		Zone:          zone,
+		Parent:        referral.Zone,
+		ParentServer:  referral.Server,
+		ParentAddress: referral.Address,
+	}
+
+	r.servers(glue, srvs)
+	c.glue(r, srvs)
+	c.ds(r, referral, srvs)
+
+	slices.SortStableFunc(r.Findings, func(a, b Finding) int {
+		return cmp.Compare(b.Severity, a.Severity)
+	})
+
+	for _, f := range r.Findings {
+		r.Severity = max(r.Severity, f.Severity)
+	}
+
+	return r, nil
+}
+
+// servers compares the NS records of the referral with the ones of the child.
+func (r *Report) servers(parent, child []trace.Server) {
+	for _, srv := range parent {
+		r.Servers = append(r.Servers, Server{Name: srv.Name, Parent: true, Glue: srv.Addrs})
+	}
+
+	for _, srv := range child {
+		i := slices.IndexFunc(r.Servers, func(s Server) bool { return util.EqualNames(s.Name, srv.Name) })
+		if i == -1 {
+			r.Servers = append(r.Servers, Server{Name: srv.Name})
+			i = len(r.Servers) - 1
+		}
+
+		r.Servers[i].Child = true
+	}
+
+	slices.SortFunc(r.Servers, func(a, b Server) int {
+		return strings.Compare(dns.CanonicalName(a.Name), dns.CanonicalName(b.Name))
+	})
+
+	same := true
+
+	for _, s := range r.Servers {
+		switch {
+		case !s.Child:
+			r.add(Warning, CheckNS, "%s is delegated to by %s but is not an NS record of %s", s.Name, r.Parent, r.Zone)
+		case !s.Parent:
+			r.add(Warning, CheckNS, "%s is an NS record of %s but is not delegated to by %s", s.Name, r.Zone, r.Parent)
+		default:
+			continue
+		}
+
+		same = false
+	}
+
+	if same {
+		r.add(Info, CheckNS, "%s and %s give the same %d NS records", r.Parent, r.Zone, len(r.Servers))
+	}
+}
+
+// glue compares the glue of every server in the zone with its address records in the zone.
+func (c *Checker) glue(r *Report, child []trace.Server) {
+	for i := range r.Servers {
+		s := &r.Servers[i]
+
+		// Glue for servers outside of the zone is not needed, and is ignored by resolvers
+		if !s.Parent || !dns.IsSubDomain(r.Zone, s.Name) {
+			continue
+		}
+
+		if len(s.Glue) == 0 {
+			r.add(Error, CheckGlue, "%s is in %s but %s gives no glue for it", s.Name, r.Zone, r.Parent)
+		}
+
+		var failed bool
+
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			step, err := c.tracer.Query(child, r.Zone, s.Name, qtype)
+			if err != nil {
+				r.add(Warning, CheckGlue, "unable to find the %s records of %s: %s", dns.TypeToString[qtype], s.Name, err)
+
+				failed = true
+
+				continue
+			}
+
+			for _, rr := range step.Response.DNS.Answer {
+				switch rr := rr.(type) {
+				case *dns.A:
+					s.Addrs = append(s.Addrs, rr.A.String())
+				case *dns.AAAA:
+					s.Addrs = append(s.Addrs, rr.AAAA.String())
+				}
+			}
+		}
+
+		if failed || len(s.Glue) == 0 {
+			continue
+		}
+
+		same := true
+
+		for _, addr := range s.Glue {
+			if !slices.Contains(s.Addrs, addr) {
+				r.add(Error, CheckGlue, "glue %s for %s is not an address of it in %s", addr, s.Name, r.Zone)
+
+				same = false
+			}
+		}
+
+		for _, addr := range s.Addrs {
+			if !slices.Contains(s.Glue, addr) {
+				r.add(Warning, CheckGlue, "address %s of %s is missing from the glue", addr, s.Name)
+
+				same = false
+			}
+		}
+
+		if same {
+			r.add(Info, CheckGlue, "glue for %s matches its addresses in %s", s.Name, r.Zone)
+		}
+	}
+}
+
+// ds compares the DS records of the parent with the DNSKEY records of the child.
+func (c *Checker) ds(r *Report, referral trace.Step, child []trace.Server) {
+	parent := []trace.Server{{Name: referral.Server, Addrs: []string{referral.Address}}}
+
+	step, err := c.tracer.Query(parent, r.Parent, r.Zone, dns.TypeDS)
+	if err != nil {
+		r.add(Error, CheckDS, "unable to find the DS records of %s: %s", r.Zone, err)
+
+		return
+	}
+
+	var dses []*dns.DS
+
+	for _, rr := range step.Response.DNS.Answer {
+		if ds, ok := rr.(*dns.DS); ok && util.EqualNames(ds.Hdr.Name, r.Zone) {
+			dses = append(dses, ds)
+		}
+	}
+
+	step, err = c.tracer.Query(child, r.Zone, r.Zone, dns.TypeDNSKEY)
+	if err != nil {
+		r.add(Error, CheckDS, "unable to find the DNSKEY records of %s: %s", r.Zone, err)
+
+		return
+	}
+
+	var keys []*dns.DNSKEY
+
+	for _, rr := range step.Response.DNS.Answer {
+		if key, ok := rr.(*dns.DNSKEY); ok && util.EqualNames(key.Hdr.Name, r.Zone) {
+			keys = append(keys, key)
+		}
+	}
+
+	switch {
+	case len(dses) == 0 && len(keys) == 0:
+		r.add(Info, CheckDS, "%s is not signed", r.Zone)
+
+		return
+	case len(dses) == 0:
+		r.add(Warning, CheckDS, "%s has DNSKEY records but %s has no DS records for it, so it can't be validated", r.Zone, r.Parent)
+
+		return
+	case len(keys) == 0:
+		r.add(Error, CheckDS, "%s has DS records for %s but it has no DNSKEY records", r.Parent, r.Zone)
+
+		return
+	}
+
+	matched := 0
+
+	for _, ds := range dses {
+		desc := fmt.Sprintf("DS %d (%s, %s)", ds.KeyTag, dns.AlgorithmToString[ds.Algorithm], dns.HashToString[ds.DigestType])
+
+		if slices.ContainsFunc(keys, func(key *dns.DNSKEY) bool { return matches(ds, key) }) {
+			r.add(Info, CheckDS, "%s matches a DNSKEY record of %s", desc, r.Zone)
+
+			matched++
+		} else {
+			r.add(Warning, CheckDS, "%s matches no DNSKEY record of %s", desc, r.Zone)
+		}
+	}
+
+	if matched == 0 {
+		r.add(Error, CheckDS, "no DS record of %s matches a DNSKEY record of %s, so it will not validate", r.Parent, r.Zone)
+	}
+}
+
+// matches checks if a DS record is the digest of a zone key.
+func matches(ds *dns.DS, key *dns.DNSKEY) bool {
+	if key.Flags&dns.ZONE == 0 || key.KeyTag() != ds.KeyTag || key.Algorithm != ds.Algorithm {
+		return false
+	}
+
+	digest := key.ToDS(ds.DigestType)
+
+	return digest != nil && strings.EqualFold(digest.Digest, ds.Digest)
+}
+
+// add adds a finding to the report.
+func (r *Report) add(severity Severity, check, format string, args ...any) {
+	r.Findings = append(r.Findings, Finding{severity, check, fmt.Sprintf(format, args...)})
+}
+
+var errNoReferral = errors.New("no referral from a parent zone, it may be served by the same servers")
@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package delegation_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"dns.froth.zone/awl/pkg/delegation"
+	"dns.froth.zone/awl/pkg/dnstest"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+// check checks the delegation of example.com. from com., given the records of
+// com. and of example.com., which is served at the addresses given.
+func check(t *testing.T, com, child []string, addrs ...string) *delegation.Report {
+	t.Helper()
+
+	network := dnstest.Network{
+		"198.41.0.4": {Origin: ".", Records: []string{
+			"com. 172800 IN NS a.gtld-servers.net.",
+			"a.gtld-servers.net. 172800 IN A 192.5.6.30",
+		}},
+		"192.5.6.30": {Origin: "com.", Records: com},
+	}
+
+	for _, addr := range addrs {
+		network[addr] = dnstest.Zone{Origin: "example.com.", Records: append([]string{
+			"example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 3600",
+		}, child...)}
+	}
+
+	r, err := newChecker(network).Check()
+	assert.NilError(t, err)
+
+	return r
+}
+
+func newChecker(network dnstest.Network) *delegation.Checker {
+	opts := &util.Options{
+		Logger:  util.InitLogger(0),
+		Request: util.Request{Name: "www.example.com.", Type: dns.TypeA},
+	}
+
+	return delegation.NewWith(opts, network.Exchange)
+}
+
+// key makes a KSK for example.com. and its DS record.
+func key(t *testing.T) (*dns.DNSKEY, *dns.DS) {
+	t.Helper()
+
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     dns.ZONE | dns.SEP,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+
+	_, err := key.Generate(256)
+	assert.NilError(t, err)
+
+	return key, key.ToDS(dns.SHA256)
+}
+
+func TestConsistent(t *testing.T) {
+	t.Parallel()
+
+	ksk, ds := key(t)
+
+	r := check(t, []string{
+		"example.com. 172800 IN NS ns1.example.com.",
+		"example.com. 172800 IN NS ns.example.net.",
+		"ns1.example.com. 172800 IN A 192.0.2.1",
+		ds.String(),
+	}, []string{
+		"example.com. 3600 IN NS ns.example.net.",
+		"example.com. 3600 IN NS NS1.example.com.",
+		"ns1.example.com. 3600 IN A 192.0.2.1",
+		ksk.String(),
+	}, "192.0.2.1")
+
+	assert.Equal(t, r.Severity, delegation.Info)
+	assert.Equal(t, r.Parent, "com.")
+	assert.Equal(t, r.ParentServer, "a.gtld-servers.net.")
+	assert.Equal(t, len(r.Servers), 2)
+	assert.Equal(t, r.Servers[0].Name, "ns.example.net.")
+	assert.DeepEqual(t, r.Servers[1].Glue, []string{"192.0.2.1"})
+	assert.DeepEqual(t, r.Servers[1].Addrs, []string{"192.0.2.1"})
+
+	var msgs []string
+	for _, f := range r.Findings {
+		msgs = append(msgs, f.Message)
+	}
+
+	assert.DeepEqual(t, msgs, []string{
+		"com. and example.com. give the same 2 NS records",
+		"glue for ns1.example.com. matches its addresses in example.com.",
+		fmt.Sprintf("DS %d (ECDSAP256SHA256, SHA256) matches a DNSKEY record of example.com.", ds.KeyTag),
+	})
+
+	str := delegation.ToString(r)
+	assert.Assert(t, strings.HasPrefix(str, ";; Delegation of example.com. from com. by a.gtld-servers.net. (192.5.6.30): INFO\n"), str)
+	assert.Assert(t, strings.Contains(str, "\nINFO ns: com. and example.com. give the same 2 NS records"), str)
+}
+
+func TestInconsistent(t *testing.T) {
+	t.Parallel()
+
+	// The DS is of a key the child no longer has
+	_, ds := key(t)
+	other, _ := key(t)
+
+	// ns1 still answers at the address in the glue
+	r := check(t, []string{
+		"example.com. 172800 IN NS ns1.example.com.",
+		"example.com. 172800 IN NS ns3.example.com.",
+		"ns1.example.com. 172800 IN A 192.0.2.9",
+		ds.String(),
+	}, []string{
+		"example.com. 3600 IN NS ns1.example.com.",
+		"example.com. 3600 IN NS ns2.example.com.",
+		"ns1.example.com. 3600 IN A 192.0.2.1",
+		"ns1.example.com. 3600 IN AAAA 2001:db8::1",
+		other.String(),
+	}, "192.0.2.1", "192.0.2.9")
+
+	assert.Equal(t, r.Severity, delegation.Error)
+
+	found := make(map[string]delegation.Severity)
+	for _, f := range r.Findings {
+		found[f.Check+": "+f.Message] = f.Severity
+	}
+
+	for msg, severity := range map[string]delegation.Severity{
+		"ns: ns2.example.com. is an NS record of example.com. but is not delegated to by com.":      delegation.Warning,
+		"ns: ns3.example.com. is delegated to by com. but is not an NS record of example.com.":      delegation.Warning,
+		"glue: ns3.example.com. is in example.com. but com. gives no glue for it":                   delegation.Error,
+		"glue: glue 192.0.2.9 for ns1.example.com. is not an address of it in example.com.":         delegation.Error,
+		"glue: address 192.0.2.1 of ns1.example.com. is missing from the glue":                      delegation.Warning,
+		"glue: address 2001:db8::1 of ns1.example.com. is missing from the glue":                    delegation.Warning,
+		"ds: no DS record of com. matches a DNSKEY record of example.com., so it will not validate": delegation.Error,
+	} {
+		got, ok := found[msg]
+		assert.Assert(t, ok, msg)
+		assert.Equal(t, got, severity, msg)
+	}
+
+	// The worst come first
+	assert.Equal(t, r.Findings[0].Severity, delegation.Error)
+	assert.Equal(t, r.Findings[len(r.Findings)-1].Severity, delegation.Warning)
+}
+
+func TestNoReferral(t *testing.T) {
+	t.Parallel()
+
+	// The root servers serve example.com. too
+	c := newChecker(dnstest.Network{
+		"198.41.0.4": {Origin: ".", Records: []string{
+			"example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 3600",
+			"example.com. 3600 IN NS ns1.example.com.",
+			"ns1.example.com. 3600 IN A 198.41.0.4",
+			"www.example.com. 3600 IN A 192.0.2.80",
+		}},
+	})
+
+	_, err := c.Check()
+	assert.ErrorContains(t, err, "no referral from a parent zone")
+
+	_, err = newChecker(dnstest.Network{}).Check()
+	assert.ErrorContains(t, err, "finding servers")
+}
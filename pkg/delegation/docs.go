@@ -0,0 +1,11 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+/*
+Package delegation checks that the delegation of a zone from its parent matches
+the zone itself.
+
+The referral to the zone is found from the root, as when tracing. Its NS records
+and glue are compared with the NS and address records of the zone, and its DS
+records with the DNSKEY records of the zone. Every finding is given a severity.
+*/
+package delegation
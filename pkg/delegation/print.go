@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package delegation
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// ToString prints a table of the name servers of the zone, as the parent and
+// the child see them, followed by every finding.
+func ToString(r *Report) string {
+	var buf strings.Builder
+
+	fmt.Fprintf(&buf, ";; Delegation of %s from %s by %s (%s): %s\n",
+		r.Zone, r.Parent, r.ParentServer, r.ParentAddress, r.Severity)
+
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "server\tparent\tchild\tglue\taddresses")
+
+	for _, s := range r.Servers {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Name, yesNo(s.Parent), yesNo(s.Child), list(s.Glue), list(s.Addrs))
+	}
+
+	w.Flush() //nolint:errcheck,gosec // Writes to a strings.Builder
+
+	for _, f := range r.Findings {
+		fmt.Fprintf(&buf, "%s %s: %s\n", f.Severity, f.Check, f.Message)
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+
+	return "no"
+}
+
+func list(strs []string) string {
+	if len(strs) == 0 {
+		return "-"
+	}
+
+	return strings.Join(strs, ",")
+}
@@ -0,0 +1,32 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package delegation
+
+// Severity is how bad a finding is, with the values being the exit codes used.
+type Severity int
+
+const (
+	// Info is a finding that needs no action, such as records that match.
+	Info Severity = iota
+	// Warning is a finding that does not break resolution, but should be fixed.
+	Warning
+	// Error is a finding that breaks, or can break, resolution or validation.
+	Error
+)
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "INFO"
+	case Warning:
+		return "WARNING"
+	default:
+		return "ERROR"
+	}
+}
+
+// MarshalText makes the severity print as its name in JSON, XML and YAML.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
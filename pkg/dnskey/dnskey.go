@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package dnskey
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Digests are the DS digest types computed for every key.
+var Digests = []uint8{dns.SHA256, dns.SHA384}
+
+// Keys is a set of described keys, made printable.
+type Keys struct {
+	XMLName xml.Name `json:"-" xml:"keys" yaml:"-"`
+	Keys    []Info   `json:"keys" xml:"key" yaml:"keys"`
+}
+
+// Info describes a single DNSKEY.
+//
+//nolint:govet // Better looking output is worth a few bytes.
+type Info struct {
+	// Owner name of the key
+	Name   string `json:"name" xml:"name" yaml:"name" example:"example.com."`
+	TTL    uint32 `json:"TTL" xml:"TTL" yaml:"TTL" example:"3600"`
+	KeyTag uint16 `json:"keyTag" xml:"keyTag" yaml:"keyTag" example:"12345"`
+	Flags  uint16 `json:"flags" xml:"flags" yaml:"flags" example:"257"`
+	// KSK or ZSK
+	Role string `json:"role" xml:"role" yaml:"role" example:"KSK"`
+	// Whether the REVOKE flag is set
+	Revoked       bool   `json:"revoked,omitempty" xml:"revoked,omitempty" yaml:"revoked,omitempty"`
+	Algorithm     uint8  `json:"algorithm" xml:"algorithm" yaml:"algorithm" example:"13"`
+	AlgorithmName string `json:"algorithmName" xml:"algorithmName" yaml:"algorithmName" example:"ECDSAP256SHA256"`
+	// Size of the key in bits
+	Size int `json:"size" xml:"size" yaml:"size" example:"256"`
+	// DS records for the key, one per digest type
+	DS []DS `json:"ds" xml:"ds" yaml:"ds"`
+}
+
+// DS is a DS record computed from a key.
+type DS struct {
+	DigestType     uint8  `json:"digestType" xml:"digestType" yaml:"digestType" example:"2"`
+	DigestTypeName string `json:"digestTypeName" xml:"digestTypeName" yaml:"digestTypeName" example:"SHA256"`
+	Digest         string `json:"digest" xml:"digest" yaml:"digest"`
+}
+
+// Describe describes a DNSKEY.
+func Describe(key *dns.DNSKEY) Info {
+	info := Info{
+		Name:          key.Hdr.Name,
+		TTL:           key.Hdr.Ttl,
+		KeyTag:        key.KeyTag(),
+		Flags:         key.Flags,
+		Role:          Role(key),
+		Revoked:       key.Flags&dns.REVOKE != 0,
+		Algorithm:     key.Algorithm,
+		AlgorithmName: algorithm(key.Algorithm),
+		Size:          Size(key),
+	}
+
+	for _, digest := range Digests {
+		if ds := key.ToDS(digest); ds != nil {
+			info.DS = append(info.DS, DS{ds.DigestType, dns.HashToString[ds.DigestType], strings.ToUpper(ds.Digest)})
+		}
+	}
+
+	return info
+}
+
+// Role returns KSK for keys with the SEP flag set, and ZSK otherwise.
+func Role(key *dns.DNSKEY) string {
+	if key.Flags&dns.SEP != 0 {
+		return "KSK"
+	}
+
+	return "ZSK"
+}
+
+// Size returns the size of a key in bits, or 0 if it cannot be found.
+func Size(key *dns.DNSKEY) int {
+	raw, err := base64.StdEncoding.DecodeString(key.PublicKey)
+	if err != nil || len(raw) == 0 {
+		return 0
+	}
+
+	switch key.Algorithm {
+	case dns.RSAMD5, dns.RSASHA1, dns.RSASHA1NSEC3SHA1, dns.RSASHA256, dns.RSASHA512:
+		// RFC 3110 section 2
+		explen, off := int(raw[0]), 1
+		if explen == 0 {
+			if len(raw) < 3 {
+				return 0
+			}
+
+			explen, off = int(raw[1])<<8|int(raw[2]), 3
+		}
+
+		if off+explen >= len(raw) {
+			return 0
+		}
+
+		return new(big.Int).SetBytes(raw[off+explen:]).BitLen()
+	case dns.ECDSAP256SHA256, dns.ECDSAP384SHA384:
+		// Both coordinates of the point
+		return len(raw) * 4
+	default:
+		return len(raw) * 8
+	}
+}
+
+// Comment returns a comment describing a key, the same as dig's +rrcomments.
+func Comment(key *dns.DNSKEY) string {
+	role := Role(key)
+	if key.Flags&dns.REVOKE != 0 {
+		role += "; REVOKED"
+	}
+
+	return fmt.Sprintf("; %s; alg = %s ; key id = %d", role, algorithm(key.Algorithm), key.KeyTag())
+}
+
+// Read reads every DNSKEY and CDNSKEY record in zone file format.
+func Read(r io.Reader, file string) (keys []*dns.DNSKEY, err error) {
+	zp := dns.NewZoneParser(r, ".", file)
+
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		switch rr := rr.(type) {
+		case *dns.DNSKEY:
+			keys = append(keys, rr)
+		case *dns.CDNSKEY:
+			keys = append(keys, &rr.DNSKEY)
+		}
+	}
+
+	if err := zp.Err(); err != nil {
+		return nil, fmt.Errorf("key file: %w", err)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("key file: %w", errNoKeys)
+	}
+
+	return keys, nil
+}
+
+// Load reads every DNSKEY and CDNSKEY record from a file, or stdin if file is "-".
+func Load(file string) ([]*dns.DNSKEY, error) {
+	if file == "-" {
+		return Read(os.Stdin, "stdin")
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("key file: %w", err)
+	}
+	defer f.Close()
+
+	return Read(f, file)
+}
+
+// FromMsg returns every DNSKEY record in the answer of a message.
+func FromMsg(msg *dns.Msg) (keys []*dns.DNSKEY, err error) {
+	for _, rr := range msg.Answer {
+		switch rr := rr.(type) {
+		case *dns.DNSKEY:
+			keys = append(keys, rr)
+		case *dns.CDNSKEY:
+			keys = append(keys, &rr.DNSKEY)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, errNoKeys
+	}
+
+	return keys, nil
+}
+
+func algorithm(alg uint8) string {
+	if name, ok := dns.AlgorithmToString[alg]; ok {
+		return name
+	}
+
+	return strconv.Itoa(int(alg))
+}
+
+var errNoKeys = errors.New("no DNSKEY records found")
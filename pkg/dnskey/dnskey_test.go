@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package dnskey_test
+
+import (
+	"strings"
+	"testing"
+
+	"dns.froth.zone/awl/pkg/dnskey"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+// From RFC 4034 section 5.4
+const zone = `dskey.example.com. 86400 IN DNSKEY 256 3 5 ( AQOeiiR0GOMYkDshWoSKz9Xz
+                                             fwJr1AYtsmx3TGkJaNXVbfi/
+                                             2pHm822aJ5iI9BMzNXxeYCmZ
+                                             DRD99WYwYqUSdjMmmAphXdvx
+                                             egXd/M5+X7OrzKBaMbCVdFLU
+                                             Uh6DhweJBjEVv5f2wwjM9Xzc
+                                             nOf+EPbtG9DMBmADjFDc2w/r
+                                             ljwvFw==
+                                             ) ;  key id = 60485
+dskey.example.com. 86400 IN A 192.0.2.1
+`
+
+func TestDescribe(t *testing.T) {
+	t.Parallel()
+
+	keys, err := dnskey.Read(strings.NewReader(zone), "test")
+	assert.NilError(t, err)
+	assert.Equal(t, len(keys), 1)
+
+	info := dnskey.Describe(keys[0])
+	assert.Equal(t, info.KeyTag, uint16(60485))
+	assert.Equal(t, info.Role, "ZSK")
+	assert.Equal(t, info.AlgorithmName, "RSASHA1")
+	assert.Equal(t, info.Size, 1024)
+	assert.Equal(t, len(info.DS), 2)
+	assert.Equal(t, info.DS[0].DigestTypeName, "SHA256")
+	assert.Equal(t, info.DS[1].DigestTypeName, "SHA384")
+
+	assert.Equal(t, dnskey.Comment(keys[0]), "; ZSK; alg = RSASHA1 ; key id = 60485")
+
+	str := dnskey.ToString([]dnskey.Info{info})
+	assert.Assert(t, strings.HasPrefix(str, "; dskey.example.com. ZSK, key id = 60485, alg = RSASHA1 (5), 1024 bits, flags = 256\n"))
+	assert.Assert(t, strings.Contains(str,
+		"\ndskey.example.com.\t86400\tIN\tDS\t60485 5 2 D4B7D520E7BB5F0F67674A0CCEB1E3E0614B93C4F9E99B8383F6A1E4469DA50A\n"))
+}
+
+func TestKSK(t *testing.T) {
+	t.Parallel()
+
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: "example.", Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET},
+		Flags:     dns.ZONE | dns.SEP | dns.REVOKE,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+
+	_, err := key.Generate(256)
+	assert.NilError(t, err)
+
+	info := dnskey.Describe(key)
+	assert.Equal(t, info.Role, "KSK")
+	assert.Assert(t, info.Revoked)
+	assert.Equal(t, info.Size, 256)
+	assert.Assert(t, strings.HasPrefix(dnskey.Comment(key), "; KSK; REVOKED; alg = ECDSAP256SHA256 ; key id = "))
+}
+
+func TestNoKeys(t *testing.T) {
+	t.Parallel()
+
+	_, err := dnskey.Read(strings.NewReader("example. 300 IN A 192.0.2.1"), "test")
+	assert.ErrorContains(t, err, "no DNSKEY")
+}
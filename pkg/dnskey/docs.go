@@ -0,0 +1,7 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+/*
+Package dnskey describes DNSKEY records, computing their key tags, sizes and
+DS records.
+*/
+package dnskey
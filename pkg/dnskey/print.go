@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package dnskey
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ToString prints keys as comments followed by their DS records, so the output
+// can be pasted into a zone file.
+func ToString(keys []Info) string {
+	var s strings.Builder
+
+	for i, key := range keys {
+		role := key.Role
+		if key.Revoked {
+			role += " (revoked)"
+		}
+
+		fmt.Fprintf(&s, "; %s %s, key id = %d, alg = %s (%d), %d bits, flags = %d\n",
+			key.Name, role, key.KeyTag, key.AlgorithmName, key.Algorithm, key.Size, key.Flags)
+
+		for _, ds := range key.DS {
+			fmt.Fprintf(&s, "%s\t%d\tIN\tDS\t%d %d %d %s\n",
+				key.Name, key.TTL, key.KeyTag, key.Algorithm, ds.DigestType, ds.Digest)
+		}
+
+		if i != len(keys)-1 {
+			s.WriteString("\n")
+		}
+	}
+
+	return strings.TrimSuffix(s.String(), "\n")
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package dnssec
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// rootAnchors are the DS records of the root KSKs, taken from
+// https://data.iana.org/root-anchors/root-anchors.xml
+var rootAnchors = []string{
+	// KSK-2017
+	". IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D",
+	// KSK-2024
+	". IN DS 38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16",
+}
+
+// RootAnchors returns the built-in trust anchors for the root zone.
+func RootAnchors() []dns.RR {
+	anchors := make([]dns.RR, 0, len(rootAnchors))
+
+	for _, s := range rootAnchors {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			panic(err)
+		}
+
+		anchors = append(anchors, rr)
+	}
+
+	return anchors
+}
+
+// LoadAnchors reads trust anchors from a file in zone file format.
+// Both DS and DNSKEY records are accepted, everything else is ignored.
+func LoadAnchors(file string) ([]dns.RR, error) {
+	f, err := os.Open(file) //nolint:gosec // Reading a file given by the user is the point
+	if err != nil {
+		return nil, fmt.Errorf("trust anchor: %w", err)
+	}
+	defer f.Close() //nolint:errcheck // Read only
+
+	return ParseAnchors(f, file)
+}
+
+// ParseAnchors reads trust anchors in zone file format.
+func ParseAnchors(r io.Reader, file string) ([]dns.RR, error) {
+	var anchors []dns.RR
+
+	zp := dns.NewZoneParser(r, ".", file)
+
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		switch rr.(type) {
+		case *dns.DS, *dns.DNSKEY:
+			anchors = append(anchors, rr)
+		}
+	}
+
+	if err := zp.Err(); err != nil {
+		return nil, fmt.Errorf("trust anchor: %w", err)
+	}
+
+	if len(anchors) == 0 {
+		return nil, fmt.Errorf("trust anchor: %w", errNoAnchors)
+	}
+
+	return anchors, nil
+}
+
+// anchorFor returns the closest trust anchor zone enclosing name.
+func anchorFor(anchors []dns.RR, name string) string {
+	closest := ""
+
+	for _, rr := range anchors {
+		owner := strings.ToLower(rr.Header().Name)
+		if dns.IsSubDomain(owner, name) && (closest == "" || dns.CountLabel(owner) > dns.CountLabel(closest)) {
+			closest = owner
+		}
+	}
+
+	return closest
+}
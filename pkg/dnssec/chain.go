@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package dnssec
+
+import (
+	"sort"
+	"time"
+
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+)
+
+// Link is a zone in the chain of trust, along with the zones below it.
+type Link = util.DNSSECLink
+
+// DS is a DS record or DS trust anchor.
+type DS = util.DNSSECDS
+
+// Key is a DNSKEY record.
+type Key = util.DNSSECKey
+
+// Signature is an RRSIG that was checked, and what it signed.
+type Signature = util.DNSSECSignature
+
+// Chain returns the chain of trust built by the validator so far, as a tree
+// rooted at each trust anchor used.
+func (v *Validator) Chain() []*Link {
+	zones := make([]*zone, 0, len(v.zones))
+
+	for _, z := range v.zones {
+		if z != nil {
+			zones = append(zones, z)
+		}
+	}
+
+	sort.Slice(zones, func(i, j int) bool {
+		if a, b := dns.CountLabel(zones[i].name), dns.CountLabel(zones[j].name); a != b {
+			return a < b
+		}
+
+		return compare(zones[i].name, zones[j].name) < 0
+	})
+
+	var (
+		roots []*Link
+		links = make(map[string]*Link, len(zones))
+	)
+
+	for _, z := range zones {
+		link := z.link(v.now)
+		links[z.name] = link
+
+		parent := ""
+
+		for _, anc := range ancestors(z.name) {
+			if _, ok := links[anc]; ok && anc != z.name {
+				parent = anc
+
+				break
+			}
+		}
+
+		if parent == "" {
+			roots = append(roots, link)
+		} else {
+			links[parent].Children = append(links[parent].Children, link)
+		}
+	}
+
+	return roots
+}
+
+// link makes the printable form of a zone.
+func (z *zone) link(now time.Time) *Link {
+	link := &Link{
+		Zone:   z.name,
+		Status: z.status,
+		Reason: z.reason,
+	}
+
+	for _, rr := range z.ds {
+		if ds, ok := rr.(*dns.DS); ok {
+			link.DS = append(link.DS, DS{
+				KeyTag:     ds.KeyTag,
+				Algorithm:  dns.AlgorithmToString[ds.Algorithm],
+				DigestType: dns.HashToString[ds.DigestType],
+				Digest:     ds.Digest,
+				Anchor:     z.anchor,
+			})
+		}
+	}
+
+	for _, key := range z.dnskeys {
+		role := "ZSK"
+		if key.Flags&dns.SEP != 0 {
+			role = "KSK"
+		}
+
+		link.Keys = append(link.Keys, Key{
+			KeyTag:    key.KeyTag(),
+			Algorithm: dns.AlgorithmToString[key.Algorithm],
+			Flags:     key.Flags,
+			Role:      role,
+			Trusted:   matchesDS(key, z.ds) || matchesKey(key, z.trusted),
+		})
+	}
+
+	for _, sig := range z.sigs {
+		s := Signature{
+			Name:      sig.rrsig.Hdr.Name,
+			Type:      dns.TypeToString[sig.rrsig.TypeCovered],
+			KeyTag:    sig.rrsig.KeyTag,
+			Algorithm: dns.AlgorithmToString[sig.rrsig.Algorithm],
+			Inception: time.Unix(int64(sig.rrsig.Inception), 0).UTC(),
+			Expiry:    time.Unix(int64(sig.rrsig.Expiration), 0).UTC(),
+		}
+
+		s.Remaining = s.Expiry.Sub(now).Round(time.Second)
+
+		if sig.err != nil {
+			s.Error = sig.err.Error()
+		}
+
+		link.Signatures = append(link.Signatures, s)
+	}
+
+	return link
+}
+
+// checked is a signature that was checked with the keys of a zone.
+type checked struct {
+	rrsig *dns.RRSIG
+	err   error
+}
+
+// check verifies an RRset with the keys given, remembering the signatures checked.
+func (z *zone) check(set *rrset, keys []*dns.DNSKEY, now time.Time) (*dns.RRSIG, *dns.DNSKEY, error) {
+	sig, key, err := verify(set, keys, now)
+
+	if err == nil {
+		z.sigs = append(z.sigs, checked{sig, nil})
+	} else {
+		for _, s := range set.sigs {
+			if util.EqualNames(s.SignerName, z.name) {
+				z.sigs = append(z.sigs, checked{s, err})
+			}
+		}
+	}
+
+	return sig, key, err
+}
@@ -0,0 +1,333 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package dnssec
+
+import (
+	"strings"
+
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+)
+
+// Denial of existence proofs, see RFC 4035 section 5.4 and RFC 5155 section 8.
+
+// proof is what a set of NSEC or NSEC3 records proves.
+type proof int
+
+const (
+	// Nothing was proven
+	unproven proof = iota
+	// The denial is proven
+	proven
+	// The denial is covered by an NSEC3 opt-out span, so it is insecure
+	optOut
+)
+
+// proveNXDomain checks that the name given does not exist, and that no wildcard
+// could have matched it.
+func proveNXDomain(rrs []dns.RR, name string) proof {
+	nsecs, nsec3s := split(rrs)
+
+	if len(nsecs) > 0 {
+		cover := nsecCovering(nsecs, name)
+		if cover == nil {
+			return unproven
+		}
+
+		ce := nsecEncloser(cover, name)
+		if nsecMatching(nsecs, wildcard(ce)) != nil || nsecCovering(nsecs, wildcard(ce)) == nil {
+			return unproven
+		}
+
+		return proven
+	}
+
+	ce, nc := nsec3Encloser(nsec3s, name)
+	if ce == "" || nc == "" {
+		return unproven
+	}
+
+	cover := nsec3Covering(nsec3s, nc)
+	if cover == nil || nsec3Covering(nsec3s, wildcard(ce)) == nil {
+		return unproven
+	}
+
+	if cover.Flags&1 == 1 {
+		return optOut
+	}
+
+	return proven
+}
+
+// proveNoData checks that the name given exists, but not with the type given.
+func proveNoData(rrs []dns.RR, name string, qtype uint16) proof {
+	nsecs, nsec3s := split(rrs)
+
+	if len(nsecs) > 0 {
+		if match := nsecMatching(nsecs, name); match != nil {
+			if hasType(match.TypeBitMap, qtype) || hasType(match.TypeBitMap, dns.TypeCNAME) {
+				return unproven
+			}
+
+			return proven
+		}
+
+		// Wildcard NODATA
+		cover := nsecCovering(nsecs, name)
+		if cover == nil {
+			return unproven
+		}
+
+		match := nsecMatching(nsecs, wildcard(nsecEncloser(cover, name)))
+		if match == nil || hasType(match.TypeBitMap, qtype) || hasType(match.TypeBitMap, dns.TypeCNAME) {
+			return unproven
+		}
+
+		return proven
+	}
+
+	if match := nsec3Matching(nsec3s, name); match != nil {
+		if hasType(match.TypeBitMap, qtype) || hasType(match.TypeBitMap, dns.TypeCNAME) {
+			return unproven
+		}
+
+		return proven
+	}
+
+	ce, nc := nsec3Encloser(nsec3s, name)
+	if ce == "" || nc == "" {
+		return unproven
+	}
+
+	cover := nsec3Covering(nsec3s, nc)
+	if cover == nil {
+		return unproven
+	}
+
+	// A DS query for an unsigned delegation in an opt-out span
+	if qtype == dns.TypeDS && cover.Flags&1 == 1 {
+		return optOut
+	}
+
+	// Wildcard NODATA
+	match := nsec3Matching(nsec3s, wildcard(ce))
+	if match == nil || hasType(match.TypeBitMap, qtype) || hasType(match.TypeBitMap, dns.TypeCNAME) {
+		return unproven
+	}
+
+	return proven
+}
+
+// proveWildcard checks that a wildcard expansion was valid, by proving that
+// the name queried does not exist.
+func proveWildcard(rrs []dns.RR, name string) bool {
+	nsecs, nsec3s := split(rrs)
+
+	if len(nsecs) > 0 {
+		return nsecCovering(nsecs, name) != nil
+	}
+
+	_, nc := nsec3Encloser(nsec3s, name)
+	if nc == "" {
+		// The closest encloser is not given with wildcard answers, so find the next closer
+		// from the longest name that is covered
+		for _, anc := range ancestors(name) {
+			if nsec3Covering(nsec3s, anc) != nil {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return nsec3Covering(nsec3s, nc) != nil
+}
+
+// delegation is what a denial proves about a name that has no DS records.
+type delegation int
+
+const (
+	// Nothing was proven
+	noProof delegation = iota
+	// The name is not a zone cut
+	noCut
+	// The name is an unsigned zone cut
+	unsignedCut
+)
+
+// proveNoDS checks what the lack of a DS record at a name means.
+func proveNoDS(rrs []dns.RR, name string) delegation {
+	nsecs, nsec3s := split(rrs)
+
+	var bitmap []uint16
+
+	if match := nsecMatching(nsecs, name); match != nil {
+		bitmap = match.TypeBitMap
+	} else if match := nsec3Matching(nsec3s, name); match != nil {
+		bitmap = match.TypeBitMap
+	}
+
+	if bitmap != nil {
+		if hasType(bitmap, dns.TypeDS) {
+			return noProof
+		}
+
+		if hasType(bitmap, dns.TypeNS) && !hasType(bitmap, dns.TypeSOA) {
+			return unsignedCut
+		}
+
+		return noCut
+	}
+
+	// Empty non-terminals do not always have their own records
+	if nsecCovering(nsecs, name) != nil {
+		return noCut
+	}
+
+	switch proveNoData(rrs, name, dns.TypeDS) {
+	case optOut:
+		return unsignedCut
+	case proven:
+		return noCut
+	case unproven:
+	}
+
+	return noProof
+}
+
+func split(rrs []dns.RR) (nsecs []*dns.NSEC, nsec3s []*dns.NSEC3) {
+	for _, rr := range rrs {
+		switch rr := rr.(type) {
+		case *dns.NSEC:
+			nsecs = append(nsecs, rr)
+		case *dns.NSEC3:
+			nsec3s = append(nsec3s, rr)
+		}
+	}
+
+	return
+}
+
+func nsecMatching(nsecs []*dns.NSEC, name string) *dns.NSEC {
+	for _, nsec := range nsecs {
+		if util.EqualNames(nsec.Hdr.Name, name) {
+			return nsec
+		}
+	}
+
+	return nil
+}
+
+func nsecCovering(nsecs []*dns.NSEC, name string) *dns.NSEC {
+	for _, nsec := range nsecs {
+		owner, next := nsec.Hdr.Name, nsec.NextDomain
+
+		if compare(owner, next) < 0 {
+			if compare(owner, name) < 0 && compare(name, next) < 0 {
+				return nsec
+			}
+		} else if compare(owner, name) < 0 || compare(name, next) < 0 {
+			// The last NSEC in a zone wraps around to the apex
+			return nsec
+		}
+	}
+
+	return nil
+}
+
+// nsecEncloser finds the closest encloser of a name from the NSEC covering it.
+func nsecEncloser(cover *dns.NSEC, name string) string {
+	for _, anc := range ancestors(name) {
+		if dns.IsSubDomain(anc, cover.Hdr.Name) || dns.IsSubDomain(anc, cover.NextDomain) {
+			return anc
+		}
+	}
+
+	return "."
+}
+
+func nsec3Matching(nsec3s []*dns.NSEC3, name string) *dns.NSEC3 {
+	for _, nsec3 := range nsec3s {
+		if nsec3.Match(name) {
+			return nsec3
+		}
+	}
+
+	return nil
+}
+
+func nsec3Covering(nsec3s []*dns.NSEC3, name string) *dns.NSEC3 {
+	for _, nsec3 := range nsec3s {
+		if nsec3.Cover(name) {
+			return nsec3
+		}
+	}
+
+	return nil
+}
+
+// nsec3Encloser finds the closest encloser and next closer name of a name, see RFC 5155 section 8.3.
+func nsec3Encloser(nsec3s []*dns.NSEC3, name string) (ce, nc string) {
+	if len(nsec3s) == 0 || nsec3Matching(nsec3s, name) != nil {
+		return "", ""
+	}
+
+	nc = name
+
+	for _, anc := range ancestors(name) {
+		if nsec3Matching(nsec3s, anc) != nil {
+			return anc, nc
+		}
+
+		nc = anc
+	}
+
+	return "", ""
+}
+
+// ancestors returns every parent of a name, closest first.
+func ancestors(name string) (ret []string) {
+	name = dns.Fqdn(name)
+
+	for off, end := dns.NextLabel(name, 0); !end; off, end = dns.NextLabel(name, off) {
+		ret = append(ret, name[off:])
+	}
+
+	if name != "." {
+		ret = append(ret, ".")
+	}
+
+	return ret
+}
+
+func wildcard(name string) string {
+	if name == "." {
+		return "*."
+	}
+
+	return "*." + name
+}
+
+func hasType(bitmap []uint16, t uint16) bool {
+	for _, bit := range bitmap {
+		if bit == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compare compares names in canonical DNS order, see RFC 4034 section 6.1.
+func compare(a, b string) int {
+	la := dns.SplitDomainName(strings.ToLower(a))
+	lb := dns.SplitDomainName(strings.ToLower(b))
+
+	for i := 1; i <= len(la) && i <= len(lb); i++ {
+		if c := strings.Compare(la[len(la)-i], lb[len(lb)-i]); c != 0 {
+			return c
+		}
+	}
+
+	return len(la) - len(lb)
+}
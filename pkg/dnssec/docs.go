@@ -0,0 +1,7 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+/*
+Package dnssec validates DNSSEC signed responses, building a chain of trust
+from a trust anchor down to the zone that signed the response.
+*/
+package dnssec
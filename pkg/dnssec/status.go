@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package dnssec
+
+import (
+	"errors"
+	"fmt"
+
+	"dns.froth.zone/awl/pkg/util"
+)
+
+// Status is the outcome of validating a response, as defined in RFC 4033 section 5.
+type Status = util.DNSSECStatus
+
+const (
+	// Indeterminate means that validation could not be completed.
+	Indeterminate = util.Indeterminate
+	// Secure means that there is a full chain of trust to the response.
+	Secure = util.Secure
+	// Insecure means that there is proof that the response is not signed.
+	Insecure = util.Insecure
+	// Bogus means that the response should have been signed, but it could not be validated.
+	Bogus = util.Bogus
+)
+
+// severity orders statuses from best to worst.
+func severity(s Status) int {
+	switch s {
+	case Secure:
+		return 0
+	case Insecure:
+		return 1
+	case Indeterminate:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// Result is the result of validating a response.
+type Result = util.DNSSEC
+
+// worse returns the result with the worse status, preferring a if they are the same.
+func worse(a, b Result) Result {
+	if severity(b.Status) > severity(a.Status) {
+		return b
+	}
+
+	return a
+}
+
+var (
+	errNoAnchors  = errors.New("no DS or DNSKEY records found")
+	errNoSigs     = errors.New("no signatures")
+	errNoKey      = errors.New("no matching key for signature")
+	errNotSubzone = errors.New("signer is not a parent of the signed name")
+)
+
+type errExpired struct {
+	name string
+	tag  uint16
+}
+
+func (e *errExpired) Error() string {
+	return fmt.Sprintf("signature by key %d of %s is outside of its validity period", e.tag, e.name)
+}
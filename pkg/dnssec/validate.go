@@ -0,0 +1,565 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package dnssec
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+)
+
+// Exchanger makes a DNS query with the DO bit set, returning the response.
+type Exchanger func(name string, qtype uint16) (*dns.Msg, error)
+
+// zone is a zone in the chain of trust.
+type zone struct {
+	name   string
+	status Status
+	reason string
+	// Validated keys of the zone
+	keys []*dns.DNSKEY
+
+	// DS records and trusted keys the zone was validated with
+	ds      []dns.RR
+	trusted []*dns.DNSKEY
+	anchor  bool
+	// Every DNSKEY record of the zone, validated or not
+	dnskeys []*dns.DNSKEY
+	// Signatures checked with the keys of the zone
+	sigs []checked
+}
+
+// Validator validates responses, fetching the DNSKEY and DS records needed.
+type Validator struct {
+	exchange Exchanger
+	anchors  []dns.RR
+	// Zones that have been looked at, nil for names that are not zone cuts
+	zones map[string]*zone
+	now   time.Time
+}
+
+// New creates a new validator, trusting the anchors given.
+func New(anchors []dns.RR, exchange Exchanger) *Validator {
+	return &Validator{
+		exchange: exchange,
+		anchors:  anchors,
+		zones:    make(map[string]*zone),
+		now:      time.Now(),
+	}
+}
+
+// Validate validates a response.
+func (v *Validator) Validate(msg *dns.Msg) Result {
+	if msg == nil || len(msg.Question) == 0 {
+		return Result{Status: Indeterminate, Reason: "no question in response"}
+	}
+
+	if msg.Rcode != dns.RcodeSuccess && msg.Rcode != dns.RcodeNameError {
+		return Result{Status: Indeterminate, Reason: dns.RcodeToString[msg.Rcode] + " response"}
+	}
+
+	q := msg.Question[0]
+	name := strings.ToLower(q.Name)
+
+	var (
+		res      = Result{Status: Secure}
+		answered bool
+	)
+
+	sets := group(msg.Answer)
+
+	for _, set := range sets {
+		// CNAMEs synthesized from a DNAME are never signed
+		if set.rrtype == dns.TypeCNAME && len(set.sigs) == 0 && synthesized(sets, set.name) {
+			continue
+		}
+
+		res = worse(res, v.positive(set, msg.Ns))
+
+		// Follow CNAMEs to see what the final answer should be
+		switch {
+		case util.EqualNames(set.name, name) && set.rrtype == dns.TypeCNAME && q.Qtype != dns.TypeCNAME:
+			name = strings.ToLower(set.rrs[0].(*dns.CNAME).Target)
+		case util.EqualNames(set.name, name) && (set.rrtype == q.Qtype || q.Qtype == dns.TypeANY):
+			answered = true
+		}
+	}
+
+	if res.Reason == "" && answered {
+		res.Reason = "every RRset in the answer is signed"
+	}
+
+	if !answered {
+		res = worse(res, v.negative(msg, name, q.Qtype))
+	}
+
+	return res
+}
+
+// positive validates an RRset in the answer section.
+func (v *Validator) positive(set *rrset, authority []dns.RR) Result {
+	if len(set.sigs) == 0 {
+		z := v.walk(set.name, false)
+
+		switch z.status {
+		case Secure:
+			return Result{Status: Bogus, Reason: fmt.Sprintf("%s %s is not signed", set.name, dns.TypeToString[set.rrtype])}
+		default:
+			return Result{Status: z.status, Reason: z.reason}
+		}
+	}
+
+	var err error = errNoSigs
+
+	for _, sig := range set.sigs {
+		if !dns.IsSubDomain(sig.SignerName, set.name) {
+			err = errNotSubzone
+
+			continue
+		}
+
+		z := v.walk(sig.SignerName, true)
+		if z.status != Secure {
+			return Result{Status: z.status, Reason: z.reason}
+		}
+
+		used, key, verr := z.check(set, z.keys, v.now)
+		if verr != nil {
+			err = verr
+
+			continue
+		}
+
+		// Wildcard expansions need proof that the name itself does not exist
+		if int(used.Labels) < labels(set.name) {
+			signed, _ := v.authority(authority, z)
+			if !proveWildcard(signed, set.name) {
+				return Result{Status: Bogus, Reason: fmt.Sprintf("%s %s is a wildcard expansion without proof", set.name, dns.TypeToString[set.rrtype])}
+			}
+		}
+
+		return Result{Status: Secure, Reason: fmt.Sprintf("%s %s signed by %s with key %d", set.name, dns.TypeToString[set.rrtype], z.name, key.KeyTag())}
+	}
+
+	return Result{Status: Bogus, Reason: fmt.Sprintf("%s %s: %v", set.name, dns.TypeToString[set.rrtype], err)}
+}
+
+// negative validates a NXDOMAIN or NODATA response for name.
+func (v *Validator) negative(msg *dns.Msg, name string, qtype uint16) Result {
+	signer := ""
+
+	for _, set := range group(msg.Ns) {
+		for _, sig := range set.sigs {
+			if dns.IsSubDomain(sig.SignerName, name) {
+				signer = sig.SignerName
+			}
+		}
+	}
+
+	if signer == "" {
+		z := v.walk(name, false)
+		if z.status == Secure {
+			return Result{Status: Bogus, Reason: "negative response for " + name + " is not signed"}
+		}
+
+		return Result{Status: z.status, Reason: z.reason}
+	}
+
+	z := v.walk(signer, true)
+	if z.status != Secure {
+		return Result{Status: z.status, Reason: z.reason}
+	}
+
+	signed, err := v.authority(msg.Ns, z)
+	if err != nil {
+		return Result{Status: Bogus, Reason: err.Error()}
+	}
+
+	var (
+		p    proof
+		kind string
+	)
+
+	if msg.Rcode == dns.RcodeNameError {
+		p, kind = proveNXDomain(signed, name), "NXDOMAIN"
+	} else {
+		p, kind = proveNoData(signed, name, qtype), "NODATA"
+	}
+
+	switch p {
+	case proven:
+		return Result{Status: Secure, Reason: fmt.Sprintf("%s for %s proven by %s", kind, name, denialType(signed))}
+	case optOut:
+		return Result{Status: Insecure, Reason: fmt.Sprintf("%s for %s is in an NSEC3 opt-out span", kind, name)}
+	default:
+		return Result{Status: Bogus, Reason: fmt.Sprintf("no valid proof of %s for %s", kind, name)}
+	}
+}
+
+// authority validates the NSEC, NSEC3 and SOA records in an authority section,
+// returning the NSEC and NSEC3 records.
+func (v *Validator) authority(rrs []dns.RR, z *zone) (signed []dns.RR, err error) {
+	for _, set := range group(rrs) {
+		switch set.rrtype {
+		case dns.TypeNSEC, dns.TypeNSEC3, dns.TypeSOA:
+		default:
+			continue
+		}
+
+		if _, _, err := z.check(set, z.keys, v.now); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", set.name, dns.TypeToString[set.rrtype], err)
+		}
+
+		if set.rrtype != dns.TypeSOA {
+			signed = append(signed, set.rrs...)
+		}
+	}
+
+	return signed, nil
+}
+
+// walk builds the chain of trust from the closest trust anchor down to target.
+// When target is known to be a zone apex, it must either be signed or proven to be unsigned.
+func (v *Validator) walk(target string, apex bool) *zone {
+	target = strings.ToLower(dns.Fqdn(target))
+
+	anchor := anchorFor(v.anchors, target)
+	if anchor == "" {
+		return &zone{name: target, status: Indeterminate, reason: "no trust anchor for " + target}
+	}
+
+	cur, ok := v.zones[anchor]
+	if !ok {
+		cur = v.keys(anchor, anchorDS(v.anchors, anchor), anchorKeys(v.anchors, anchor))
+		cur.anchor = true
+		v.zones[anchor] = cur
+	}
+
+	for i := dns.CountLabel(anchor) + 1; i <= dns.CountLabel(target); i++ {
+		if cur.status != Secure {
+			return cur
+		}
+
+		name := lastLabels(target, i)
+		mustCut := apex && name == target
+
+		next, ok := v.zones[name]
+		if !ok || (next == nil && mustCut) {
+			next = v.delegation(cur, name, mustCut)
+			v.zones[name] = next
+		}
+
+		if next != nil {
+			cur = next
+		}
+	}
+
+	return cur
+}
+
+// delegation checks for a secure delegation from parent to name, returning nil
+// if name is not a zone cut.
+func (v *Validator) delegation(parent *zone, name string, mustCut bool) *zone {
+	msg, err := v.exchange(name, dns.TypeDS)
+	if err != nil {
+		return &zone{name: name, status: Indeterminate, reason: fmt.Sprintf("DS query for %s: %v", name, err)}
+	}
+
+	for _, set := range group(msg.Answer) {
+		if set.rrtype != dns.TypeDS || !util.EqualNames(set.name, name) {
+			continue
+		}
+
+		if _, _, err := parent.check(set, parent.keys, v.now); err != nil {
+			return &zone{name: name, status: Bogus, reason: fmt.Sprintf("DS for %s: %v", name, err)}
+		}
+
+		return v.keys(name, set.rrs, nil)
+	}
+
+	signed, err := v.authority(msg.Ns, parent)
+	if err != nil {
+		return &zone{name: name, status: Bogus, reason: fmt.Sprintf("no DS for %s: %v", name, err)}
+	}
+
+	switch proveNoDS(signed, name) {
+	case unsignedCut:
+		return &zone{name: name, status: Insecure, reason: "insecure delegation to " + name}
+	case noCut:
+		if !mustCut {
+			return nil
+		}
+
+		return &zone{name: name, status: Bogus, reason: name + " signs records, but is not a zone cut"}
+	default:
+		return &zone{name: name, status: Bogus, reason: "no valid proof of a missing DS for " + name}
+	}
+}
+
+// keys fetches and validates the DNSKEY records of a zone, using either DS records
+// or trusted DNSKEY records.
+func (v *Validator) keys(name string, ds []dns.RR, trusted []*dns.DNSKEY) *zone {
+	z := &zone{name: name, status: Bogus, ds: ds, trusted: trusted}
+
+	if !supported(ds, trusted) {
+		z.status = Insecure
+		z.reason = "no supported DNSSEC algorithms for " + name
+
+		return z
+	}
+
+	msg, err := v.exchange(name, dns.TypeDNSKEY)
+	if err != nil {
+		z.status = Indeterminate
+		z.reason = fmt.Sprintf("DNSKEY query for %s: %v", name, err)
+
+		return z
+	}
+
+	var set *rrset
+
+	for _, s := range group(msg.Answer) {
+		if s.rrtype == dns.TypeDNSKEY && util.EqualNames(s.name, name) {
+			set = s
+		}
+	}
+
+	if set == nil {
+		z.reason = "no DNSKEY records for " + name
+
+		return z
+	}
+
+	var (
+		keys []*dns.DNSKEY
+		ksks []*dns.DNSKEY
+	)
+
+	for _, rr := range set.rrs {
+		key, ok := rr.(*dns.DNSKEY)
+		if !ok {
+			continue
+		}
+
+		keys = append(keys, key)
+		z.dnskeys = append(z.dnskeys, key)
+
+		if matchesDS(key, ds) || matchesKey(key, trusted) {
+			ksks = append(ksks, key)
+		}
+	}
+
+	if len(ksks) == 0 {
+		z.reason = "no DNSKEY of " + name + " matches its DS records"
+
+		return z
+	}
+
+	if _, _, err := z.check(set, ksks, v.now); err != nil {
+		z.reason = fmt.Sprintf("DNSKEY for %s: %v", name, err)
+
+		return z
+	}
+
+	z.status = Secure
+	z.keys = keys
+
+	return z
+}
+
+// verify verifies an RRset with any of the keys given.
+func verify(set *rrset, keys []*dns.DNSKEY, now time.Time) (*dns.RRSIG, *dns.DNSKEY, error) {
+	var err error = errNoSigs
+	if len(set.sigs) > 0 {
+		err = errNoKey
+	}
+
+	for _, sig := range set.sigs {
+		for _, key := range keys {
+			if key.KeyTag() != sig.KeyTag || key.Algorithm != sig.Algorithm || key.Flags&dns.ZONE == 0 {
+				continue
+			}
+
+			if !sig.ValidityPeriod(now) {
+				err = &errExpired{sig.SignerName, sig.KeyTag}
+
+				continue
+			}
+
+			if e := sig.Verify(key, set.rrs); e != nil {
+				err = fmt.Errorf("key %d: %w", sig.KeyTag, e)
+
+				continue
+			}
+
+			return sig, key, nil
+		}
+	}
+
+	return nil, nil, err
+}
+
+// matchesDS checks if a key is referenced by any of the DS records given.
+func matchesDS(key *dns.DNSKEY, ds []dns.RR) bool {
+	for _, rr := range ds {
+		ds, ok := rr.(*dns.DS)
+		if !ok || ds.KeyTag != key.KeyTag() || ds.Algorithm != key.Algorithm {
+			continue
+		}
+
+		if digest := key.ToDS(ds.DigestType); digest != nil && strings.EqualFold(digest.Digest, ds.Digest) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesKey(key *dns.DNSKEY, trusted []*dns.DNSKEY) bool {
+	for _, t := range trusted {
+		if t.Algorithm == key.Algorithm && t.Flags == key.Flags && t.PublicKey == key.PublicKey {
+			return true
+		}
+	}
+
+	return false
+}
+
+// supported checks that at least one of the DS or DNSKEY records use an
+// algorithm that can be validated.
+func supported(ds []dns.RR, keys []*dns.DNSKEY) bool {
+	for _, key := range keys {
+		if supportedAlgorithm(key.Algorithm) {
+			return true
+		}
+	}
+
+	for _, rr := range ds {
+		if ds, ok := rr.(*dns.DS); ok && supportedAlgorithm(ds.Algorithm) && ds.DigestType != dns.GOST94 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func supportedAlgorithm(alg uint8) bool {
+	switch alg {
+	case dns.RSASHA1, dns.RSASHA1NSEC3SHA1, dns.RSASHA256, dns.RSASHA512,
+		dns.ECDSAP256SHA256, dns.ECDSAP384SHA384, dns.ED25519:
+		return true
+	default:
+		return false
+	}
+}
+
+func anchorDS(anchors []dns.RR, name string) (ds []dns.RR) {
+	for _, rr := range anchors {
+		if _, ok := rr.(*dns.DS); ok && util.EqualNames(rr.Header().Name, name) {
+			ds = append(ds, rr)
+		}
+	}
+
+	return ds
+}
+
+func anchorKeys(anchors []dns.RR, name string) (keys []*dns.DNSKEY) {
+	for _, rr := range anchors {
+		if key, ok := rr.(*dns.DNSKEY); ok && util.EqualNames(rr.Header().Name, name) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// denialType returns the type of denial of existence records used.
+func denialType(rrs []dns.RR) string {
+	if nsecs, _ := split(rrs); len(nsecs) > 0 {
+		return "NSEC"
+	}
+
+	return "NSEC3"
+}
+
+// synthesized checks if a name is below a DNAME in the RRsets given.
+func synthesized(sets []*rrset, name string) bool {
+	for _, set := range sets {
+		if set.rrtype == dns.TypeDNAME && len(set.sigs) > 0 && !util.EqualNames(set.name, name) && dns.IsSubDomain(set.name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// labels counts the labels of a name, leaving out a leading wildcard label as RFC 4035 section 5.3.2 does.
+func labels(name string) int {
+	n := dns.CountLabel(name)
+	if strings.HasPrefix(name, "*.") {
+		n--
+	}
+
+	return n
+}
+
+// lastLabels returns the last n labels of a name.
+func lastLabels(name string, n int) string {
+	labels := dns.SplitDomainName(name)
+
+	return dns.Fqdn(strings.Join(labels[len(labels)-n:], "."))
+}
+
+// rrset is a set of records with the same name, type and class, along with their signatures.
+type rrset struct {
+	name   string
+	rrtype uint16
+	class  uint16
+	rrs    []dns.RR
+	sigs   []*dns.RRSIG
+}
+
+// group groups records into RRsets.
+func group(rrs []dns.RR) (sets []*rrset) {
+	find := func(name string, rrtype, class uint16) *rrset {
+		for _, set := range sets {
+			if set.rrtype == rrtype && set.class == class && util.EqualNames(set.name, name) {
+				return set
+			}
+		}
+
+		set := &rrset{name: strings.ToLower(name), rrtype: rrtype, class: class}
+		sets = append(sets, set)
+
+		return set
+	}
+
+	for _, rr := range rrs {
+		hdr := rr.Header()
+
+		switch rr := rr.(type) {
+		case *dns.OPT:
+			continue
+		case *dns.RRSIG:
+			set := find(hdr.Name, rr.TypeCovered, hdr.Class)
+			set.sigs = append(set.sigs, rr)
+		default:
+			set := find(hdr.Name, hdr.Rrtype, hdr.Class)
+			set.rrs = append(set.rrs, rr)
+		}
+	}
+
+	// Signatures without records are of no use
+	ret := sets[:0]
+
+	for _, set := range sets {
+		if len(set.rrs) > 0 {
+			ret = append(ret, set)
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,327 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package dnssec_test
+
+import (
+	"crypto"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"dns.froth.zone/awl/pkg/dnssec"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+// signer is a zone with a single combined signing key.
+type signer struct {
+	key  *dns.DNSKEY
+	priv crypto.Signer
+}
+
+func newSigner(t *testing.T, zone string) *signer {
+	t.Helper()
+
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: zone, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     dns.ZONE | dns.SEP,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+
+	priv, err := key.Generate(256)
+	assert.NilError(t, err)
+
+	return &signer{key, priv.(crypto.Signer)}
+}
+
+// sign returns the records given along with their signature.
+func (s *signer) sign(t *testing.T, expiry time.Duration, rrs ...string) []dns.RR {
+	t.Helper()
+
+	set := make([]dns.RR, 0, len(rrs)+1)
+
+	for _, str := range rrs {
+		rr, err := dns.NewRR(str)
+		assert.NilError(t, err)
+
+		set = append(set, rr)
+	}
+
+	sig := &dns.RRSIG{
+		Algorithm:  s.key.Algorithm,
+		KeyTag:     s.key.KeyTag(),
+		SignerName: s.key.Hdr.Name,
+		Inception:  uint32(time.Now().Add(-time.Hour).Unix()),
+		Expiration: uint32(time.Now().Add(expiry).Unix()),
+	}
+
+	assert.NilError(t, sig.Sign(s.priv, set))
+
+	return append(set, sig)
+}
+
+func (s *signer) keys(t *testing.T) []dns.RR {
+	t.Helper()
+
+	set := []dns.RR{s.key}
+	sig := &dns.RRSIG{
+		Algorithm:  s.key.Algorithm,
+		KeyTag:     s.key.KeyTag(),
+		SignerName: s.key.Hdr.Name,
+		Inception:  uint32(time.Now().Add(-time.Hour).Unix()),
+		Expiration: uint32(time.Now().Add(time.Hour).Unix()),
+	}
+
+	assert.NilError(t, sig.Sign(s.priv, set))
+
+	return append(set, sig)
+}
+
+// fixture is a small signed hierarchy:
+//
+//	.           signed, trusted directly
+//	example.    signed, with a DS in the root
+//	insecure.   unsigned delegation
+type fixture struct {
+	root, example *signer
+	answers       map[string]*dns.Msg
+}
+
+func key(name string, qtype uint16) string {
+	return strings.ToLower(name) + "/" + dns.TypeToString[qtype]
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+
+	f := &fixture{
+		root:    newSigner(t, "."),
+		example: newSigner(t, "example."),
+		answers: make(map[string]*dns.Msg),
+	}
+
+	ds := f.example.key.ToDS(dns.SHA256)
+
+	f.add(".", dns.TypeDNSKEY, f.root.keys(t), nil, dns.RcodeSuccess)
+	f.add("example.", dns.TypeDNSKEY, f.example.keys(t), nil, dns.RcodeSuccess)
+	f.add("example.", dns.TypeDS, f.root.sign(t, time.Hour, ds.String()), nil, dns.RcodeSuccess)
+	f.add("insecure.", dns.TypeDS, nil, f.root.sign(t, time.Hour,
+		"insecure. 3600 IN NSEC zzz. NS RRSIG NSEC"), dns.RcodeSuccess)
+	f.add("www.example.", dns.TypeDS, nil, f.example.sign(t, time.Hour,
+		"www.example. 300 IN NSEC example. A RRSIG NSEC"), dns.RcodeSuccess)
+
+	return f
+}
+
+func (f *fixture) add(name string, qtype uint16, answer, ns []dns.RR, rcode int) *dns.Msg {
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, qtype)
+	msg.Response = true
+	msg.Rcode = rcode
+	msg.Answer = answer
+	msg.Ns = ns
+	f.answers[key(name, qtype)] = msg
+
+	return msg
+}
+
+var errNotFound = errors.New("not found")
+
+func (f *fixture) exchange(name string, qtype uint16) (*dns.Msg, error) {
+	if msg, ok := f.answers[key(name, qtype)]; ok {
+		return msg, nil
+	}
+
+	return nil, errNotFound
+}
+
+func (f *fixture) validate(msg *dns.Msg) dnssec.Result {
+	return dnssec.New([]dns.RR{f.root.key}, f.exchange).Validate(msg)
+}
+
+func TestSecure(t *testing.T) {
+	t.Parallel()
+
+	f := newFixture(t)
+	msg := f.add("www.example.", dns.TypeA,
+		f.example.sign(t, time.Hour, "www.example. 300 IN A 192.0.2.1"), nil, dns.RcodeSuccess)
+
+	res := f.validate(msg)
+	assert.Equal(t, res.Status, dnssec.Secure, res.Reason)
+
+	// The wildcard itself, which is not an expansion of it
+	msg = f.add("*.example.", dns.TypeA,
+		f.example.sign(t, time.Hour, "*.example. 300 IN A 192.0.2.1"), nil, dns.RcodeSuccess)
+
+	res = f.validate(msg)
+	assert.Equal(t, res.Status, dnssec.Secure, res.Reason)
+}
+
+func TestBogus(t *testing.T) {
+	t.Parallel()
+
+	f := newFixture(t)
+
+	// Tampered
+	answer := f.example.sign(t, time.Hour, "www.example. 300 IN A 192.0.2.1")
+	answer[0].(*dns.A).A[3] = 2
+	msg := f.add("www.example.", dns.TypeA, answer, nil, dns.RcodeSuccess)
+
+	res := f.validate(msg)
+	assert.Equal(t, res.Status, dnssec.Bogus, res.Reason)
+
+	// Expired
+	answer = f.example.sign(t, -time.Minute, "www.example. 300 IN A 192.0.2.1")
+	msg = f.add("www.example.", dns.TypeA, answer, nil, dns.RcodeSuccess)
+
+	res = f.validate(msg)
+	assert.Equal(t, res.Status, dnssec.Bogus, res.Reason)
+	assert.ErrorContains(t, errors.New(res.Reason), "validity period")
+
+	// Stripped
+	rr, _ := dns.NewRR("www.example. 300 IN A 192.0.2.1")
+	msg = f.add("www.example.", dns.TypeA, []dns.RR{rr}, nil, dns.RcodeSuccess)
+
+	res = f.validate(msg)
+	assert.Equal(t, res.Status, dnssec.Bogus, res.Reason)
+}
+
+func TestInsecure(t *testing.T) {
+	t.Parallel()
+
+	f := newFixture(t)
+
+	rr, _ := dns.NewRR("host.insecure. 300 IN A 192.0.2.1")
+	msg := f.add("host.insecure.", dns.TypeA, []dns.RR{rr}, nil, dns.RcodeSuccess)
+
+	res := f.validate(msg)
+	assert.Equal(t, res.Status, dnssec.Insecure, res.Reason)
+}
+
+func TestNXDomain(t *testing.T) {
+	t.Parallel()
+
+	f := newFixture(t)
+
+	var ns []dns.RR
+	ns = append(ns, f.example.sign(t, time.Hour,
+		"example. 300 IN SOA ns.example. admin.example. 1 7200 3600 1209600 300")...)
+	ns = append(ns, f.example.sign(t, time.Hour,
+		"example. 300 IN NSEC www.example. NS SOA RRSIG NSEC DNSKEY")...)
+
+	msg := f.add("nope.example.", dns.TypeA, nil, ns, dns.RcodeNameError)
+	res := f.validate(msg)
+	assert.Equal(t, res.Status, dnssec.Secure, res.Reason)
+
+	// Claiming www.example. does not exist, when the NSEC says otherwise
+	msg = f.add("www.example.", dns.TypeA, nil, ns, dns.RcodeNameError)
+	res = f.validate(msg)
+	assert.Equal(t, res.Status, dnssec.Bogus, res.Reason)
+}
+
+func TestNoData(t *testing.T) {
+	t.Parallel()
+
+	f := newFixture(t)
+
+	var ns []dns.RR
+	ns = append(ns, f.example.sign(t, time.Hour,
+		"example. 300 IN SOA ns.example. admin.example. 1 7200 3600 1209600 300")...)
+	ns = append(ns, f.example.sign(t, time.Hour,
+		"www.example. 300 IN NSEC example. A RRSIG NSEC")...)
+
+	msg := f.add("www.example.", dns.TypeTXT, nil, ns, dns.RcodeSuccess)
+	res := f.validate(msg)
+	assert.Equal(t, res.Status, dnssec.Secure, res.Reason)
+
+	msg = f.add("www.example.", dns.TypeA, nil, ns, dns.RcodeSuccess)
+	res = f.validate(msg)
+	assert.Equal(t, res.Status, dnssec.Bogus, res.Reason)
+}
+
+func TestNoAnchor(t *testing.T) {
+	t.Parallel()
+
+	f := newFixture(t)
+	msg := f.add("www.example.", dns.TypeA,
+		f.example.sign(t, time.Hour, "www.example. 300 IN A 192.0.2.1"), nil, dns.RcodeSuccess)
+
+	anchors, err := dnssec.ParseAnchors(strings.NewReader(f.example.key.String()), "test")
+	assert.NilError(t, err)
+
+	// Trusting example. directly
+	res := dnssec.New(anchors, f.exchange).Validate(msg)
+	assert.Equal(t, res.Status, dnssec.Secure, res.Reason)
+
+	// Trusting the real root
+	res = dnssec.New(dnssec.RootAnchors(), f.exchange).Validate(msg)
+	assert.Equal(t, res.Status, dnssec.Bogus, res.Reason)
+}
+
+func TestNSEC3(t *testing.T) {
+	t.Parallel()
+
+	f := newFixture(t)
+
+	apex := strings.ToLower(dns.HashName("example.", dns.SHA1, 0, ""))
+	www := strings.ToLower(dns.HashName("www.example.", dns.SHA1, 0, ""))
+
+	var ns []dns.RR
+	ns = append(ns, f.example.sign(t, time.Hour,
+		"example. 300 IN SOA ns.example. admin.example. 1 7200 3600 1209600 300")...)
+	ns = append(ns, f.example.sign(t, time.Hour,
+		apex+".example. 300 IN NSEC3 1 0 0 - "+strings.ToUpper(www)+" NS SOA RRSIG DNSKEY NSEC3PARAM")...)
+	ns = append(ns, f.example.sign(t, time.Hour,
+		www+".example. 300 IN NSEC3 1 0 0 - "+strings.ToUpper(apex)+" A RRSIG")...)
+
+	msg := f.add("nope.example.", dns.TypeA, nil, ns, dns.RcodeNameError)
+	res := f.validate(msg)
+	assert.Equal(t, res.Status, dnssec.Secure, res.Reason)
+
+	msg = f.add("www.example.", dns.TypeTXT, nil, ns, dns.RcodeSuccess)
+	res = f.validate(msg)
+	assert.Equal(t, res.Status, dnssec.Secure, res.Reason)
+
+	msg = f.add("www.example.", dns.TypeA, nil, ns, dns.RcodeSuccess)
+	res = f.validate(msg)
+	assert.Equal(t, res.Status, dnssec.Bogus, res.Reason)
+}
+
+func TestChain(t *testing.T) {
+	t.Parallel()
+
+	f := newFixture(t)
+	msg := f.add("www.example.", dns.TypeA,
+		f.example.sign(t, time.Hour, "www.example. 300 IN A 192.0.2.1"), nil, dns.RcodeSuccess)
+
+	validator := dnssec.New([]dns.RR{f.root.key}, f.exchange)
+	res := validator.Validate(msg)
+	assert.Equal(t, res.Status, dnssec.Secure, res.Reason)
+
+	chain := validator.Chain()
+	assert.Equal(t, len(chain), 1)
+
+	root := chain[0]
+	assert.Equal(t, root.Zone, ".")
+	assert.Equal(t, root.Keys[0].KeyTag, f.root.key.KeyTag())
+	assert.Assert(t, root.Keys[0].Trusted)
+	// DNSKEY, then the DS of example.
+	assert.Equal(t, len(root.Signatures), 2)
+	assert.Equal(t, root.Signatures[1].Type, "DS")
+
+	assert.Equal(t, len(root.Children), 1)
+
+	example := root.Children[0]
+	assert.Equal(t, example.Zone, "example.")
+	assert.Equal(t, example.Status, dnssec.Secure)
+	assert.Equal(t, example.DS[0].KeyTag, f.example.key.KeyTag())
+	assert.Equal(t, example.Keys[0].Role, "KSK")
+
+	sig := example.Signatures[len(example.Signatures)-1]
+	assert.Equal(t, sig.Name, "www.example.")
+	assert.Equal(t, sig.Type, "A")
+	assert.Equal(t, sig.Error, "")
+	assert.Assert(t, sig.Remaining > 0 && sig.Remaining <= time.Hour)
+}
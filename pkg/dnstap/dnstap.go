@@ -0,0 +1,232 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package dnstap
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"dns.froth.zone/awl/pkg/util"
+)
+
+// How long to wait for a socket to answer the handshake
+const handshakeTimeout = 5 * time.Second
+
+// Writer writes exchanges as dnstap frames. It is a [util.Recorder].
+type Writer struct {
+	// Put in every frame, eg. the name and version of the program
+	Identity, Version string
+
+	mu sync.Mutex
+	w  io.Writer
+	c  io.Closer
+	// Set when the other end takes part in the handshake
+	conn net.Conn
+}
+
+var _ util.Recorder = (*Writer)(nil)
+
+// Open writes to a unix socket if dest is one, or starts with unix:, otherwise to a file.
+func Open(dest string) (*Writer, error) {
+	if path, ok := strings.CutPrefix(dest, "unix:"); ok {
+		return Dial(path)
+	}
+
+	if info, err := os.Stat(dest); err == nil && info.Mode()&os.ModeSocket != 0 {
+		return Dial(dest)
+	}
+
+	return Create(dest)
+}
+
+// Create creates a dnstap file.
+func Create(path string) (*Writer, error) {
+	file, err := os.Create(path) //nolint:gosec // Writing files is the point.
+	if err != nil {
+		return nil, fmt.Errorf("dnstap: %w", err)
+	}
+
+	w, err := NewWriter(file)
+	if err != nil {
+		file.Close()
+
+		return nil, err
+	}
+
+	w.c = file
+
+	return w, nil
+}
+
+// Dial connects to a unix socket, eg. of dnstap or a resolver collecting logs,
+// and makes the Frame Streams handshake.
+func Dial(path string) (*Writer, error) {
+	conn, err := net.DialTimeout("unix", path, handshakeTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("dnstap: %w", err)
+	}
+
+	if err := handshake(conn); err != nil {
+		conn.Close()
+
+		return nil, err
+	}
+
+	return &Writer{w: conn, c: conn, conn: conn}, nil
+}
+
+// handshake sends READY and START, once the other end has accepted dnstap.
+func handshake(conn net.Conn) error {
+	if err := conn.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		return fmt.Errorf("dnstap: %w", err)
+	}
+
+	if err := writeControl(conn, controlReady, contentType); err != nil {
+		return err
+	}
+
+	if err := readControl(conn, controlAccept); err != nil {
+		return err
+	}
+
+	if err := writeControl(conn, controlStart, contentType); err != nil {
+		return err
+	}
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return fmt.Errorf("dnstap: %w", err)
+	}
+
+	return nil
+}
+
+// NewWriter starts a stream of dnstap frames, ready for exchanges to be recorded.
+func NewWriter(w io.Writer) (*Writer, error) {
+	if err := writeControl(w, controlStart, contentType); err != nil {
+		return nil, err
+	}
+
+	return &Writer{w: w}, nil
+}
+
+// Record writes the query of an exchange as a TOOL_QUERY frame, and the
+// response as a TOOL_RESPONSE frame.
+func (w *Writer) Record(ex util.Exchange) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	base := message{
+		protocol: socketProtocol(ex),
+		query:    addrPort(ex.Local),
+		response: addrPort(ex.Remote),
+	}
+
+	if ex.Query != nil {
+		buf, err := ex.Query.Pack()
+		if err != nil {
+			return fmt.Errorf("dnstap: %w", err)
+		}
+
+		msg := base
+		msg.typ = typeToolQuery
+		msg.queryTime = ex.QueryTime
+		msg.queryMessage = buf
+
+		if err := writeFrame(w.w, msg.marshal(w.Identity, w.Version)); err != nil {
+			return err
+		}
+	}
+
+	if ex.Response != nil {
+		buf, err := ex.Response.Pack()
+		if err != nil {
+			return fmt.Errorf("dnstap: %w", err)
+		}
+
+		msg := base
+		msg.typ = typeToolResponse
+		msg.queryTime = ex.QueryTime
+		msg.responseTime = ex.ResponseTime
+		msg.responseMessage = buf
+
+		if err := writeFrame(w.w, msg.marshal(w.Identity, w.Version)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close ends the stream, and closes the file or socket it was opened with.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := writeControl(w.w, controlStop, "")
+
+	if err == nil && w.conn != nil {
+		err = w.conn.SetDeadline(time.Now().Add(handshakeTimeout))
+		if err == nil {
+			err = readControl(w.conn, controlFinish)
+		}
+	}
+
+	if w.c != nil {
+		if closeErr := w.c.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("dnstap: close: %w", err)
+	}
+
+	return nil
+}
+
+// socketProtocol gives the dnstap SocketProtocol of the transport of an exchange.
+func socketProtocol(ex util.Exchange) uint64 {
+	switch ex.Protocol {
+	case "UDP":
+		return protocolUDP
+	case "TCP":
+		return protocolTCP
+	case "TLS":
+		return protocolDOT
+	case "HTTPS":
+		return protocolDOH
+	case "QUIC":
+		return protocolDOQ
+	case "DNSCrypt":
+		if _, ok := ex.Remote.(*net.TCPAddr); ok {
+			return protocolDNSCryptTCP
+		}
+
+		return protocolDNSCryptUDP
+	default:
+		return 0
+	}
+}
+
+// addrPort gets the address and port of a connection.
+func addrPort(addr net.Addr) netip.AddrPort {
+	var ret netip.AddrPort
+
+	switch addr := addr.(type) {
+	case *net.UDPAddr:
+		ret = addr.AddrPort()
+	case *net.TCPAddr:
+		ret = addr.AddrPort()
+	case nil:
+	default:
+		ret, _ = netip.ParseAddrPort(addr.String())
+	}
+
+	return netip.AddrPortFrom(ret.Addr().Unmap(), ret.Port())
+}
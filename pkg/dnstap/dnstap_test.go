@@ -0,0 +1,270 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package dnstap_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dns.froth.zone/awl/pkg/dnstap"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+// fields decodes a protobuf message, giving every value by field number.
+// Varints and fixed32 are given as numbers, bytes as they are.
+func fields(t *testing.T, buf []byte) map[uint64]any {
+	t.Helper()
+
+	ret := make(map[uint64]any)
+
+	for len(buf) > 0 {
+		tag, n := binary.Uvarint(buf)
+		assert.Assert(t, n > 0)
+		buf = buf[n:]
+
+		switch tag & 7 {
+		case 0:
+			val, n := binary.Uvarint(buf)
+			assert.Assert(t, n > 0)
+			ret[tag>>3], buf = val, buf[n:]
+		case 2:
+			size, n := binary.Uvarint(buf)
+			assert.Assert(t, n > 0)
+			ret[tag>>3], buf = buf[n:n+int(size)], buf[n+int(size):]
+		case 5:
+			ret[tag>>3], buf = uint64(binary.LittleEndian.Uint32(buf)), buf[4:]
+		default:
+			t.Fatalf("wire type %d", tag&7)
+		}
+	}
+
+	return ret
+}
+
+// readFrames reads Frame Streams, giving the data frames and the types of the control frames.
+func readFrames(t *testing.T, r io.Reader) (data [][]byte, control []uint32) {
+	t.Helper()
+
+	for {
+		var length uint32
+		err := binary.Read(r, binary.BigEndian, &length)
+		if errors.Is(err, io.EOF) {
+			return data, control
+		}
+
+		assert.NilError(t, err)
+
+		escape := length == 0
+		if escape {
+			assert.NilError(t, binary.Read(r, binary.BigEndian, &length))
+		}
+
+		frame := make([]byte, length)
+		_, err = io.ReadFull(r, frame)
+		assert.NilError(t, err)
+
+		if !escape {
+			data = append(data, frame)
+
+			continue
+		}
+
+		typ := binary.BigEndian.Uint32(frame)
+		control = append(control, typ)
+
+		// STOP is the end of a stream
+		if typ == 3 {
+			return data, control
+		}
+	}
+}
+
+func exchange(t *testing.T) util.Exchange {
+	t.Helper()
+
+	query := new(dns.Msg)
+	query.SetQuestion("example.com.", dns.TypeA)
+
+	response := new(dns.Msg)
+	response.SetReply(query)
+
+	start := time.Unix(1700000000, 123456789)
+
+	return util.Exchange{
+		Query: query, Response: response, QueryTime: start, ResponseTime: start.Add(time.Millisecond),
+		Protocol: "TLS",
+		Local:    &net.TCPAddr{IP: net.IPv4(192, 0, 2, 10), Port: 40000},
+		Remote:   &net.TCPAddr{IP: net.IPv4(192, 0, 2, 53), Port: 853},
+	}
+}
+
+func TestWriter(t *testing.T) {
+	t.Parallel()
+
+	ex := exchange(t)
+	path := filepath.Join(t.TempDir(), "out.dnstap")
+
+	w, err := dnstap.Create(path)
+	assert.NilError(t, err)
+
+	w.Version = "awl TEST"
+
+	assert.NilError(t, w.Record(ex))
+	// Nothing known but the response
+	assert.NilError(t, w.Record(util.Exchange{Response: ex.Response, Protocol: "HTTPS"}))
+	assert.NilError(t, w.Close())
+
+	file, err := os.ReadFile(path)
+	assert.NilError(t, err)
+
+	// START first, with the content type
+	assert.Assert(t, bytes.Contains(file[:42], []byte("protobuf:dnstap.Dnstap")))
+
+	data, control := readFrames(t, bytes.NewReader(file))
+	assert.DeepEqual(t, control, []uint32{2, 3})
+	assert.Equal(t, len(data), 3)
+
+	top := fields(t, data[0])
+	assert.Equal(t, string(top[2].([]byte)), "awl TEST")
+	assert.Equal(t, top[15], uint64(1))
+
+	query := fields(t, top[14].([]byte))
+	assert.Equal(t, query[1], uint64(11))
+	assert.Equal(t, query[2], uint64(1))
+	assert.Equal(t, query[3], uint64(3))
+	assert.DeepEqual(t, query[4], []byte{192, 0, 2, 10})
+	assert.DeepEqual(t, query[5], []byte{192, 0, 2, 53})
+	assert.Equal(t, query[6], uint64(40000))
+	assert.Equal(t, query[7], uint64(853))
+	assert.Equal(t, query[8], uint64(1700000000))
+	assert.Equal(t, query[9], uint64(123456789))
+
+	msg := new(dns.Msg)
+	assert.NilError(t, msg.Unpack(query[10].([]byte)))
+	assert.Equal(t, msg.Question[0].Name, "example.com.")
+
+	response := fields(t, fields(t, data[1])[14].([]byte))
+	assert.Equal(t, response[1], uint64(12))
+	assert.Equal(t, response[12], uint64(1700000000))
+	assert.Equal(t, response[13], uint64(124456789))
+	assert.NilError(t, msg.Unpack(response[14].([]byte)))
+	assert.Assert(t, msg.Response)
+
+	response = fields(t, fields(t, data[2])[14].([]byte))
+	assert.Equal(t, response[3], uint64(4))
+	assert.Assert(t, response[4] == nil)
+}
+
+func TestDial(t *testing.T) {
+	t.Parallel()
+
+	dir, err := os.MkdirTemp("", "dnstap")
+	assert.NilError(t, err)
+
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	// Unix socket paths are short, so not t.TempDir
+	path := filepath.Join(dir, "sock")
+
+	listener, err := net.Listen("unix", path)
+	assert.NilError(t, err)
+
+	// Everything sent after READY, once the stream has stopped
+	done := make(chan []byte)
+
+	go func() {
+		defer close(done)
+
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		ready := make([]byte, 42)
+		if _, err := io.ReadFull(conn, ready); err != nil || !bytes.HasSuffix(ready, []byte("protobuf:dnstap.Dnstap")) {
+			return
+		}
+
+		accept := []byte{0, 0, 0, 0, 0, 0, 0, 34, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 22}
+		if _, err := conn.Write(append(accept, "protobuf:dnstap.Dnstap"...)); err != nil {
+			return
+		}
+
+		var stream []byte
+
+		stop := []byte{0, 0, 0, 0, 0, 0, 0, 4, 0, 0, 0, 3}
+		buf := make([]byte, 512)
+
+		for !bytes.HasSuffix(stream, stop) {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+
+			stream = append(stream, buf[:n]...)
+		}
+
+		if _, err := conn.Write([]byte{0, 0, 0, 0, 0, 0, 0, 4, 0, 0, 0, 5}); err != nil {
+			return
+		}
+
+		done <- stream
+	}()
+
+	// The socket is found without unix:
+	w, err := dnstap.Open(path)
+	assert.NilError(t, err)
+
+	assert.NilError(t, w.Record(exchange(t)))
+	assert.NilError(t, w.Close())
+
+	data, control := readFrames(t, bytes.NewReader(<-done))
+	assert.DeepEqual(t, control, []uint32{2, 3})
+	assert.Equal(t, len(data), 2)
+	assert.NilError(t, listener.Close())
+}
+
+func TestRead(t *testing.T) {
+	t.Parallel()
+
+	ex := exchange(t)
+	ex.Response.Rcode = dns.RcodeNameError
+
+	unanswered := new(dns.Msg)
+	unanswered.SetQuestion("example.net.", dns.TypeAAAA)
+
+	var buf bytes.Buffer
+
+	w, err := dnstap.NewWriter(&buf)
+	assert.NilError(t, err)
+
+	assert.NilError(t, w.Record(ex))
+	assert.NilError(t, w.Record(util.Exchange{Query: unanswered, QueryTime: ex.QueryTime, Protocol: "QUIC"}))
+	assert.NilError(t, w.Close())
+
+	exs, err := dnstap.Read(&buf)
+	assert.NilError(t, err)
+	assert.Equal(t, len(exs), 2)
+
+	assert.Equal(t, exs[0].Protocol, "TLS")
+	assert.Equal(t, exs[0].Query.Question[0].Name, "example.com.")
+	assert.Equal(t, exs[0].Response.Rcode, dns.RcodeNameError)
+	assert.Equal(t, exs[0].Local.String(), "192.0.2.10:40000")
+	assert.Equal(t, exs[0].Remote.String(), "192.0.2.53:853")
+	assert.Assert(t, exs[0].QueryTime.Equal(ex.QueryTime))
+	assert.Assert(t, exs[0].ResponseTime.Equal(ex.ResponseTime))
+
+	assert.Equal(t, exs[1].Protocol, "QUIC")
+	assert.Assert(t, exs[1].Response == nil)
+	assert.Assert(t, exs[1].Remote == nil)
+}
@@ -0,0 +1,13 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+/*
+Package dnstap writes the DNS messages exchanged with servers as dnstap
+TOOL_QUERY and TOOL_RESPONSE messages, in Frame Streams, to a file or a unix
+socket.
+
+Logs of any kind of dnstap message can also be read back, eg. to replay the
+queries in them.
+
+See https://dnstap.info for the format.
+*/
+package dnstap
@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package dnstap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Content type of dnstap frames
+const contentType = "protobuf:dnstap.Dnstap"
+
+// Frame Streams control frame types
+const (
+	controlAccept = 0x01
+	controlStart  = 0x02
+	controlStop   = 0x03
+	controlReady  = 0x04
+	controlFinish = 0x05
+)
+
+// Control field giving a content type
+const fieldContentType = 0x01
+
+// Largest control frame read
+const maxControl = 512
+
+// writeControl writes a control frame, with the content type if there is one.
+func writeControl(w io.Writer, typ uint32, content string) error {
+	frame := binary.BigEndian.AppendUint32(nil, typ)
+
+	if content != "" {
+		frame = binary.BigEndian.AppendUint32(frame, fieldContentType)
+		frame = binary.BigEndian.AppendUint32(frame, uint32(len(content))) //nolint:gosec // Short constant
+		frame = append(frame, content...)
+	}
+
+	// Control frames start with a data frame length of 0
+	buf := binary.BigEndian.AppendUint32(make([]byte, 4), uint32(len(frame))) //nolint:gosec // Short
+	if _, err := w.Write(append(buf, frame...)); err != nil {
+		return fmt.Errorf("dnstap: %w", err)
+	}
+
+	return nil
+}
+
+// readControl reads a control frame, checking its type and that it allows dnstap.
+func readControl(r io.Reader, want uint32) error {
+	hdr := make([]byte, 8)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return fmt.Errorf("dnstap: %w", err)
+	}
+
+	length := binary.BigEndian.Uint32(hdr[4:])
+	if binary.BigEndian.Uint32(hdr) != 0 || length < 4 || length > maxControl {
+		return fmt.Errorf("dnstap: %w", errBadControl)
+	}
+
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return fmt.Errorf("dnstap: %w", err)
+	}
+
+	if binary.BigEndian.Uint32(frame) != want {
+		return fmt.Errorf("dnstap: %w", errBadControl)
+	}
+
+	// FINISH has no fields, ACCEPT lists the content types allowed
+	if want != controlAccept {
+		return nil
+	}
+
+	for fields := frame[4:]; len(fields) >= 8; {
+		typ, size := binary.BigEndian.Uint32(fields), binary.BigEndian.Uint32(fields[4:])
+		if uint64(size) > uint64(len(fields)-8) {
+			break
+		}
+
+		if typ == fieldContentType && bytes.Equal(fields[8:8+size], []byte(contentType)) {
+			return nil
+		}
+
+		fields = fields[8+size:]
+	}
+
+	return fmt.Errorf("dnstap: %w", errNotAccepted)
+}
+
+// writeFrame writes a data frame.
+func writeFrame(w io.Writer, data []byte) error {
+	buf := binary.BigEndian.AppendUint32(nil, uint32(len(data))) //nolint:gosec // DNS messages are under 64k
+
+	if _, err := w.Write(append(buf, data...)); err != nil {
+		return fmt.Errorf("dnstap: %w", err)
+	}
+
+	return nil
+}
+
+var (
+	errBadControl  = errors.New("unexpected control frame")
+	errNotAccepted = errors.New("receiver does not accept dnstap")
+)
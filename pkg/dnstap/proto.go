@@ -0,0 +1,309 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package dnstap
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"time"
+)
+
+// Protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// Field numbers of the Dnstap message
+const (
+	fieldIdentity = 1
+	fieldVersion  = 2
+	fieldMessage  = 14
+	fieldType     = 15
+)
+
+// Field numbers of the Message message
+const (
+	fieldMessageType      = 1
+	fieldSocketFamily     = 2
+	fieldSocketProtocol   = 3
+	fieldQueryAddress     = 4
+	fieldResponseAddress  = 5
+	fieldQueryPort        = 6
+	fieldResponsePort     = 7
+	fieldQueryTimeSec     = 8
+	fieldQueryTimeNsec    = 9
+	fieldQueryMessage     = 10
+	fieldResponseTimeSec  = 12
+	fieldResponseTimeNsec = 13
+	fieldResponseMessage  = 14
+)
+
+// Dnstap.Type
+const typeMessage = 1
+
+// Message.Type
+const (
+	typeToolQuery    = 11
+	typeToolResponse = 12
+)
+
+// SocketFamily
+const (
+	familyINET  = 1
+	familyINET6 = 2
+)
+
+// SocketProtocol
+const (
+	protocolUDP         = 1
+	protocolTCP         = 2
+	protocolDOT         = 3
+	protocolDOH         = 4
+	protocolDNSCryptUDP = 5
+	protocolDNSCryptTCP = 6
+	protocolDOQ         = 7
+)
+
+// message is a dnstap Message, with the fields awl uses.
+type message struct {
+	typ      uint64
+	protocol uint64
+	// Address of awl and the server, invalid if not known
+	query, response netip.AddrPort
+	queryTime       time.Time
+	responseTime    time.Time
+	queryMessage    []byte
+	responseMessage []byte
+}
+
+// marshal encodes a message inside a Dnstap message.
+func (m message) marshal(identity, version string) []byte {
+	var msg []byte
+
+	msg = appendVarint(msg, fieldMessageType, m.typ)
+
+	if addr := m.response.Addr(); addr.IsValid() {
+		family := uint64(familyINET6)
+		if addr.Is4() {
+			family = familyINET
+		}
+
+		msg = appendVarint(msg, fieldSocketFamily, family)
+	}
+
+	if m.protocol != 0 {
+		msg = appendVarint(msg, fieldSocketProtocol, m.protocol)
+	}
+
+	if m.query.IsValid() {
+		msg = appendBytes(msg, fieldQueryAddress, m.query.Addr().AsSlice())
+	}
+
+	if m.response.IsValid() {
+		msg = appendBytes(msg, fieldResponseAddress, m.response.Addr().AsSlice())
+	}
+
+	if m.query.IsValid() {
+		msg = appendVarint(msg, fieldQueryPort, uint64(m.query.Port()))
+	}
+
+	if m.response.IsValid() {
+		msg = appendVarint(msg, fieldResponsePort, uint64(m.response.Port()))
+	}
+
+	if !m.queryTime.IsZero() {
+		msg = appendVarint(msg, fieldQueryTimeSec, uint64(m.queryTime.Unix()))         //nolint:gosec // Times are after 1970
+		msg = appendFixed32(msg, fieldQueryTimeNsec, uint32(m.queryTime.Nanosecond())) //nolint:gosec // Under a billion
+	}
+
+	if m.queryMessage != nil {
+		msg = appendBytes(msg, fieldQueryMessage, m.queryMessage)
+	}
+
+	if !m.responseTime.IsZero() {
+		msg = appendVarint(msg, fieldResponseTimeSec, uint64(m.responseTime.Unix()))         //nolint:gosec // Times are after 1970
+		msg = appendFixed32(msg, fieldResponseTimeNsec, uint32(m.responseTime.Nanosecond())) //nolint:gosec // Under a billion
+	}
+
+	if m.responseMessage != nil {
+		msg = appendBytes(msg, fieldResponseMessage, m.responseMessage)
+	}
+
+	var ret []byte
+
+	if identity != "" {
+		ret = appendBytes(ret, fieldIdentity, []byte(identity))
+	}
+
+	if version != "" {
+		ret = appendBytes(ret, fieldVersion, []byte(version))
+	}
+
+	ret = appendBytes(ret, fieldMessage, msg)
+
+	return appendVarint(ret, fieldType, typeMessage)
+}
+
+func appendTag(buf []byte, field, wire uint64) []byte {
+	return binary.AppendUvarint(buf, field<<3|wire)
+}
+
+func appendVarint(buf []byte, field, val uint64) []byte {
+	return binary.AppendUvarint(appendTag(buf, field, wireVarint), val)
+}
+
+func appendBytes(buf []byte, field uint64, val []byte) []byte {
+	buf = binary.AppendUvarint(appendTag(buf, field, wireBytes), uint64(len(val)))
+
+	return append(buf, val...)
+}
+
+func appendFixed32(buf []byte, field uint64, val uint32) []byte {
+	return binary.LittleEndian.AppendUint32(appendTag(buf, field, wireFixed32), val)
+}
+
+// field is a field of an encoded protobuf message.
+type field struct {
+	num  uint64
+	wire uint64
+	// Value of varint and fixed32 fields
+	val uint64
+	// Value of bytes fields
+	data []byte
+}
+
+// fields decodes the fields of a protobuf message.
+func fields(buf []byte) ([]field, error) {
+	var ret []field
+
+	for len(buf) > 0 {
+		tag, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return nil, errBadProto
+		}
+
+		f := field{num: tag >> 3, wire: tag & 7}
+		buf = buf[n:]
+
+		switch f.wire {
+		case wireVarint:
+			f.val, n = binary.Uvarint(buf)
+			if n <= 0 {
+				return nil, errBadProto
+			}
+
+			buf = buf[n:]
+		case wireBytes:
+			size, n := binary.Uvarint(buf)
+			if n <= 0 || size > uint64(len(buf)-n) {
+				return nil, errBadProto
+			}
+
+			f.data, buf = buf[n:n+int(size)], buf[n+int(size):]
+		case wireFixed32:
+			if len(buf) < 4 {
+				return nil, errBadProto
+			}
+
+			f.val, buf = uint64(binary.LittleEndian.Uint32(buf)), buf[4:]
+		case wireFixed64:
+			if len(buf) < 8 {
+				return nil, errBadProto
+			}
+
+			f.val, buf = binary.LittleEndian.Uint64(buf), buf[8:]
+		default:
+			return nil, errBadProto
+		}
+
+		ret = append(ret, f)
+	}
+
+	return ret, nil
+}
+
+// unmarshal decodes a Dnstap message, giving the Message inside it if there is one.
+func unmarshal(buf []byte) (*message, error) {
+	top, err := fields(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	var inner []byte
+
+	for _, f := range top {
+		if f.num == fieldMessage && f.wire == wireBytes {
+			inner = f.data
+		}
+	}
+
+	if inner == nil {
+		return nil, nil //nolint:nilnil // Not every frame has a message
+	}
+
+	msg := new(message)
+
+	var (
+		queryAddr, responseAddr netip.Addr
+		queryPort, responsePort uint16
+		queryTime, respTime     [2]uint64
+	)
+
+	inners, err := fields(inner)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range inners {
+		switch f.num {
+		case fieldMessageType:
+			msg.typ = f.val
+		case fieldSocketProtocol:
+			msg.protocol = f.val
+		case fieldQueryAddress:
+			queryAddr, _ = netip.AddrFromSlice(f.data)
+		case fieldResponseAddress:
+			responseAddr, _ = netip.AddrFromSlice(f.data)
+		case fieldQueryPort:
+			queryPort = uint16(f.val) //nolint:gosec // Ports are 16 bits
+		case fieldResponsePort:
+			responsePort = uint16(f.val) //nolint:gosec // Ports are 16 bits
+		case fieldQueryTimeSec:
+			queryTime[0] = f.val
+		case fieldQueryTimeNsec:
+			queryTime[1] = f.val
+		case fieldQueryMessage:
+			msg.queryMessage = f.data
+		case fieldResponseTimeSec:
+			respTime[0] = f.val
+		case fieldResponseTimeNsec:
+			respTime[1] = f.val
+		case fieldResponseMessage:
+			msg.responseMessage = f.data
+		}
+	}
+
+	if queryAddr.IsValid() {
+		msg.query = netip.AddrPortFrom(queryAddr.Unmap(), queryPort)
+	}
+
+	if responseAddr.IsValid() {
+		msg.response = netip.AddrPortFrom(responseAddr.Unmap(), responsePort)
+	}
+
+	if queryTime[0] != 0 {
+		msg.queryTime = time.Unix(int64(queryTime[0]), int64(queryTime[1])) //nolint:gosec // Times are after 1970
+	}
+
+	if respTime[0] != 0 {
+		msg.responseTime = time.Unix(int64(respTime[0]), int64(respTime[1])) //nolint:gosec // Times are after 1970
+	}
+
+	return msg, nil
+}
+
+var errBadProto = errors.New("bad protobuf message")
@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package dnstap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+)
+
+// Largest data frame read
+const maxFrame = 1 << 20
+
+// ReadFile reads the DNS exchanges logged in a dnstap file.
+func ReadFile(path string) ([]util.Exchange, error) {
+	file, err := os.Open(path) //nolint:gosec // Reading files is the point.
+	if err != nil {
+		return nil, fmt.Errorf("dnstap: %w", err)
+	}
+	defer file.Close()
+
+	return Read(file)
+}
+
+// Read reads the DNS exchanges logged as dnstap in Frame Streams, of any kind,
+// not only those of awl.
+//
+// Queries are paired with their responses by address, port and ID. Queries
+// without a response are kept. Responses without a query are kept when the
+// query was logged with them.
+func Read(r io.Reader) ([]util.Exchange, error) {
+	var (
+		exs []util.Exchange
+		// Index in exs of queries still waiting for a response
+		pending = make(map[key]int)
+		hdr     = make([]byte, 4)
+	)
+
+	for {
+		if _, err := io.ReadFull(r, hdr); err != nil {
+			if errors.Is(err, io.EOF) {
+				return exs, nil
+			}
+
+			return nil, fmt.Errorf("dnstap: %w", err)
+		}
+
+		length := binary.BigEndian.Uint32(hdr)
+
+		control := length == 0
+		if control {
+			if _, err := io.ReadFull(r, hdr); err != nil {
+				return nil, fmt.Errorf("dnstap: %w", err)
+			}
+
+			length = binary.BigEndian.Uint32(hdr)
+		}
+
+		if length > maxFrame {
+			return nil, fmt.Errorf("dnstap: %w", errTooLong)
+		}
+
+		frame := make([]byte, length)
+		if _, err := io.ReadFull(r, frame); err != nil {
+			return nil, fmt.Errorf("dnstap: %w", err)
+		}
+
+		if control {
+			// STOP ends a stream, START and the rest are skipped
+			if len(frame) >= 4 && binary.BigEndian.Uint32(frame) == controlStop {
+				return exs, nil
+			}
+
+			continue
+		}
+
+		msg, err := unmarshal(frame)
+		if err != nil {
+			return nil, fmt.Errorf("dnstap: %w", err)
+		}
+
+		if msg != nil {
+			exs = msg.pair(exs, pending)
+		}
+	}
+}
+
+// key finds the query a response is for.
+type key struct {
+	query, response netip.AddrPort
+	id              uint16
+}
+
+// pair adds a logged query to the exchanges, or adds a response to its query.
+func (m *message) pair(exs []util.Exchange, pending map[key]int) []util.Exchange {
+	var query, response *dns.Msg
+
+	if m.queryMessage != nil {
+		query = new(dns.Msg)
+		if err := query.Unpack(m.queryMessage); err != nil {
+			query = nil
+		}
+	}
+
+	if m.responseMessage != nil {
+		response = new(dns.Msg)
+		if err := response.Unpack(m.responseMessage); err != nil {
+			response = nil
+		}
+	}
+
+	// Queries have odd types, responses even ones
+	isQuery := m.typ%2 == 1
+
+	if isQuery {
+		if query == nil {
+			return exs
+		}
+
+		pending[key{m.query, m.response, query.Id}] = len(exs)
+
+		return append(exs, m.exchange(query))
+	}
+
+	if response == nil {
+		return exs
+	}
+
+	k := key{m.query, m.response, response.Id}
+
+	i, ok := pending[k]
+	if !ok {
+		if query == nil {
+			return exs
+		}
+
+		exs = append(exs, m.exchange(query))
+		i = len(exs) - 1
+	}
+
+	delete(pending, k)
+
+	exs[i].Response = response
+	exs[i].ResponseTime = m.responseTime
+
+	return exs
+}
+
+// exchange makes an exchange for a query.
+func (m *message) exchange(query *dns.Msg) util.Exchange {
+	ex := util.Exchange{
+		Query:     query,
+		QueryTime: m.queryTime,
+	}
+
+	tcp := true
+
+	switch m.protocol {
+	case protocolUDP:
+		ex.Protocol, tcp = "UDP", false
+	case protocolTCP:
+		ex.Protocol = "TCP"
+	case protocolDOT:
+		ex.Protocol = "TLS"
+	case protocolDOH:
+		ex.Protocol = "HTTPS"
+	case protocolDNSCryptUDP:
+		ex.Protocol, tcp = "DNSCrypt", false
+	case protocolDNSCryptTCP:
+		ex.Protocol = "DNSCrypt"
+	case protocolDOQ:
+		ex.Protocol, tcp = "QUIC", false
+	default:
+		ex.Protocol, tcp = "UDP", false
+	}
+
+	for _, addr := range []struct {
+		ap  netip.AddrPort
+		set *net.Addr
+	}{
+		{m.query, &ex.Local},
+		{m.response, &ex.Remote},
+	} {
+		switch {
+		case !addr.ap.IsValid():
+		case tcp:
+			*addr.set = net.TCPAddrFromAddrPort(addr.ap)
+		default:
+			*addr.set = net.UDPAddrFromAddrPort(addr.ap)
+		}
+	}
+
+	return ex
+}
+
+var errTooLong = errors.New("frame too long")
@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package dnstest
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+)
+
+// RTT is the round trip time given with every response.
+const RTT = time.Millisecond
+
+// Network maps server addresses to the zone they serve.
+// Queries to any other address time out.
+type Network map[string]Zone
+
+// Zone is a fake authoritative server.
+type Zone struct {
+	// Apex of the zone
+	Origin string
+	// Records of the zone in presentation format, including delegations and glue
+	Records []string
+	// Whether to leave the AA bit unset in answers
+	NoAA bool
+	// Given to every query instead of answering, when set
+	Rcode int
+}
+
+// Exchange answers the query in opts from the zone served at the address of
+// the server in opts.
+func (n Network) Exchange(opts *util.Options) (util.Response, error) {
+	host, _, err := net.SplitHostPort(opts.Request.Server)
+	if err != nil {
+		host = opts.Request.Server
+	}
+
+	z, ok := n[host]
+	if !ok {
+		return util.Response{}, fmt.Errorf("dnstest: %s: %w", host, ErrTimeout)
+	}
+
+	msg, err := z.Answer(dns.Question{Name: dns.Fqdn(opts.Request.Name), Qtype: opts.Request.Type, Qclass: dns.ClassINET})
+	if err != nil {
+		return util.Response{}, err
+	}
+
+	return util.Response{DNS: msg, RTT: RTT}, nil
+}
+
+// Answer answers a question from the records of the zone.
+//
+// Questions below a delegation are given a referral, with the glue of the
+// name servers. Any other question is answered authoritatively, along with
+// the signatures of the answer, or is given NODATA or NXDOMAIN with the SOA
+// record and the NSEC3 records that match or cover the name.
+func (z Zone) Answer(q dns.Question) (*dns.Msg, error) {
+	rrs := make([]dns.RR, 0, len(z.Records))
+
+	for _, str := range z.Records {
+		rr, err := dns.NewRR(str)
+		if err != nil {
+			return nil, fmt.Errorf("dnstest: %w", err)
+		}
+
+		rrs = append(rrs, rr)
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(q.Name, q.Qtype)
+	msg.Response = true
+
+	if z.Rcode != dns.RcodeSuccess {
+		msg.Rcode = z.Rcode
+
+		return msg, nil
+	}
+
+	if cut := z.cut(rrs, q); cut != "" {
+		for _, rr := range rrs {
+			if rr.Header().Rrtype == dns.TypeNS && util.EqualNames(rr.Header().Name, cut) {
+				msg.Ns = append(msg.Ns, rr)
+			}
+		}
+
+		msg.Extra = glue(rrs, msg.Ns)
+
+		return msg, nil
+	}
+
+	msg.Authoritative = !z.NoAA
+
+	found := false
+
+	for _, rr := range rrs {
+		hdr := rr.Header()
+
+		switch {
+		case util.EqualNames(hdr.Name, q.Name):
+			found = true
+
+			if sig, ok := rr.(*dns.RRSIG); hdr.Rrtype == q.Qtype || hdr.Rrtype == dns.TypeCNAME || (ok && sig.TypeCovered == q.Qtype) {
+				msg.Answer = append(msg.Answer, rr)
+			}
+		case dns.IsSubDomain(q.Name, hdr.Name):
+			// Empty non-terminals exist too
+			found = true
+		case hdr.Rrtype == dns.TypeDNAME && dns.IsSubDomain(hdr.Name, q.Name):
+			dname, _ := rr.(*dns.DNAME)
+			target := q.Name[:len(q.Name)-len(hdr.Name)] + dname.Target
+
+			msg.Answer = append(msg.Answer, rr, &dns.CNAME{
+				Hdr:    dns.RR_Header{Name: q.Name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: hdr.Ttl},
+				Target: target,
+			})
+
+			return msg, nil
+		}
+	}
+
+	if len(msg.Answer) > 0 {
+		msg.Extra = glue(rrs, msg.Answer)
+
+		return msg, nil
+	}
+
+	if !found {
+		msg.Rcode = dns.RcodeNameError
+	}
+
+	for _, rr := range rrs {
+		switch rr := rr.(type) {
+		case *dns.SOA:
+			if dns.IsSubDomain(rr.Hdr.Name, q.Name) {
+				msg.Ns = append(msg.Ns, rr)
+			}
+		case *dns.NSEC3:
+			if rr.Match(q.Name) || rr.Cover(q.Name) {
+				msg.Ns = append(msg.Ns, rr)
+			}
+		}
+	}
+
+	return msg, nil
+}
+
+// cut finds the closest delegation below the origin leading to the question.
+// The DS records of a delegation are answered by the parent, and NS records at
+// the apex of other zones also served are not delegations.
+func (z Zone) cut(rrs []dns.RR, q dns.Question) (cut string) {
+	apexes := make(map[string]bool)
+
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == dns.TypeSOA {
+			apexes[dns.CanonicalName(rr.Header().Name)] = true
+		}
+	}
+
+	for _, rr := range rrs {
+		owner := rr.Header().Name
+
+		switch {
+		case rr.Header().Rrtype != dns.TypeNS,
+			util.EqualNames(owner, z.Origin),
+			!dns.IsSubDomain(z.Origin, owner),
+			apexes[dns.CanonicalName(owner)],
+			!dns.IsSubDomain(owner, q.Name),
+			q.Qtype == dns.TypeDS && util.EqualNames(owner, q.Name):
+			continue
+		}
+
+		if cut == "" || dns.CountLabel(owner) > dns.CountLabel(cut) {
+			cut = owner
+		}
+	}
+
+	return cut
+}
+
+// glue finds the address records of the name servers in rrs.
+func glue(zone, rrs []dns.RR) (extra []dns.RR) {
+	for _, rr := range rrs {
+		ns, ok := rr.(*dns.NS)
+		if !ok {
+			continue
+		}
+
+		for _, addr := range zone {
+			if t := addr.Header().Rrtype; (t == dns.TypeA || t == dns.TypeAAAA) && util.EqualNames(addr.Header().Name, ns.Ns) {
+				extra = append(extra, addr)
+			}
+		}
+	}
+
+	return extra
+}
+
+// ErrTimeout is given for queries to addresses not in the network.
+var ErrTimeout = errors.New("i/o timeout")
@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package dnstest_test
+
+import (
+	"testing"
+
+	"dns.froth.zone/awl/pkg/dnstest"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+var network = dnstest.Network{
+	"192.0.2.1": {Origin: "com.", Records: []string{
+		"com. 172800 IN SOA a.gtld-servers.net. nstld.verisign-grs.com. 1 1800 900 604800 86400",
+		"example.com. 172800 IN NS ns1.example.com.",
+		"example.com. 86400 IN DS 12345 13 2 8D10EC16E2E18C0D8AD8C6ABF55A0A1E9C33A9EB0F0A1C6F0B5AD96A3B7C7F21",
+		"ns1.example.com. 172800 IN A 192.0.2.2",
+	}},
+	"192.0.2.3": {Rcode: dns.RcodeRefused},
+}
+
+func exchange(t *testing.T, server, name string, qtype uint16) *dns.Msg {
+	t.Helper()
+
+	resp, err := network.Exchange(&util.Options{
+		Request: util.Request{Server: server + ":53", Name: name, Type: qtype},
+	})
+	assert.NilError(t, err)
+
+	return resp.DNS
+}
+
+func TestReferral(t *testing.T) {
+	t.Parallel()
+
+	msg := exchange(t, "192.0.2.1", "www.example.com", dns.TypeA)
+	assert.Assert(t, !msg.Authoritative)
+	assert.Equal(t, len(msg.Ns), 1)
+	assert.Equal(t, msg.Extra[0].(*dns.A).A.String(), "192.0.2.2")
+
+	// The parent side of the delegation
+	msg = exchange(t, "192.0.2.1", "example.com.", dns.TypeDS)
+	assert.Assert(t, msg.Authoritative)
+	assert.Equal(t, msg.Answer[0].Header().Rrtype, dns.TypeDS)
+}
+
+func TestNegative(t *testing.T) {
+	t.Parallel()
+
+	msg := exchange(t, "192.0.2.1", "nope.com.", dns.TypeA)
+	assert.Equal(t, msg.Rcode, dns.RcodeNameError)
+	assert.Equal(t, msg.Ns[0].Header().Rrtype, dns.TypeSOA)
+
+	msg = exchange(t, "192.0.2.3", "nope.com.", dns.TypeA)
+	assert.Equal(t, msg.Rcode, dns.RcodeRefused)
+
+	_, err := network.Exchange(&util.Options{Request: util.Request{Server: "192.0.2.99"}})
+	assert.ErrorIs(t, err, dnstest.ErrTimeout)
+}
@@ -0,0 +1,12 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+/*
+Package dnstest is a fake network of authoritative servers, to test code that
+makes queries without sending any.
+
+A [Network] answers queries in place of [query.CreateQuery], from the records
+given for every server address.
+
+[query.CreateQuery]: https://pkg.go.dev/dns.froth.zone/awl/pkg/query#CreateQuery
+*/
+package dnstest
@@ -0,0 +1,7 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+/*
+Package expiry checks when the DNSSEC signatures of a zone expire on every one
+of its authoritative servers, reporting the result like a Nagios plugin.
+*/
+package expiry
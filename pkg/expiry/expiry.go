@@ -0,0 +1,253 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package expiry
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"time"
+
+	"dns.froth.zone/awl/pkg/query"
+	"dns.froth.zone/awl/pkg/trace"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+)
+
+// Report is the result of checking every authoritative server of a zone.
+//
+//nolint:govet // Better looking output is worth a few bytes.
+type Report struct {
+	XMLName xml.Name `json:"-" xml:"expiry" yaml:"-"`
+	// Zone that was checked
+	Zone string `json:"zone" xml:"zone" yaml:"zone" example:"example.com."`
+	// Overall state, the worst of every signature and server
+	State State `json:"state" xml:"state" yaml:"state" example:"OK"`
+	// One line summary of the state
+	Summary string `json:"summary" xml:"summary" yaml:"summary"`
+	// Thresholds used
+	Warning  time.Duration `json:"warning" xml:"warning" yaml:"warning" example:"604800000000000"`
+	Critical time.Duration `json:"critical" xml:"critical" yaml:"critical" example:"259200000000000"`
+	// Every signature seen
+	Signatures []Signature `json:"signatures,omitempty" xml:"signature,omitempty" yaml:"signatures,omitempty"`
+	// Servers that could not be checked
+	Failures []Failure `json:"failures,omitempty" xml:"failure,omitempty" yaml:"failures,omitempty"`
+}
+
+// Signature is a single RRSIG given by a server.
+//
+//nolint:govet // Better looking output is worth a few bytes.
+type Signature struct {
+	// Name of the server the signature came from
+	Server string `json:"server" xml:"server" yaml:"server" example:"ns1.example.com."`
+	// Address of the server
+	Address string `json:"address" xml:"address" yaml:"address" example:"192.0.2.1"`
+	// Owner name of the signed RRset
+	Name string `json:"name" xml:"name" yaml:"name" example:"example.com."`
+	// Type of the signed RRset
+	Type      string    `json:"type" xml:"type" yaml:"type" example:"SOA"`
+	KeyTag    uint16    `json:"keyTag" xml:"keyTag" yaml:"keyTag" example:"12345"`
+	Inception time.Time `json:"inception" xml:"inception" yaml:"inception"`
+	Expiry    time.Time `json:"expiry" xml:"expiry" yaml:"expiry"`
+	// Time until the signature expires, negative if it has expired
+	Remaining time.Duration `json:"remaining" xml:"remaining" yaml:"remaining" example:"1209600000000000"`
+	// State of the signature alone
+	State State `json:"state" xml:"state" yaml:"state" example:"OK"`
+}
+
+// Failure is a server that could not be checked.
+type Failure struct {
+	Server  string `json:"server" xml:"server" yaml:"server" example:"ns1.example.com."`
+	Address string `json:"address" xml:"address" yaml:"address" example:"192.0.2.1"`
+	Error   string `json:"error" xml:"error" yaml:"error"`
+}
+
+// Checker checks the signatures of a zone.
+type Checker struct {
+	opts   *util.Options
+	tracer *trace.Tracer
+	now    time.Time
+}
+
+// New creates a new Checker from the options given.
+func New(opts *util.Options) *Checker {
+	return NewWith(opts, query.CreateQuery)
+}
+
+// NewWith creates a new Checker making every query with exchange, as with [trace.NewWith].
+func NewWith(opts *util.Options, exchange func(*util.Options) (util.Response, error)) *Checker {
+	// Signatures are only given with the DO bit set
+	o := *opts
+	o.EDNS.EnableEDNS = true
+	o.EDNS.DNSSEC = true
+
+	return &Checker{
+		opts:   opts,
+		tracer: trace.NewWith(&o, exchange),
+		now:    time.Now(),
+	}
+}
+
+// Check checks the signatures of the zone the requested name is in.
+func Check(opts *util.Options) (*Report, error) {
+	return New(opts).Check()
+}
+
+// Check queries every authoritative server of the zone the requested name is in,
+// with the DO bit set, and checks when every signature given expires.
+//
+// The requested name and type are queried, along with the SOA and DNSKEY records
+// of the zone.
+func (c *Checker) Check() (*Report, error) {
+	zone, srvs, err := c.tracer.Authoritative(c.opts.Request.Name)
+	if err != nil {
+		return nil, fmt.Errorf("finding servers: %w", err)
+	}
+
+	report := &Report{
+		Zone:     zone,
+		Warning:  c.opts.ExpiryWarning,
+		Critical: c.opts.ExpiryCritical,
+	}
+
+	for _, srv := range srvs {
+		if len(srv.Addrs) == 0 {
+			report.Failures = append(report.Failures, Failure{srv.Name, "", errNoAddress.Error()})
+
+			continue
+		}
+
+		for _, addr := range c.tracer.Filter(srv.Addrs) {
+			sigs, err := c.server(zone, srv.Name, addr)
+			if err != nil {
+				report.Failures = append(report.Failures, Failure{srv.Name, addr, err.Error()})
+
+				continue
+			}
+
+			report.Signatures = append(report.Signatures, sigs...)
+		}
+	}
+
+	c.summarize(report)
+
+	return report, nil
+}
+
+// server collects the signatures from a single server.
+func (c *Checker) server(zone, server, addr string) (sigs []Signature, err error) {
+	type question struct {
+		name  string
+		qtype uint16
+	}
+
+	questions := []question{{dns.Fqdn(c.opts.Request.Name), c.opts.Request.Type}}
+
+	for _, qtype := range []uint16{dns.TypeSOA, dns.TypeDNSKEY} {
+		if !(util.EqualNames(questions[0].name, zone) && questions[0].qtype == qtype) {
+			questions = append(questions, question{zone, qtype})
+		}
+	}
+
+	for _, q := range questions {
+		resp, err := c.tracer.QueryAddr(addr, q.name, q.qtype)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", q.name, dns.TypeToString[q.qtype], err)
+		}
+
+		msg := resp.DNS
+
+		if msg.Rcode != dns.RcodeSuccess && msg.Rcode != dns.RcodeNameError {
+			return nil, fmt.Errorf("%s %s: %w: %s", q.name, dns.TypeToString[q.qtype], errRcode, dns.RcodeToString[msg.Rcode])
+		}
+
+		for _, rr := range append(msg.Answer, msg.Ns...) {
+			if sig, ok := rr.(*dns.RRSIG); ok {
+				sigs = append(sigs, c.signature(sig, server, addr))
+			}
+		}
+	}
+
+	return sigs, nil
+}
+
+// signature checks a single signature against the thresholds.
+func (c *Checker) signature(sig *dns.RRSIG, server, addr string) Signature {
+	s := Signature{
+		Server:    server,
+		Address:   addr,
+		Name:      sig.Hdr.Name,
+		Type:      dns.TypeToString[sig.TypeCovered],
+		KeyTag:    sig.KeyTag,
+		Inception: time.Unix(int64(sig.Inception), 0).UTC(),
+		Expiry:    time.Unix(int64(sig.Expiration), 0).UTC(),
+	}
+
+	s.Remaining = s.Expiry.Sub(c.now).Round(time.Second)
+
+	switch {
+	case c.now.Before(s.Inception), s.Remaining <= c.opts.ExpiryCritical:
+		s.State = Critical
+	case s.Remaining <= c.opts.ExpiryWarning:
+		s.State = Warning
+	default:
+		s.State = OK
+	}
+
+	return s
+}
+
+// summarize sets the overall state and summary of a report.
+func (c *Checker) summarize(r *Report) {
+	if len(r.Signatures) == 0 {
+		r.State = Critical
+		r.Summary = "no signatures found for " + r.Zone
+
+		if len(r.Failures) > 0 {
+			r.State = Unknown
+			r.Summary = fmt.Sprintf("no servers of %s could be checked", r.Zone)
+		}
+
+		return
+	}
+
+	first := r.Signatures[0]
+	servers := make(map[string]bool)
+
+	for _, sig := range r.Signatures {
+		servers[sig.Address] = true
+
+		switch {
+		case sig.State.severity() > first.State.severity():
+			first = sig
+		case sig.State == first.State && sig.Remaining < first.Remaining:
+			first = sig
+		}
+	}
+
+	r.State = first.State
+
+	switch {
+	case first.State == OK && len(r.Failures) > 0:
+		r.State = Unknown
+		f := r.Failures[0]
+		r.Summary = fmt.Sprintf("%s (%s) could not be checked: %s", f.Server, f.Address, f.Error)
+	case first.State == OK:
+		r.Summary = fmt.Sprintf("%d signatures from %d servers, earliest expiry in %s",
+			len(r.Signatures), len(servers), first.Remaining)
+	case first.Remaining <= 0:
+		r.Summary = fmt.Sprintf("%s %s signature by key %d on %s (%s) expired %s ago",
+			first.Name, first.Type, first.KeyTag, first.Server, first.Address, -first.Remaining)
+	case c.now.Before(first.Inception):
+		r.Summary = fmt.Sprintf("%s %s signature by key %d on %s (%s) is not valid until %s",
+			first.Name, first.Type, first.KeyTag, first.Server, first.Address, first.Inception.Format(time.RFC3339))
+	default:
+		r.Summary = fmt.Sprintf("%s %s signature by key %d on %s (%s) expires in %s",
+			first.Name, first.Type, first.KeyTag, first.Server, first.Address, first.Remaining)
+	}
+}
+
+var (
+	errNoAddress = errors.New("no addresses found")
+	errRcode     = errors.New("bad response code")
+)
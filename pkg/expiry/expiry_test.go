@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package expiry_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"dns.froth.zone/awl/pkg/dnstest"
+	"dns.froth.zone/awl/pkg/expiry"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+// network serves example.com. on ns1 at 192.0.2.1, and on ns2 at 192.0.2.2
+// and 2001:db8::2, with signatures that have as long left as given.
+func network(left map[string]time.Duration) dnstest.Network {
+	ret := dnstest.Network{
+		"198.41.0.4": {Origin: ".", Records: []string{
+			"com. 172800 IN NS a.gtld-servers.net.",
+			"a.gtld-servers.net. 172800 IN A 192.0.2.30",
+		}},
+		"192.0.2.30": {Origin: "com.", Records: []string{
+			"example.com. 172800 IN NS ns1.example.com.",
+			"example.com. 172800 IN NS ns2.example.com.",
+			"ns1.example.com. 172800 IN A 192.0.2.1",
+			"ns2.example.com. 172800 IN A 192.0.2.2",
+			"ns2.example.com. 172800 IN AAAA 2001:db8::2",
+		}},
+	}
+
+	now := time.Now()
+
+	for addr, left := range left {
+		records := []string{
+			"example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 3600",
+			"example.com. 3600 IN NS ns1.example.com.",
+			"example.com. 3600 IN NS ns2.example.com.",
+			"example.com. 3600 IN DNSKEY 257 3 13 AAAA",
+			"ns1.example.com. 3600 IN A 192.0.2.1",
+			"ns2.example.com. 3600 IN A 192.0.2.2",
+			"ns2.example.com. 3600 IN AAAA 2001:db8::2",
+			"www.example.com. 300 IN A 192.0.2.80",
+		}
+
+		expiration := dns.TimeToString(uint32(now.Add(left).Unix()))
+		inception := dns.TimeToString(uint32(now.Add(-24 * time.Hour).Unix()))
+
+		for _, signed := range []string{"www.example.com. A", "example.com. SOA", "example.com. DNSKEY"} {
+			name, qtype, _ := strings.Cut(signed, " ")
+			records = append(records, fmt.Sprintf("%s 300 IN RRSIG %s 13 2 300 %s %s 12345 example.com. AAAA",
+				name, qtype, expiration, inception))
+		}
+
+		ret[addr] = dnstest.Zone{Origin: "example.com.", Records: records}
+	}
+
+	return ret
+}
+
+func check(t *testing.T, left map[string]time.Duration) *expiry.Report {
+	t.Helper()
+
+	opts := &util.Options{
+		Logger:         util.InitLogger(0),
+		IPv4:           true,
+		ExpiryWarning:  7 * 24 * time.Hour,
+		ExpiryCritical: 3 * 24 * time.Hour,
+		Request:        util.Request{Name: "www.example.com", Type: dns.TypeA},
+	}
+
+	report, err := expiry.NewWith(opts, network(left).Exchange).Check()
+	assert.NilError(t, err)
+
+	return report
+}
+
+func TestOK(t *testing.T) {
+	t.Parallel()
+
+	report := check(t, map[string]time.Duration{"192.0.2.1": 30 * 24 * time.Hour, "192.0.2.2": 14 * 24 * time.Hour})
+
+	assert.Equal(t, report.State, expiry.OK)
+	assert.Equal(t, report.Zone, "example.com.")
+	// A, SOA and DNSKEY from both servers, IPv6 is skipped
+	assert.Equal(t, len(report.Signatures), 6)
+	assert.Assert(t, strings.HasPrefix(report.Summary, "6 signatures from 2 servers, earliest expiry in "), report.Summary)
+
+	for _, sig := range report.Signatures {
+		if sig.Address == "192.0.2.2" {
+			assert.Equal(t, sig.Remaining.Round(time.Minute), 14*24*time.Hour)
+		}
+	}
+
+	str := expiry.ToString(report)
+	assert.Assert(t, strings.HasPrefix(str, "DNSSEC EXPIRY OK - "))
+	assert.Assert(t, strings.Contains(str, ";604800;259200\n"))
+}
+
+func TestWarning(t *testing.T) {
+	t.Parallel()
+
+	report := check(t, map[string]time.Duration{"192.0.2.1": 30 * 24 * time.Hour, "192.0.2.2": 5 * 24 * time.Hour})
+
+	assert.Equal(t, report.State, expiry.Warning)
+	assert.Assert(t, strings.Contains(report.Summary, "on ns2.example.com. (192.0.2.2) expires in "), report.Summary)
+}
+
+func TestCritical(t *testing.T) {
+	t.Parallel()
+
+	report := check(t, map[string]time.Duration{"192.0.2.1": -time.Hour, "192.0.2.2": 5 * 24 * time.Hour})
+
+	assert.Equal(t, report.State, expiry.Critical)
+	assert.Assert(t, strings.Contains(report.Summary, "(192.0.2.1) expired 1h0m"), report.Summary)
+}
+
+func TestUnknown(t *testing.T) {
+	t.Parallel()
+
+	report := check(t, map[string]time.Duration{"192.0.2.1": 30 * 24 * time.Hour})
+
+	assert.Equal(t, report.State, expiry.Unknown)
+	assert.Equal(t, len(report.Failures), 1)
+	assert.Equal(t, report.Failures[0].Server, "ns2.example.com.")
+
+	opts := &util.Options{
+		Logger:  util.InitLogger(0),
+		Request: util.Request{Name: "www.example.com", Type: dns.TypeA},
+	}
+
+	_, err := expiry.NewWith(opts, network(nil).Exchange).Check()
+	assert.ErrorContains(t, err, "finding servers")
+}
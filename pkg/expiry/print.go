@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package expiry
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ToString prints a report in the format expected from Nagios plugins: a status
+// line with performance data, followed by every signature checked.
+func ToString(r *Report) string {
+	var s strings.Builder
+
+	fmt.Fprintf(&s, "DNSSEC EXPIRY %s - %s", r.State, r.Summary)
+
+	if len(r.Signatures) > 0 {
+		earliest := r.Signatures[0].Remaining
+		for _, sig := range r.Signatures {
+			if sig.Remaining < earliest {
+				earliest = sig.Remaining
+			}
+		}
+
+		fmt.Fprintf(&s, " | expiry=%ds;%d;%d", int64(earliest.Seconds()),
+			int64(r.Warning.Seconds()), int64(r.Critical.Seconds()))
+	}
+
+	for _, f := range r.Failures {
+		fmt.Fprintf(&s, "\n%s %s (%s): %s", Unknown, f.Server, f.Address, f.Error)
+	}
+
+	for _, sig := range r.Signatures {
+		fmt.Fprintf(&s, "\n%s %s (%s): %s %s key %d, %s to %s, expires in %s",
+			sig.State, sig.Server, sig.Address, sig.Name, sig.Type, sig.KeyTag,
+			sig.Inception.Format(time.RFC3339), sig.Expiry.Format(time.RFC3339), sig.Remaining)
+	}
+
+	return s.String()
+}
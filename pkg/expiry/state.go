@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package expiry
+
+// State is the result of a check, with the values being Nagios plugin exit codes.
+type State int
+
+const (
+	// OK means every signature is valid for longer than the warning threshold.
+	OK State = iota
+	// Warning means a signature expires within the warning threshold.
+	Warning
+	// Critical means a signature expires within the critical threshold, has expired
+	// or is not yet valid.
+	Critical
+	// Unknown means that a server could not be checked.
+	Unknown
+)
+
+// String returns the name of the state, as Nagios would print it.
+func (s State) String() string {
+	switch s {
+	case OK:
+		return "OK"
+	case Warning:
+		return "WARNING"
+	case Critical:
+		return "CRITICAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// MarshalText makes the state print as its name in JSON, XML and YAML.
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// severity orders states from best to worst.
+func (s State) severity() int {
+	switch s {
+	case OK:
+		return 0
+	case Unknown:
+		return 1
+	case Warning:
+		return 2
+	default:
+		return 3
+	}
+}
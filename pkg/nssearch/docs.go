@@ -0,0 +1,11 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+/*
+Package nssearch checks that every authoritative server of a zone gives the
+same answers, like dig +nssearch.
+
+Every address of every name server of the zone is queried for the SOA record of
+the zone and for the requested name and type. Servers that are unreachable, lame,
+not authoritative, or give a different serial or answers than the others are reported.
+*/
+package nssearch
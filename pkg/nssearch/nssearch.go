@@ -0,0 +1,286 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package nssearch
+
+import (
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"dns.froth.zone/awl/pkg/query"
+	"dns.froth.zone/awl/pkg/trace"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+)
+
+// Problems a server can have.
+const (
+	// Unreachable means the server has no address or could not be queried.
+	Unreachable = "unreachable"
+	// Lame means the server does not serve the zone.
+	Lame = "lame"
+	// NotAuthoritative means the server gave the SOA record of the zone without the AA bit.
+	NotAuthoritative = "not authoritative"
+	// SerialMismatch means the serial differs from the one most servers gave.
+	SerialMismatch = "serial mismatch"
+	// AnswersDiffer means the rcode or answers differ from the ones most servers gave.
+	AnswersDiffer = "different answers"
+)
+
+// Result is the outcome of querying every authoritative server of a zone.
+//
+//nolint:govet // Better looking output is worth a few bytes.
+type Result struct {
+	XMLName xml.Name `json:"-" xml:"nssearch" yaml:"-"`
+	// Zone the requested name is in
+	Zone string `json:"zone" xml:"zone" yaml:"zone" example:"example.com."`
+	Name string `json:"name" xml:"name" yaml:"name" example:"www.example.com."`
+	Type string `json:"type" xml:"type" yaml:"type" example:"A"`
+	// Serial given by most servers
+	Serial uint32 `json:"serial" xml:"serial" yaml:"serial" example:"2024010101"`
+	// Whether no server has any problem
+	Consistent bool `json:"consistent" xml:"consistent" yaml:"consistent" example:"true"`
+	// Every address of every name server, sorted by name
+	Servers []Server `json:"servers" xml:"server" yaml:"servers"`
+}
+
+// Server is what a single address of a name server answered.
+//
+//nolint:govet // Better looking output is worth a few bytes.
+type Server struct {
+	Name    string `json:"name" xml:"name" yaml:"name" example:"ns1.example.com."`
+	Address string `json:"address,omitempty" xml:"address,omitempty" yaml:"address,omitempty" example:"192.0.2.1"`
+	// Serial of the SOA record given
+	Serial        uint32 `json:"serial,omitempty" xml:"serial,omitempty" yaml:"serial,omitempty" example:"2024010101"`
+	Authoritative bool   `json:"authoritative" xml:"authoritative" yaml:"authoritative" example:"true"`
+	// Rcode of the response to the requested name and type
+	Rcode string `json:"rcode,omitempty" xml:"rcode,omitempty" yaml:"rcode,omitempty" example:"NOERROR"`
+	// Answer records, sorted and without TTLs
+	Answers []string `json:"answers,omitempty" xml:"answer,omitempty" yaml:"answers,omitempty"`
+	// Round trip time of the SOA query
+	RTT time.Duration `json:"rtt,omitempty" xml:"rtt,omitempty" yaml:"rtt,omitempty" example:"2000000"`
+	// Problems found with the server, empty when there are none
+	Problems []string `json:"problems,omitempty" xml:"problem,omitempty" yaml:"problems,omitempty"`
+	// Why the server could not be queried
+	Error string `json:"error,omitempty" xml:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Checker queries every authoritative server of a zone.
+type Checker struct {
+	opts   *util.Options
+	tracer *trace.Tracer
+}
+
+// New creates a new Checker from the options given.
+func New(opts *util.Options) *Checker {
+	return NewWith(opts, query.CreateQuery)
+}
+
+// NewWith creates a new Checker making every query with exchange, as with [trace.NewWith].
+func NewWith(opts *util.Options, exchange func(*util.Options) (util.Response, error)) *Checker {
+	return &Checker{
+		opts:   opts,
+		tracer: trace.NewWith(opts, exchange),
+	}
+}
+
+// Search queries every authoritative server of the zone the requested name is in.
+func Search(opts *util.Options) (*Result, error) {
+	return New(opts).Search()
+}
+
+// Search finds every name server of the zone the requested name is in, and
+// queries every one of their addresses, at the same time, for the SOA record
+// of the zone and the requested name and type.
+//
+// The serial, rcode and answers of every server are compared to the ones
+// given by most servers. The order of records and their TTLs are ignored.
+func (c *Checker) Search() (*Result, error) {
+	zone, srvs, err := c.tracer.Authoritative(c.opts.Request.Name)
+	if err != nil {
+		return nil, fmt.Errorf("finding servers: %w", err)
+	}
+
+	res := &Result{
+		Zone: zone,
+		Name: dns.Fqdn(c.opts.Request.Name),
+		Type: dns.TypeToString[c.opts.Request.Type],
+	}
+
+	for _, srv := range srvs {
+		addrs := c.addresses(srv)
+		if len(addrs) == 0 {
+			res.Servers = append(res.Servers, Server{
+				Name:     srv.Name,
+				Problems: []string{Unreachable},
+				Error:    errNoAddress.Error(),
+			})
+
+			continue
+		}
+
+		for _, addr := range addrs {
+			res.Servers = append(res.Servers, Server{Name: srv.Name, Address: addr})
+		}
+	}
+
+	slices.SortFunc(res.Servers, func(a, b Server) int {
+		return cmp.Or(strings.Compare(dns.CanonicalName(a.Name), dns.CanonicalName(b.Name)), strings.Compare(a.Address, b.Address))
+	})
+
+	var wg sync.WaitGroup
+
+	for i := range res.Servers {
+		if res.Servers[i].Address == "" {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			c.server(zone, &res.Servers[i])
+		}()
+	}
+
+	wg.Wait()
+
+	c.compare(res)
+
+	return res, nil
+}
+
+// addresses finds every address of a name server allowed by -4 and -6, along with its glue.
+func (c *Checker) addresses(srv trace.Server) (addrs []string) {
+	found, err := c.tracer.Addrs(srv.Name)
+	if err != nil {
+		c.opts.Logger.Warn("Unable to resolve", srv.Name, "error:", err)
+	}
+
+	for _, addr := range c.tracer.Filter(append(slices.Clone(srv.Addrs), found...)) {
+		if !slices.Contains(addrs, addr) {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	return addrs
+}
+
+// server queries a single address of a name server.
+func (c *Checker) server(zone string, s *Server) {
+	resp, err := c.tracer.QueryAddr(s.Address, zone, dns.TypeSOA)
+	if err != nil {
+		s.Problems = append(s.Problems, Unreachable)
+		s.Error = fmt.Sprintf("%s SOA: %s", zone, err)
+
+		return
+	}
+
+	s.RTT = resp.RTT
+	s.Authoritative = resp.DNS.Authoritative
+
+	hasSOA := false
+
+	for _, rr := range resp.DNS.Answer {
+		if soa, ok := rr.(*dns.SOA); ok && util.EqualNames(soa.Hdr.Name, zone) {
+			s.Serial = soa.Serial
+			hasSOA = true
+		}
+	}
+
+	switch {
+	case resp.DNS.Rcode != dns.RcodeSuccess || !hasSOA:
+		s.Problems = append(s.Problems, Lame)
+
+		return
+	case !resp.DNS.Authoritative:
+		s.Problems = append(s.Problems, NotAuthoritative)
+	}
+
+	name, qtype := dns.Fqdn(c.opts.Request.Name), c.opts.Request.Type
+
+	// The SOA record of the zone has already been asked for
+	if !(util.EqualNames(name, zone) && qtype == dns.TypeSOA) {
+		resp, err = c.tracer.QueryAddr(s.Address, name, qtype)
+		if err != nil {
+			s.Problems = append(s.Problems, Unreachable)
+			s.Error = fmt.Sprintf("%s %s: %s", name, dns.TypeToString[qtype], err)
+
+			return
+		}
+	}
+
+	s.Rcode = dns.RcodeToString[resp.DNS.Rcode]
+
+	for _, rr := range resp.DNS.Answer {
+		s.Answers = append(s.Answers, util.WithoutTTL(rr))
+	}
+
+	slices.Sort(s.Answers)
+}
+
+// compare marks the servers whose serial or answers differ from the ones most servers gave.
+func (c *Checker) compare(res *Result) {
+	serials := make(map[uint32]int)
+	answers := make(map[string]int)
+
+	for _, s := range res.Servers {
+		if s.answered() {
+			serials[s.Serial]++
+			answers[s.key()]++
+		}
+	}
+
+	res.Serial = most(serials)
+	common := most(answers)
+	res.Consistent = len(res.Servers) > 0
+
+	for i := range res.Servers {
+		s := &res.Servers[i]
+
+		if s.answered() {
+			if s.Serial != res.Serial {
+				s.Problems = append(s.Problems, SerialMismatch)
+			}
+
+			if s.key() != common {
+				s.Problems = append(s.Problems, AnswersDiffer)
+			}
+		}
+
+		if len(s.Problems) > 0 {
+			res.Consistent = false
+		}
+	}
+}
+
+// answered reports whether the server gave both the SOA record and an answer.
+func (s Server) answered() bool {
+	return s.Error == "" && !slices.Contains(s.Problems, Lame)
+}
+
+// key is the rcode and answers of a server, to compare them with other servers.
+func (s Server) key() string {
+	return s.Rcode + "\n" + strings.Join(s.Answers, "\n")
+}
+
+// most returns the value counted the most often, the greatest value breaking ties.
+func most[K cmp.Ordered](counts map[K]int) (ret K) {
+	best := 0
+
+	for val, n := range counts {
+		if n > best || (n == best && val > ret) {
+			ret, best = val, n
+		}
+	}
+
+	return ret
+}
+
+var errNoAddress = errors.New("no addresses found")
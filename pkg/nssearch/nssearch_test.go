@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package nssearch_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"dns.froth.zone/awl/pkg/dnstest"
+	"dns.froth.zone/awl/pkg/nssearch"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+// example is example.com. with the serial and www.example.com. records given.
+// ns1 has an IPv6 address missing from the glue, and ns3 has no address.
+func example(serial int, www ...string) dnstest.Zone {
+	return dnstest.Zone{Origin: "example.com.", Records: append([]string{
+		fmt.Sprintf("example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. %d 7200 3600 1209600 3600", serial),
+		"example.com. 3600 IN NS ns1.example.com.",
+		"example.com. 3600 IN NS ns2.example.com.",
+		"example.com. 3600 IN NS ns3.example.com.",
+		"ns1.example.com. 3600 IN A 192.0.2.1",
+		"ns1.example.com. 3600 IN AAAA 2001:db8::1",
+		"ns2.example.com. 3600 IN A 192.0.2.2",
+	}, www...)}
+}
+
+func search(t *testing.T, servers dnstest.Network) *nssearch.Result {
+	t.Helper()
+
+	network := dnstest.Network{
+		"198.41.0.4": {Origin: ".", Records: []string{
+			"com. 172800 IN NS a.gtld-servers.net.",
+			"a.gtld-servers.net. 172800 IN A 192.0.2.30",
+		}},
+		"192.0.2.30": {Origin: "com.", Records: []string{
+			"example.com. 172800 IN NS ns2.example.com.",
+			"example.com. 172800 IN NS ns1.example.com.",
+			"example.com. 172800 IN NS ns3.example.com.",
+			"ns1.example.com. 172800 IN A 192.0.2.1",
+			"ns2.example.com. 172800 IN A 192.0.2.2",
+		}},
+	}
+
+	for addr, zone := range servers {
+		network[addr] = zone
+	}
+
+	opts := &util.Options{
+		Logger:  util.InitLogger(0),
+		Request: util.Request{Name: "www.example.com", Type: dns.TypeA},
+	}
+
+	res, err := nssearch.NewWith(opts, network.Exchange).Search()
+	assert.NilError(t, err)
+
+	return res
+}
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+
+	a := "www.example.com. 300 IN A 192.0.2.80"
+	b := "www.example.com. 300 IN A 192.0.2.81"
+
+	res := search(t, dnstest.Network{
+		"192.0.2.1":   example(2, a, b),
+		"2001:db8::1": example(2, b, a),
+		"192.0.2.2":   example(2, a, b),
+	})
+
+	assert.Equal(t, res.Zone, "example.com.")
+	assert.Equal(t, res.Name, "www.example.com.")
+	assert.Equal(t, res.Serial, uint32(2))
+	// ns3 has no address
+	assert.Assert(t, !res.Consistent)
+	assert.Equal(t, len(res.Servers), 4)
+	assert.Equal(t, res.Servers[0].Address, "192.0.2.1")
+	assert.Equal(t, res.Servers[1].Address, "2001:db8::1")
+	assert.DeepEqual(t, res.Servers[1].Answers, res.Servers[0].Answers)
+	assert.Equal(t, len(res.Servers[2].Problems), 0)
+	assert.DeepEqual(t, res.Servers[3].Problems, []string{nssearch.Unreachable})
+	assert.Equal(t, res.Servers[3].Error, "no addresses found")
+
+	str := nssearch.ToString(res)
+	assert.Assert(t, strings.HasPrefix(str, ";; www.example.com. A on 4 servers of example.com., serial 2: 1 with problems\n"), str)
+	assert.Assert(t, strings.HasSuffix(str, ";; ns3.example.com. (-): no addresses found"), str)
+	assert.Assert(t, !strings.Contains(str, ";; NOERROR from"), str)
+}
+
+func TestProblems(t *testing.T) {
+	t.Parallel()
+
+	a := "www.example.com. 300 IN A 192.0.2.80"
+
+	// No A records, so NODATA
+	noAA := example(1, "www.example.com. 300 IN AAAA 2001:db8::80")
+	noAA.NoAA = true
+
+	res := search(t, dnstest.Network{
+		"192.0.2.1":   example(2, a),
+		"2001:db8::1": noAA,
+		"192.0.2.2":   {Rcode: dns.RcodeRefused},
+	})
+
+	assert.Assert(t, !res.Consistent)
+	// Ties go to the greatest serial
+	assert.Equal(t, res.Serial, uint32(2))
+	assert.Equal(t, len(res.Servers[0].Problems), 0)
+	assert.DeepEqual(t, res.Servers[1].Problems, []string{nssearch.NotAuthoritative, nssearch.SerialMismatch, nssearch.AnswersDiffer})
+	assert.DeepEqual(t, res.Servers[2].Problems, []string{nssearch.Lame})
+
+	str := nssearch.ToString(res)
+	assert.Assert(t, strings.Contains(str, ";; NOERROR from ns1.example.com. (192.0.2.1):\nwww.example.com.\tIN\tA\t192.0.2.80\n"), str)
+	assert.Assert(t, strings.Contains(str, ";; NOERROR from ns1.example.com. (2001:db8::1):\n"), str)
+
+	for _, line := range strings.Split(str, "\n") {
+		if strings.HasPrefix(line, "ns2.example.com.") {
+			assert.DeepEqual(t, strings.Fields(line), []string{"ns2.example.com.", "192.0.2.2", "-", "-", "-", "-", "1ms", "lame"})
+		}
+	}
+}
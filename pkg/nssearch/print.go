@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package nssearch
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// ToString prints a table of every server, followed by the answers given when
+// servers disagree on them.
+func ToString(res *Result) string {
+	var buf strings.Builder
+
+	summary := "consistent"
+
+	if !res.Consistent {
+		bad := 0
+
+		for _, s := range res.Servers {
+			if len(s.Problems) > 0 {
+				bad++
+			}
+		}
+
+		summary = fmt.Sprintf("%d with problems", bad)
+	}
+
+	fmt.Fprintf(&buf, ";; %s %s on %d servers of %s, serial %d: %s\n",
+		res.Name, res.Type, len(res.Servers), res.Zone, res.Serial, summary)
+
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "server\taddress\tserial\taa\trcode\tanswers\ttime\tproblems")
+
+	for _, s := range res.Servers {
+		row := []string{s.Name, orDash(s.Address), "-", "-", orDash(s.Rcode), "-", "-", orDash(strings.Join(s.Problems, ", "))}
+
+		if s.Error == "" && s.Address != "" {
+			row[6] = s.RTT.String()
+
+			if !slices.Contains(s.Problems, Lame) {
+				row[2] = strconv.FormatUint(uint64(s.Serial), 10)
+				row[3] = yesNo(s.Authoritative)
+				row[5] = strconv.Itoa(len(s.Answers))
+			}
+		}
+
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+
+	w.Flush() //nolint:errcheck,gosec // Writes to a strings.Builder
+
+	// Every set of answers given, along with who gave them
+	if slices.ContainsFunc(res.Servers, func(s Server) bool { return slices.Contains(s.Problems, AnswersDiffer) }) {
+		var keys []string
+
+		for _, s := range res.Servers {
+			if s.answered() && !slices.Contains(keys, s.key()) {
+				keys = append(keys, s.key())
+			}
+		}
+
+		for _, key := range keys {
+			var (
+				from    []string
+				answers []string
+				rcode   string
+			)
+
+			for _, s := range res.Servers {
+				if s.answered() && s.key() == key {
+					from = append(from, fmt.Sprintf("%s (%s)", s.Name, s.Address))
+					rcode, answers = s.Rcode, s.Answers
+				}
+			}
+
+			fmt.Fprintf(&buf, ";; %s from %s:\n", rcode, strings.Join(from, ", "))
+
+			for _, rr := range answers {
+				buf.WriteString(rr + "\n")
+			}
+		}
+	}
+
+	for _, s := range res.Servers {
+		if s.Error != "" {
+			fmt.Fprintf(&buf, ";; %s (%s): %s\n", s.Name, orDash(s.Address), s.Error)
+		}
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+
+	return "no"
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
@@ -0,0 +1,12 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+/*
+Package pcap writes the DNS messages exchanged with servers as packets in the
+pcap format, so they can be read by Wireshark or tcpdump.
+
+The packets are made up from the messages, not captured. Messages sent over
+encrypted transports are written in the clear, as DNS over TCP to port 53.
+
+Captures can also be read back, eg. to replay the queries in them.
+*/
+package pcap
@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// IP protocol numbers
+const (
+	protoTCP = 6
+	protoUDP = 17
+)
+
+// TCP flags
+const (
+	flagSYN = 0x02
+	flagPSH = 0x08
+	flagACK = 0x10
+)
+
+// ip puts an IPv4 or IPv6 header in front of a UDP or TCP packet.
+func ip(src, dst netip.Addr, proto byte, id uint16, transport []byte) ([]byte, error) {
+	var hdr []byte
+
+	if src.Is4() {
+		if 20+len(transport) > 0xFFFF {
+			return nil, fmt.Errorf("pcap: %w", errTooLong)
+		}
+
+		hdr = make([]byte, 20)
+		hdr[0] = 0x45
+		binary.BigEndian.PutUint16(hdr[2:], uint16(20+len(transport))) //nolint:gosec // Checked above
+		binary.BigEndian.PutUint16(hdr[4:], id)
+		// Don't fragment
+		hdr[6] = 0x40
+		hdr[8] = 64
+		hdr[9] = proto
+		copy(hdr[12:], src.AsSlice())
+		copy(hdr[16:], dst.AsSlice())
+		binary.BigEndian.PutUint16(hdr[10:], ^sum(0, hdr))
+	} else {
+		if len(transport) > 0xFFFF {
+			return nil, fmt.Errorf("pcap: %w", errTooLong)
+		}
+
+		hdr = make([]byte, 40)
+		hdr[0] = 0x60
+		binary.BigEndian.PutUint16(hdr[4:], uint16(len(transport))) //nolint:gosec // Checked above
+		hdr[6] = proto
+		hdr[7] = 64
+		copy(hdr[8:], src.AsSlice())
+		copy(hdr[24:], dst.AsSlice())
+	}
+
+	// The checksum of UDP and TCP covers part of the IP header
+	csum := ^sum(pseudo(src, dst, proto, len(transport)), transport)
+	if proto == protoUDP && csum == 0 {
+		csum = 0xFFFF
+	}
+
+	off := 16
+	if proto == protoUDP {
+		off = 6
+	}
+
+	binary.BigEndian.PutUint16(transport[off:], csum)
+
+	return append(hdr, transport...), nil
+}
+
+// udp makes a UDP packet, without its checksum.
+func udp(src, dst netip.AddrPort, payload []byte) []byte {
+	hdr := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint16(hdr[0:], src.Port())
+	binary.BigEndian.PutUint16(hdr[2:], dst.Port())
+	binary.BigEndian.PutUint16(hdr[4:], uint16(8+len(payload))) //nolint:gosec // DNS messages are under 64k
+
+	return append(hdr, payload...)
+}
+
+// tcp makes a TCP segment carrying data, without its checksum.
+func tcp(src, dst netip.AddrPort, seq, ack uint32, payload []byte) []byte {
+	hdr := make([]byte, 20, 20+len(payload))
+	binary.BigEndian.PutUint16(hdr[0:], src.Port())
+	binary.BigEndian.PutUint16(hdr[2:], dst.Port())
+	binary.BigEndian.PutUint32(hdr[4:], seq)
+	binary.BigEndian.PutUint32(hdr[8:], ack)
+	// Header length in 32 bit words
+	hdr[12] = 5 << 4
+	hdr[13] = flagPSH | flagACK
+	binary.BigEndian.PutUint16(hdr[14:], 0xFFFF)
+
+	return append(hdr, payload...)
+}
+
+// pseudo sums the pseudo-header used by UDP and TCP checksums.
+//
+// The IPv4 and IPv6 pseudo-headers have the same sum, as the order of the
+// words doesn't matter and the length is always under 64k.
+func pseudo(src, dst netip.Addr, proto byte, length int) uint32 {
+	buf := append(src.AsSlice(), dst.AsSlice()...)
+	buf = append(buf, 0, proto)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(length)) //nolint:gosec // Under 64k
+
+	return uint32(sum(0, buf))
+}
+
+// sum adds up 16 bit words, folded into 16 bits, for an internet checksum.
+func sum(initial uint32, buf []byte) uint16 {
+	total := initial
+
+	for i := 0; i+1 < len(buf); i += 2 {
+		total += uint32(binary.BigEndian.Uint16(buf[i:]))
+	}
+
+	if len(buf)%2 == 1 {
+		total += uint32(buf[len(buf)-1]) << 8
+	}
+
+	for total > 0xFFFF {
+		total = (total >> 16) + (total & 0xFFFF)
+	}
+
+	return uint16(total) //nolint:gosec // Folded above
+}
+
+var errTooLong = errors.New("packet too long")
@@ -0,0 +1,231 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package pcap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+)
+
+const (
+	// Magic number of pcap files with timestamps in microseconds
+	magic = 0xa1b2c3d4
+	// LINKTYPE_RAW, packets start at the IP header
+	linkTypeRaw = 101
+	// Largest packet written
+	snapLen = 0xFFFF
+	// Largest TCP payload in a single packet
+	maxSegment = snapLen - 60
+)
+
+// Writer writes exchanges to a pcap file. It is a [util.Recorder].
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+	c  io.Closer
+	// Sequence numbers of every TCP connection seen
+	seqs map[[2]netip.AddrPort]*[2]uint32
+	// Last port used when the local one isn't known
+	port uint16
+	id   uint16
+}
+
+var _ util.Recorder = (*Writer)(nil)
+
+// Create creates a pcap file.
+func Create(path string) (*Writer, error) {
+	file, err := os.Create(path) //nolint:gosec // Writing files is the point.
+	if err != nil {
+		return nil, fmt.Errorf("pcap: %w", err)
+	}
+
+	w, err := NewWriter(file)
+	if err != nil {
+		file.Close()
+
+		return nil, err
+	}
+
+	w.c = file
+
+	return w, nil
+}
+
+// NewWriter writes the pcap header, ready for exchanges to be recorded.
+func NewWriter(w io.Writer) (*Writer, error) {
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr[0:], magic)
+	binary.LittleEndian.PutUint16(hdr[4:], 2)
+	binary.LittleEndian.PutUint16(hdr[6:], 4)
+	binary.LittleEndian.PutUint32(hdr[16:], snapLen)
+	binary.LittleEndian.PutUint32(hdr[20:], linkTypeRaw)
+
+	if _, err := w.Write(hdr); err != nil {
+		return nil, fmt.Errorf("pcap: %w", err)
+	}
+
+	return &Writer{
+		w:    w,
+		seqs: make(map[[2]netip.AddrPort]*[2]uint32),
+		port: 49152,
+	}, nil
+}
+
+// Record writes the query and response of an exchange as packets.
+func (w *Writer) Record(ex util.Exchange) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	local, remote := w.endpoints(ex)
+	conn := [2]netip.AddrPort{local, remote}
+
+	if w.seqs[conn] == nil {
+		w.seqs[conn] = new([2]uint32)
+	}
+
+	for dir, msg := range []struct {
+		msg      *dns.Msg
+		when     time.Time
+		src, dst netip.AddrPort
+	}{
+		{ex.Query, ex.QueryTime, local, remote},
+		{ex.Response, ex.ResponseTime, remote, local},
+	} {
+		if msg.msg == nil {
+			continue
+		}
+
+		buf, err := msg.msg.Pack()
+		if err != nil {
+			return fmt.Errorf("pcap: %w", err)
+		}
+
+		if ex.Protocol == "UDP" || ex.Protocol == "DNSCrypt" {
+			err = w.writePacket(msg.when, msg.src, msg.dst, protoUDP, udp(msg.src, msg.dst, buf))
+		} else {
+			err = w.writeTCP(msg.when, msg.src, msg.dst, w.seqs[conn], dir, buf)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close closes the file made by [Create].
+func (w *Writer) Close() error {
+	if w.c == nil {
+		return nil
+	}
+
+	if err := w.c.Close(); err != nil {
+		return fmt.Errorf("pcap: %w", err)
+	}
+
+	return nil
+}
+
+// endpoints finds the addresses to write an exchange between.
+//
+// Addresses that aren't known are made up on the loopback interface.
+// Encrypted transports are written as going to port 53, so they are shown as DNS.
+func (w *Writer) endpoints(ex util.Exchange) (netip.AddrPort, netip.AddrPort) {
+	local, remote := addrPort(ex.Local), addrPort(ex.Remote)
+
+	if !remote.IsValid() {
+		remote = netip.AddrPortFrom(netip.AddrFrom4([4]byte{127, 0, 0, 53}), 53)
+	}
+
+	if !local.IsValid() || local.Addr().Is4() != remote.Addr().Is4() {
+		addr := netip.IPv6Loopback()
+		if remote.Addr().Is4() {
+			addr = netip.AddrFrom4([4]byte{127, 0, 0, 1})
+		}
+
+		// Later messages of a transfer use the same connection
+		if ex.Query != nil {
+			w.port++
+		}
+
+		local = netip.AddrPortFrom(addr, w.port)
+	}
+
+	switch ex.Protocol {
+	case "UDP", "TCP":
+	default:
+		remote = netip.AddrPortFrom(remote.Addr(), 53)
+	}
+
+	return local, remote
+}
+
+// addrPort gets the address and port of a connection.
+func addrPort(addr net.Addr) netip.AddrPort {
+	var ret netip.AddrPort
+
+	switch addr := addr.(type) {
+	case *net.UDPAddr:
+		ret = addr.AddrPort()
+	case *net.TCPAddr:
+		ret = addr.AddrPort()
+	case nil:
+	default:
+		ret, _ = netip.ParseAddrPort(addr.String())
+	}
+
+	return netip.AddrPortFrom(ret.Addr().Unmap(), ret.Port())
+}
+
+// writeTCP writes a message with its length first, as it is sent over TCP.
+// seqs are the sequence numbers of the client and server, dir is who is sending.
+func (w *Writer) writeTCP(when time.Time, src, dst netip.AddrPort, seqs *[2]uint32, dir int, msg []byte) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(len(msg))) //nolint:gosec // DNS messages are under 64k
+	payload = append(payload, msg...)
+
+	for len(payload) > 0 {
+		segment := payload[:min(len(payload), maxSegment)]
+		payload = payload[len(segment):]
+
+		err := w.writePacket(when, src, dst, protoTCP, tcp(src, dst, seqs[dir], seqs[1-dir], segment))
+		if err != nil {
+			return err
+		}
+
+		seqs[dir] += uint32(len(segment)) //nolint:gosec // Segments are under 64k
+	}
+
+	return nil
+}
+
+// writePacket wraps a UDP or TCP packet in an IP header and writes it.
+func (w *Writer) writePacket(when time.Time, src, dst netip.AddrPort, proto byte, transport []byte) error {
+	w.id++
+
+	packet, err := ip(src.Addr(), dst.Addr(), proto, w.id, transport)
+	if err != nil {
+		return err
+	}
+
+	hdr := make([]byte, 16)
+	binary.LittleEndian.PutUint32(hdr[0:], uint32(when.Unix()))            //nolint:gosec // Fine until 2106
+	binary.LittleEndian.PutUint32(hdr[4:], uint32(when.Nanosecond()/1000)) //nolint:gosec // Under a million
+	binary.LittleEndian.PutUint32(hdr[8:], uint32(len(packet)))            //nolint:gosec // Under 64k
+	binary.LittleEndian.PutUint32(hdr[12:], uint32(len(packet)))           //nolint:gosec // Under 64k
+
+	if _, err := w.w.Write(append(hdr, packet...)); err != nil {
+		return fmt.Errorf("pcap: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,255 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package pcap_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dns.froth.zone/awl/pkg/pcap"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+// packet is a packet read back from a capture.
+type packet struct {
+	when     time.Time
+	src, dst net.IP
+	proto    byte
+	sport    uint16
+	dport    uint16
+	seq      uint32
+	payload  []byte
+}
+
+// checksum is 0 when the checksum in a buffer is right.
+func checksum(initial uint32, buf []byte) uint16 {
+	total := initial
+
+	for i := 0; i+1 < len(buf); i += 2 {
+		total += uint32(binary.BigEndian.Uint16(buf[i:]))
+	}
+
+	if len(buf)%2 == 1 {
+		total += uint32(buf[len(buf)-1]) << 8
+	}
+
+	for total > 0xFFFF {
+		total = (total >> 16) + (total & 0xFFFF)
+	}
+
+	return ^uint16(total)
+}
+
+func readPackets(t *testing.T, data []byte) []packet {
+	t.Helper()
+
+	assert.Equal(t, binary.LittleEndian.Uint32(data), uint32(0xa1b2c3d4))
+	// LINKTYPE_RAW
+	assert.Equal(t, binary.LittleEndian.Uint32(data[20:]), uint32(101))
+
+	var ret []packet
+
+	for off := 24; off < len(data); {
+		length := int(binary.LittleEndian.Uint32(data[off+8:]))
+		p := packet{when: time.Unix(int64(binary.LittleEndian.Uint32(data[off:])), int64(binary.LittleEndian.Uint32(data[off+4:]))*1000)}
+		buf := data[off+16 : off+16+length]
+		off += 16 + length
+
+		var transport []byte
+
+		pseudo := new(bytes.Buffer)
+
+		if buf[0]>>4 == 4 {
+			assert.Equal(t, checksum(0, buf[:20]), uint16(0))
+			p.src, p.dst, p.proto, transport = buf[12:16], buf[16:20], buf[9], buf[20:]
+		} else {
+			p.src, p.dst, p.proto, transport = buf[8:24], buf[24:40], buf[6], buf[40:]
+		}
+
+		pseudo.Write(p.src)
+		pseudo.Write(p.dst)
+		pseudo.Write([]byte{0, p.proto})
+		binary.Write(pseudo, binary.BigEndian, uint16(len(transport))) //nolint:errcheck,gosec // Only for tests
+
+		var sum uint32
+		for i := 0; i < pseudo.Len(); i += 2 {
+			sum += uint32(binary.BigEndian.Uint16(pseudo.Bytes()[i:]))
+		}
+
+		assert.Equal(t, checksum(sum, transport), uint16(0))
+
+		p.sport, p.dport = binary.BigEndian.Uint16(transport), binary.BigEndian.Uint16(transport[2:])
+
+		if p.proto == 6 {
+			p.seq = binary.BigEndian.Uint32(transport[4:])
+			// Skip the length of DNS over TCP
+			p.payload = transport[22:]
+		} else {
+			p.payload = transport[8:]
+		}
+
+		ret = append(ret, p)
+	}
+
+	return ret
+}
+
+func TestWriter(t *testing.T) {
+	t.Parallel()
+
+	query := new(dns.Msg)
+	query.SetQuestion("example.com.", dns.TypeA)
+
+	response := new(dns.Msg)
+	response.SetReply(query)
+
+	rr, err := dns.NewRR("example.com. 300 IN A 192.0.2.1")
+	assert.NilError(t, err)
+
+	response.Answer = []dns.RR{rr}
+
+	start := time.Unix(1700000000, 123000)
+
+	path := filepath.Join(t.TempDir(), "out.pcap")
+
+	w, err := pcap.Create(path)
+	assert.NilError(t, err)
+
+	for _, ex := range []util.Exchange{
+		{
+			Query: query, Response: response, QueryTime: start, ResponseTime: start.Add(time.Millisecond),
+			Protocol: "UDP",
+			Local:    &net.UDPAddr{IP: net.IPv4(192, 0, 2, 10), Port: 40000},
+			Remote:   &net.UDPAddr{IP: net.IPv4(192, 0, 2, 53), Port: 53},
+		},
+		{
+			Query: query, Response: response, QueryTime: start, ResponseTime: start,
+			Protocol: "TLS",
+			Local:    &net.TCPAddr{IP: net.ParseIP("2001:db8::10"), Port: 40001},
+			Remote:   &net.TCPAddr{IP: net.ParseIP("2001:db8::53"), Port: 853},
+		},
+		// The rest of a zone transfer
+		{
+			Response: response, ResponseTime: start,
+			Protocol: "TLS",
+			Local:    &net.TCPAddr{IP: net.ParseIP("2001:db8::10"), Port: 40001},
+			Remote:   &net.TCPAddr{IP: net.ParseIP("2001:db8::53"), Port: 853},
+		},
+		// Nothing known but the messages
+		{Query: query, Response: response, QueryTime: start, ResponseTime: start, Protocol: "HTTPS"},
+	} {
+		assert.NilError(t, w.Record(ex))
+	}
+
+	assert.NilError(t, w.Close())
+
+	data, err := os.ReadFile(path)
+	assert.NilError(t, err)
+
+	packets := readPackets(t, data)
+	assert.Equal(t, len(packets), 7)
+
+	for i, p := range packets {
+		msg := new(dns.Msg)
+		assert.NilError(t, msg.Unpack(p.payload), "packet %d", i)
+
+		want := query
+		if msg.Response {
+			want = response
+		}
+
+		assert.Equal(t, msg.String(), want.String(), "packet %d", i)
+	}
+
+	udp := packets[:2]
+	assert.Equal(t, udp[0].proto, byte(17))
+	assert.Assert(t, udp[0].when.Equal(start))
+	assert.Assert(t, udp[1].when.Equal(start.Add(time.Millisecond)))
+	assert.Equal(t, udp[0].src.String(), "192.0.2.10")
+	assert.Equal(t, udp[0].dport, uint16(53))
+	assert.Equal(t, udp[1].src.String(), "192.0.2.53")
+	assert.Equal(t, udp[1].dport, uint16(40000))
+
+	// TLS is written in the clear to port 53
+	tls := packets[2:5]
+	for _, p := range tls {
+		assert.Equal(t, p.proto, byte(6))
+		assert.Equal(t, len(p.src), net.IPv6len)
+	}
+
+	assert.Equal(t, tls[0].dport, uint16(53))
+	assert.Equal(t, tls[1].sport, uint16(53))
+	// Both responses are on the same connection
+	assert.Equal(t, tls[2].seq, tls[1].seq+uint32(len(tls[1].payload)+2))
+
+	assert.Equal(t, packets[5].src.String(), "127.0.0.1")
+	assert.Equal(t, packets[5].dst.String(), "127.0.0.53")
+	assert.Equal(t, packets[5].dport, uint16(53))
+}
+
+func TestRead(t *testing.T) {
+	t.Parallel()
+
+	query := new(dns.Msg)
+	query.SetQuestion("example.com.", dns.TypeA)
+
+	response := new(dns.Msg)
+	response.SetReply(query)
+	response.Rcode = dns.RcodeNameError
+
+	unanswered := new(dns.Msg)
+	unanswered.SetQuestion("example.net.", dns.TypeAAAA)
+
+	start := time.Unix(1700000000, 123000)
+
+	var buf bytes.Buffer
+
+	w, err := pcap.NewWriter(&buf)
+	assert.NilError(t, err)
+
+	for _, ex := range []util.Exchange{
+		{
+			Query: query, Response: response, QueryTime: start, ResponseTime: start.Add(time.Millisecond),
+			Protocol: "UDP",
+			Local:    &net.UDPAddr{IP: net.IPv4(192, 0, 2, 10), Port: 40000},
+			Remote:   &net.UDPAddr{IP: net.IPv4(192, 0, 2, 53), Port: 53},
+		},
+		{Query: unanswered, QueryTime: start.Add(time.Second), Protocol: "UDP"},
+		{
+			Query: query, Response: response, QueryTime: start.Add(2 * time.Second), ResponseTime: start.Add(3 * time.Second),
+			Protocol: "TLS",
+			Local:    &net.TCPAddr{IP: net.ParseIP("2001:db8::10"), Port: 40001},
+			Remote:   &net.TCPAddr{IP: net.ParseIP("2001:db8::53"), Port: 853},
+		},
+	} {
+		assert.NilError(t, w.Record(ex))
+	}
+
+	exs, err := pcap.Read(&buf)
+	assert.NilError(t, err)
+	assert.Equal(t, len(exs), 3)
+
+	assert.Equal(t, exs[0].Protocol, "UDP")
+	assert.Equal(t, exs[0].Query.Question[0].Name, "example.com.")
+	assert.Equal(t, exs[0].Response.Rcode, dns.RcodeNameError)
+	assert.Equal(t, exs[0].Local.String(), "192.0.2.10:40000")
+	assert.Assert(t, exs[0].ResponseTime.Equal(start.Add(time.Millisecond)))
+
+	assert.Equal(t, exs[1].Query.Question[0].Name, "example.net.")
+	assert.Assert(t, exs[1].Response == nil)
+
+	// Encrypted transports are written as TCP
+	assert.Equal(t, exs[2].Protocol, "TCP")
+	assert.Equal(t, exs[2].Remote.String(), "[2001:db8::53]:53")
+	assert.Equal(t, exs[2].Response.Id, query.Id)
+
+	_, err = pcap.Read(bytes.NewReader([]byte{0x0a, 0x0d, 0x0d, 0x0a, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}))
+	assert.ErrorContains(t, err, "pcapng")
+}
@@ -0,0 +1,391 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+)
+
+// Link types read, as well as LINKTYPE_RAW
+const (
+	linkTypeNull     = 0
+	linkTypeEthernet = 1
+	linkTypeLinuxSLL = 113
+	linkTypeIPv4     = 228
+	linkTypeIPv6     = 229
+	linkTypeSLL2     = 276
+)
+
+// Magic number of pcap files with timestamps in nanoseconds
+const magicNano = 0xa1b23c4d
+
+// Block type that starts a pcapng file
+const pcapngMagic = 0x0a0d0d0a
+
+// Port DNS is captured on
+const dnsPort = 53
+
+// ReadFile reads the DNS exchanges captured in a pcap file.
+func ReadFile(path string) ([]util.Exchange, error) {
+	file, err := os.Open(path) //nolint:gosec // Reading files is the point.
+	if err != nil {
+		return nil, fmt.Errorf("pcap: %w", err)
+	}
+	defer file.Close()
+
+	return Read(file)
+}
+
+// Read reads the DNS exchanges captured in pcap format, over UDP or TCP to port 53.
+//
+// Queries are paired with their responses by address, port and ID. Queries
+// without a response are kept, responses without a query are dropped.
+// Exchanges are given in the order the queries were sent.
+func Read(r io.Reader) ([]util.Exchange, error) {
+	hdr := make([]byte, 24)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, fmt.Errorf("pcap: %w", err)
+	}
+
+	var (
+		order binary.ByteOrder = binary.LittleEndian
+		unit                   = time.Microsecond
+	)
+
+	switch binary.LittleEndian.Uint32(hdr) {
+	case magic:
+	case magicNano:
+		unit = time.Nanosecond
+	case pcapngMagic:
+		return nil, fmt.Errorf("pcap: %w", errPcapng)
+	default:
+		order = binary.BigEndian
+
+		switch order.Uint32(hdr) {
+		case magic:
+		case magicNano:
+			unit = time.Nanosecond
+		default:
+			return nil, fmt.Errorf("pcap: %w", errNotPcap)
+		}
+	}
+
+	rd := &reader{
+		link:    order.Uint32(hdr[20:]) & 0x0FFFFFFF,
+		streams: make(map[[2]netip.AddrPort]*stream),
+		pending: make(map[key]int),
+	}
+
+	switch rd.link {
+	case linkTypeNull, linkTypeEthernet, linkTypeRaw, linkTypeLinuxSLL, linkTypeIPv4, linkTypeIPv6, linkTypeSLL2:
+	default:
+		return nil, fmt.Errorf("pcap: link type %d: %w", rd.link, errLinkType)
+	}
+
+	rec := make([]byte, 16)
+
+	for {
+		if _, err := io.ReadFull(r, rec); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return nil, fmt.Errorf("pcap: %w", err)
+		}
+
+		when := time.Unix(int64(order.Uint32(rec)), int64(order.Uint32(rec[4:]))*int64(unit))
+
+		length := order.Uint32(rec[8:])
+		if length > 0x40000 {
+			return nil, fmt.Errorf("pcap: %w", errTooLong)
+		}
+
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("pcap: %w", err)
+		}
+
+		rd.packet(when, data)
+	}
+
+	sort.SliceStable(rd.exs, func(i, j int) bool {
+		return rd.exs[i].QueryTime.Before(rd.exs[j].QueryTime)
+	})
+
+	return rd.exs, nil
+}
+
+// key finds the query a response is for.
+type key struct {
+	client, server netip.AddrPort
+	id             uint16
+}
+
+// stream is one direction of a TCP connection, put back together.
+type stream struct {
+	buf  []byte
+	next uint32
+	// When the data waiting in buf started to arrive
+	when time.Time
+}
+
+type reader struct {
+	link    uint32
+	streams map[[2]netip.AddrPort]*stream
+	// Index in exs of queries still waiting for a response
+	pending map[key]int
+	exs     []util.Exchange
+}
+
+// packet reads the DNS messages in a captured packet, skipping anything else.
+func (rd *reader) packet(when time.Time, data []byte) {
+	data, ok := rd.unwrap(data)
+	if !ok || len(data) < 1 {
+		return
+	}
+
+	var (
+		src, dst  netip.Addr
+		proto     byte
+		transport []byte
+	)
+
+	switch data[0] >> 4 {
+	case 4:
+		ihl := int(data[0]&0x0F) * 4
+		if len(data) < 20 || ihl < 20 || len(data) < ihl {
+			return
+		}
+
+		// Fragments are not put back together
+		if binary.BigEndian.Uint16(data[6:])&0x3FFF != 0 {
+			return
+		}
+
+		total := int(binary.BigEndian.Uint16(data[2:]))
+		if total < ihl || total > len(data) {
+			total = len(data)
+		}
+
+		src = netip.AddrFrom4([4]byte(data[12:16]))
+		dst = netip.AddrFrom4([4]byte(data[16:20]))
+		proto, transport = data[9], data[ihl:total]
+	case 6:
+		if len(data) < 40 {
+			return
+		}
+
+		end := min(40+int(binary.BigEndian.Uint16(data[4:])), len(data))
+
+		// Extension headers are not followed
+		src = netip.AddrFrom16([16]byte(data[8:24]))
+		dst = netip.AddrFrom16([16]byte(data[24:40]))
+		proto, transport = data[6], data[40:end]
+	default:
+		return
+	}
+
+	if len(transport) < 8 {
+		return
+	}
+
+	srcPort, dstPort := binary.BigEndian.Uint16(transport), binary.BigEndian.Uint16(transport[2:])
+	if srcPort != dnsPort && dstPort != dnsPort {
+		return
+	}
+
+	from, to := netip.AddrPortFrom(src, srcPort), netip.AddrPortFrom(dst, dstPort)
+
+	switch proto {
+	case protoUDP:
+		rd.message(when, when, from, to, "UDP", transport[8:])
+	case protoTCP:
+		rd.segment(when, from, to, transport)
+	}
+}
+
+// unwrap takes the link layer header off a packet.
+func (rd *reader) unwrap(data []byte) ([]byte, bool) {
+	var etherType uint16
+
+	switch rd.link {
+	case linkTypeRaw, linkTypeIPv4, linkTypeIPv6:
+		return data, true
+	case linkTypeNull:
+		if len(data) < 4 {
+			return nil, false
+		}
+
+		return data[4:], true
+	case linkTypeEthernet:
+		if len(data) < 14 {
+			return nil, false
+		}
+
+		etherType, data = binary.BigEndian.Uint16(data[12:]), data[14:]
+
+		// 802.1Q and 802.1ad tags
+		for (etherType == 0x8100 || etherType == 0x88A8) && len(data) >= 4 {
+			etherType, data = binary.BigEndian.Uint16(data[2:]), data[4:]
+		}
+	case linkTypeLinuxSLL:
+		if len(data) < 16 {
+			return nil, false
+		}
+
+		etherType, data = binary.BigEndian.Uint16(data[14:]), data[16:]
+	case linkTypeSLL2:
+		if len(data) < 20 {
+			return nil, false
+		}
+
+		etherType, data = binary.BigEndian.Uint16(data), data[20:]
+	}
+
+	return data, etherType == 0x0800 || etherType == 0x86DD
+}
+
+// segment adds a TCP segment to its stream, reading the messages it completes.
+func (rd *reader) segment(when time.Time, from, to netip.AddrPort, transport []byte) {
+	if len(transport) < 20 {
+		return
+	}
+
+	off := int(transport[12]>>4) * 4
+	if off < 20 || off > len(transport) {
+		return
+	}
+
+	seq, flags, payload := binary.BigEndian.Uint32(transport[4:]), transport[13], transport[off:]
+	conn := [2]netip.AddrPort{from, to}
+
+	s := rd.streams[conn]
+
+	switch {
+	case flags&flagSYN != 0:
+		rd.streams[conn] = &stream{next: seq + 1}
+
+		return
+	case s == nil:
+		// The start of the connection wasn't captured
+		s = &stream{next: seq}
+		rd.streams[conn] = s
+	}
+
+	// Skip what was already seen, start again after a gap
+	switch diff := int32(seq - s.next); { //nolint:gosec // Wraps on purpose
+	case diff < 0:
+		if int(-diff) >= len(payload) {
+			return
+		}
+
+		payload = payload[-diff:]
+	case diff > 0:
+		s.buf = nil
+	}
+
+	if len(payload) == 0 {
+		return
+	}
+
+	if len(s.buf) == 0 {
+		s.when = when
+	}
+
+	s.buf = append(s.buf, payload...)
+	s.next = seq + uint32(len(transport)-off) //nolint:gosec // Under 64k
+
+	for len(s.buf) >= 2 {
+		length := int(binary.BigEndian.Uint16(s.buf))
+		if len(s.buf) < 2+length {
+			break
+		}
+
+		rd.message(s.when, when, from, to, "TCP", s.buf[2:2+length])
+
+		s.buf = s.buf[2+length:]
+		s.when = when
+	}
+}
+
+// message pairs a DNS message with the query or response it goes with.
+//
+// start is when the first byte of the message was seen, end when the last was.
+func (rd *reader) message(start, end time.Time, from, to netip.AddrPort, protocol string, data []byte) {
+	msg := new(dns.Msg)
+	if err := msg.Unpack(data); err != nil {
+		return
+	}
+
+	addr := func(ap netip.AddrPort) net.Addr {
+		if protocol == "TCP" {
+			return net.TCPAddrFromAddrPort(ap)
+		}
+
+		return net.UDPAddrFromAddrPort(ap)
+	}
+
+	if !msg.Response {
+		rd.pending[key{from, to, msg.Id}] = len(rd.exs)
+		rd.exs = append(rd.exs, util.Exchange{
+			Query:     msg,
+			QueryTime: start,
+			Protocol:  protocol,
+			Local:     addr(from),
+			Remote:    addr(to),
+		})
+
+		return
+	}
+
+	k := key{to, from, msg.Id}
+
+	i, ok := rd.pending[k]
+	if !ok || !sameQuestion(rd.exs[i].Query, msg) {
+		return
+	}
+
+	delete(rd.pending, k)
+
+	rd.exs[i].Response = msg
+	rd.exs[i].ResponseTime = end
+}
+
+// sameQuestion checks if a response answers a query.
+func sameQuestion(query, response *dns.Msg) bool {
+	// Responses to some errors have no question
+	if len(response.Question) == 0 {
+		return true
+	}
+
+	if len(query.Question) != len(response.Question) {
+		return false
+	}
+
+	for i := range query.Question {
+		if !strings.EqualFold(query.Question[i].Name, response.Question[i].Name) ||
+			query.Question[i].Qtype != response.Question[i].Qtype {
+			return false
+		}
+	}
+
+	return true
+}
+
+var (
+	errNotPcap  = errors.New("not a pcap file")
+	errPcapng   = errors.New("pcapng is not supported, convert it with editcap -F pcap")
+	errLinkType = errors.New("link type not supported")
+)
@@ -0,0 +1,208 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package query
+
+import (
+	"strings"
+
+	"dns.froth.zone/awl/pkg/dnssec"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+)
+
+// ANSI escape codes
+const (
+	reset   = "\x1b[0m"
+	bold    = "\x1b[1m"
+	red     = "\x1b[31m"
+	green   = "\x1b[32m"
+	yellow  = "\x1b[33m"
+	blue    = "\x1b[34m"
+	magenta = "\x1b[35m"
+	cyan    = "\x1b[36m"
+)
+
+// paint colours a string, if colour is enabled.
+func paint(opts *util.Options, color, s string) string {
+	if !opts.Display.Color || s == "" {
+		return s
+	}
+
+	return color + s + reset
+}
+
+// rcodeColor is green for success, and red for anything that went wrong.
+func rcodeColor(rcode int) string {
+	switch rcode {
+	case dns.RcodeSuccess:
+		return green
+	case dns.RcodeNameError, dns.RcodeServerFailure, dns.RcodeRefused:
+		return bold + red
+	default:
+		return red
+	}
+}
+
+// statusColor colours a DNSSEC validation status.
+func statusColor(status dnssec.Status) string {
+	switch status {
+	case dnssec.Secure:
+		return green
+	case dnssec.Bogus:
+		return bold + red
+	default:
+		return yellow
+	}
+}
+
+// colorHeader colours the response code in the header.
+func colorHeader(hdr string, rcode int, opts *util.Options) string {
+	status := "status: " + dns.RcodeToString[rcode]
+
+	return strings.Replace(hdr, status, "status: "+paint(opts, rcodeColor(rcode), dns.RcodeToString[rcode]), 1)
+}
+
+// colorRR colours the name, TTL and type of a record already formatted by stringParse.
+func colorRR(str string, opts *util.Options) string {
+	if !opts.Display.Color {
+		return str
+	}
+
+	split := strings.Split(str, "\t")
+	if len(split) < 3 {
+		return str
+	}
+
+	split[0] = paint(opts, bold+blue, split[0])
+
+	if opts.Display.TTL {
+		split[1] = paint(opts, yellow, split[1])
+	}
+
+	// The type is always right before the RDATA
+	split[len(split)-2] = paint(opts, magenta, split[len(split)-2])
+
+	return strings.Join(split, "\t")
+}
+
+// highlightJSON colours JSON, keeping it formatted as it was.
+func highlightJSON(str string, opts *util.Options) string {
+	if !opts.Display.Color {
+		return str
+	}
+
+	var s strings.Builder
+
+	for i := 0; i < len(str); i++ {
+		switch c := str[i]; {
+		case c == '"':
+			end := i + 1
+			for ; end < len(str) && str[end] != '"'; end++ {
+				if str[end] == '\\' {
+					end++
+				}
+			}
+
+			end = min(end+1, len(str))
+
+			// Keys are followed by a colon
+			color := green
+			if rest := strings.TrimLeft(str[end:], " "); strings.HasPrefix(rest, ":") {
+				color = blue
+			}
+
+			s.WriteString(paint(opts, color, str[i:end]))
+
+			i = end - 1
+		case c == '-' || (c >= '0' && c <= '9') || c == 't' || c == 'f' || c == 'n':
+			end := i
+			for end < len(str) && strings.IndexByte(",]}\n ", str[end]) == -1 {
+				end++
+			}
+
+			s.WriteString(paint(opts, yellow, str[i:end]))
+
+			i = end - 1
+		default:
+			s.WriteByte(c)
+		}
+	}
+
+	return s.String()
+}
+
+// highlightYAML colours the keys of YAML.
+func highlightYAML(str string, opts *util.Options) string {
+	if !opts.Display.Color {
+		return str
+	}
+
+	lines := strings.Split(str, "\n")
+
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		indent := line[:len(line)-len(trimmed)]
+
+		if strings.HasPrefix(trimmed, "- ") {
+			indent += "- "
+			trimmed = trimmed[2:]
+		}
+
+		key, val, ok := strings.Cut(trimmed, ":")
+		if !ok || strings.ContainsAny(key, "\"'") || (val != "" && val[0] != ' ') {
+			continue
+		}
+
+		lines[i] = indent + paint(opts, blue, key) + ":" + paint(opts, green, val)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// highlightXML colours the tags of XML.
+func highlightXML(str string, opts *util.Options) string {
+	if !opts.Display.Color {
+		return str
+	}
+
+	var s strings.Builder
+
+	for {
+		start := strings.IndexByte(str, '<')
+		if start == -1 {
+			s.WriteString(str)
+
+			break
+		}
+
+		end := strings.IndexByte(str[start:], '>')
+		if end == -1 {
+			s.WriteString(str)
+
+			break
+		}
+
+		end += start + 1
+
+		s.WriteString(str[:start])
+		s.WriteString(paint(opts, blue, str[start:end]))
+
+		str = str[end:]
+	}
+
+	return s.String()
+}
+
+// highlight colours the output of a formatter, depending on its format.
+func highlight(format, str string, opts *util.Options) string {
+	switch format {
+	case "json", "ndjson", "rfc8427":
+		return highlightJSON(str, opts)
+	case "yaml":
+		return highlightYAML(str, opts)
+	case "xml":
+		return highlightXML(str, opts)
+	default:
+		return str
+	}
+}
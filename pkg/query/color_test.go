@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package query_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"dns.froth.zone/awl/pkg/query"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+// ansi matches the escape codes used for colours.
+var ansi = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func colorMsg(t *testing.T, rcode int) *dns.Msg {
+	t.Helper()
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.com.", dns.TypeA)
+	msg.Rcode = rcode
+
+	rr, err := dns.NewRR("example.com. 300 IN A 192.0.2.1")
+	assert.NilError(t, err)
+
+	msg.Answer = []dns.RR{rr}
+
+	return msg
+}
+
+func TestColor(t *testing.T) {
+	t.Parallel()
+
+	display := util.Display{Comments: true, Question: true, Answer: true, TTL: true, ShowClass: true}
+
+	opts := &util.Options{Logger: util.InitLogger(0), Display: display}
+	res := util.Response{DNS: colorMsg(t, dns.RcodeSuccess)}
+
+	plain, err := query.ToString(res, opts)
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(plain, "\x1b"), plain)
+
+	opts.Display.Color = true
+
+	colored, err := query.ToString(res, opts)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(colored, "status: \x1b[32mNOERROR\x1b[0m"), colored)
+	assert.Assert(t, strings.Contains(colored, "\x1b[1m;; ANSWER SECTION:\x1b[0m"), colored)
+	assert.Assert(t, strings.Contains(colored, "\x1b[1m\x1b[34mexample.com.\x1b[0m\t\x1b[33m300\x1b[0m\tIN\t\x1b[35mA\x1b[0m\t192.0.2.1"), colored)
+	assert.Equal(t, ansi.ReplaceAllString(colored, ""), plain)
+
+	colored, err = query.ToString(util.Response{DNS: colorMsg(t, dns.RcodeNameError)}, opts)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(colored, "status: \x1b[1m\x1b[31mNXDOMAIN\x1b[0m"), colored)
+
+	// +short is left alone
+	opts.Short = true
+
+	colored, err = query.ToString(res, opts)
+	assert.NilError(t, err)
+	assert.Equal(t, colored, "192.0.2.1")
+}
+
+func TestHighlight(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []string{"json", "xml", "yaml", "rfc8427", "ndjson"} {
+		t.Run(format, func(t *testing.T) {
+			t.Parallel()
+
+			opts := &util.Options{
+				Logger:  util.InitLogger(0),
+				Format:  format,
+				Display: util.Display{Answer: true, TTL: true, ShowClass: true},
+			}
+
+			res := util.Response{DNS: colorMsg(t, dns.RcodeSuccess)}
+
+			plain, err := query.PrintSpecial(res, opts)
+			assert.NilError(t, err)
+			assert.Assert(t, !strings.Contains(plain, "\x1b"), plain)
+
+			opts.Display.Color = true
+
+			colored, err := query.PrintSpecial(res, opts)
+			assert.NilError(t, err)
+			assert.Assert(t, strings.Contains(colored, "\x1b[34m"), colored)
+			assert.Equal(t, ansi.ReplaceAllString(colored, ""), plain)
+		})
+	}
+}
@@ -0,0 +1,227 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package query
+
+import (
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+)
+
+// Comparison is the same query sent to several servers.
+//
+//nolint:govet // Better looking output is worth a few bytes.
+type Comparison struct {
+	XMLName xml.Name `json:"-" xml:"comparison" yaml:"-"`
+	Name    string   `json:"name" xml:"name" yaml:"name" example:"example.com."`
+	Type    string   `json:"type" xml:"type" yaml:"type" example:"A"`
+	// Whether every server gave the same rcode, flags and answers
+	Same bool `json:"same" xml:"same" yaml:"same" example:"true"`
+	// What each server answered, in the order they were given
+	Servers []Compared `json:"servers" xml:"server" yaml:"servers"`
+}
+
+// Compared is what a single server answered.
+//
+//nolint:govet // Better looking output is worth a few bytes.
+type Compared struct {
+	// As it was given, eg. tls://dns.google
+	Server   string `json:"server" xml:"server" yaml:"server" example:"1.1.1.1"`
+	Protocol string `json:"protocol" xml:"protocol" yaml:"protocol" example:"UDP"`
+	Rcode    string `json:"rcode,omitempty" xml:"rcode,omitempty" yaml:"rcode,omitempty" example:"NOERROR"`
+	Flags    string `json:"flags,omitempty" xml:"flags,omitempty" yaml:"flags,omitempty" example:"qr rd ra"`
+	// Answer records, sorted and without TTLs
+	Answers []string      `json:"answers,omitempty" xml:"answer,omitempty" yaml:"answers,omitempty"`
+	RTT     time.Duration `json:"rtt,omitempty" xml:"rtt,omitempty" yaml:"rtt,omitempty" example:"2000000"`
+	// Why the server could not be queried
+	Error string `json:"error,omitempty" xml:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Compare sends the query to every server given, at the same time, and
+// compares their rcodes, flags and answers. The order of records and their
+// TTLs are ignored.
+func Compare(opts *util.Options) *Comparison {
+	res := &Comparison{
+		Name:    opts.Request.Name,
+		Type:    dns.TypeToString[opts.Request.Type],
+		Servers: make([]Compared, len(opts.Servers)),
+	}
+
+	var wg sync.WaitGroup
+
+	for i, srv := range opts.Servers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			res.Servers[i] = compareOne(util.ForServer(srv, opts), srv.Name)
+		}()
+	}
+
+	wg.Wait()
+
+	res.Same = len(res.Servers) > 0
+
+	for _, c := range res.Servers {
+		first := res.Servers[0]
+		if c.Error != "" || c.Rcode != first.Rcode || c.Flags != first.Flags || !slices.Equal(c.Answers, first.Answers) {
+			res.Same = false
+		}
+	}
+
+	return res
+}
+
+// compareOne queries a single server, retrying as the options say.
+func compareOne(opts *util.Options, name string) Compared {
+	ret := Compared{Server: name, Protocol: util.Protocol(opts)}
+
+	var (
+		resp util.Response
+		err  error
+	)
+
+	for i := 0; i <= opts.Request.Retries; i++ {
+		resp, err = CreateQuery(opts)
+		if err == nil {
+			break
+		}
+
+		opts.Logger.Info("Querying", name, "failed:", err)
+	}
+
+	if err != nil {
+		ret.Error = err.Error()
+
+		return ret
+	}
+
+	ret.Rcode = dns.RcodeToString[resp.DNS.Rcode]
+	ret.Flags = flagNames(resp.DNS.MsgHdr)
+	ret.RTT = resp.RTT
+
+	for _, rr := range resp.DNS.Answer {
+		ret.Answers = append(ret.Answers, util.WithoutTTL(rr))
+	}
+
+	slices.Sort(ret.Answers)
+
+	return ret
+}
+
+// CompareString prints a comparison side by side, one column for each server.
+// Rows that differ between servers start with !.
+func CompareString(res *Comparison, opts *util.Options) string {
+	var (
+		buf  strings.Builder
+		rows [][]string
+	)
+
+	header := []string{"", ""}
+	for _, c := range res.Servers {
+		header = append(header, c.Server)
+	}
+
+	row := func(name string, cell func(c Compared) string) {
+		r := []string{"", name}
+		for _, c := range res.Servers {
+			r = append(r, cell(c))
+		}
+
+		for _, val := range r[3:] {
+			if val != r[2] {
+				r[0] = "!"
+			}
+		}
+
+		rows = append(rows, r)
+	}
+
+	row("rcode", func(c Compared) string { return orDash(c.Rcode) })
+	row("flags", func(c Compared) string { return orDash(c.Flags) })
+
+	// Every record given by any server, sorted
+	var answers []string
+
+	for _, c := range res.Servers {
+		for _, rr := range c.Answers {
+			if !slices.Contains(answers, rr) {
+				answers = append(answers, rr)
+			}
+		}
+	}
+
+	slices.Sort(answers)
+
+	for _, rr := range answers {
+		row(strings.ReplaceAll(rr, "\t", " "), func(c Compared) string {
+			if slices.Contains(c.Answers, rr) {
+				return "yes"
+			}
+
+			return "no"
+		})
+	}
+
+	// Times always differ
+	times := []string{"", "time"}
+
+	for _, c := range res.Servers {
+		rtt := "-"
+		if c.Error == "" {
+			rtt = c.RTT.String()
+		}
+
+		times = append(times, rtt)
+	}
+
+	summary := "same"
+	if !res.Same {
+		summary = "different"
+	}
+
+	fmt.Fprintf(&buf, ";; %s %s, %s answers from %d servers\n", res.Name, res.Type, summary, len(res.Servers))
+
+	var table strings.Builder
+
+	w := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+
+	for _, r := range append(append([][]string{header}, rows...), times) {
+		fmt.Fprintln(w, strings.Join(r, "\t"))
+	}
+
+	w.Flush() //nolint:errcheck,gosec // Writes to a strings.Builder
+
+	// Coloured after lining up, as the escape codes have no width
+	for _, line := range strings.SplitAfter(table.String(), "\n") {
+		if strings.HasPrefix(line, "!") {
+			line = paint(opts, red, strings.TrimSuffix(line, "\n")) + "\n"
+		}
+
+		buf.WriteString(line)
+	}
+
+	for _, c := range res.Servers {
+		if c.Error != "" {
+			fmt.Fprintf(&buf, ";; %s: %s\n", c.Server, c.Error)
+		}
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
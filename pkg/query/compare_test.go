@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package query_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"dns.froth.zone/awl/pkg/query"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+// answering starts a local server giving the records, with a TTL that differs for every server.
+func answering(t *testing.T, ttl int, records ...string) util.Server {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NilError(t, err)
+
+	server := &dns.Server{
+		PacketConn: conn,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			msg := new(dns.Msg)
+			msg.SetReply(req)
+			msg.RecursionAvailable = true
+
+			for _, str := range records {
+				rr, _ := dns.NewRR(str)
+				rr.Header().Ttl = uint32(ttl) //nolint:gosec // Only for tests
+
+				msg.Answer = append(msg.Answer, rr)
+			}
+
+			w.WriteMsg(msg) //nolint:errcheck,gosec // Only for tests
+		}),
+	}
+
+	go server.ActivateAndServe() //nolint:errcheck // Only for tests
+
+	t.Cleanup(func() {
+		server.Shutdown() //nolint:errcheck,gosec // Only for tests
+	})
+
+	addr := conn.LocalAddr().(*net.UDPAddr)
+
+	return util.Server{Name: addr.String(), Address: "127.0.0.1", Port: addr.Port}
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	a := "example.com. 300 IN A 192.0.2.1"
+	b := "example.com. 300 IN A 192.0.2.2"
+
+	opts := &util.Options{
+		Logger:      util.InitLogger(0),
+		HeaderFlags: util.HeaderFlags{RD: true},
+		Request: util.Request{
+			Type:    dns.TypeA,
+			Class:   dns.ClassINET,
+			Name:    "example.com.",
+			Timeout: time.Second,
+		},
+		// The same answers in another order and with other TTLs are the same
+		Servers: []util.Server{answering(t, 300, a, b), answering(t, 42, b, a)},
+	}
+
+	res := query.Compare(opts)
+	assert.Assert(t, res.Same)
+	assert.Equal(t, res.Servers[0].Rcode, "NOERROR")
+	assert.Equal(t, res.Servers[0].Flags, "qr rd ra")
+	assert.DeepEqual(t, res.Servers[0].Answers, res.Servers[1].Answers)
+
+	opts.Servers = append(opts.Servers, answering(t, 300, a))
+
+	res = query.Compare(opts)
+	assert.Assert(t, !res.Same)
+	assert.Equal(t, res.Type, "A")
+	assert.Equal(t, len(res.Servers[2].Answers), 1)
+
+	str := query.CompareString(res, opts)
+	assert.Assert(t, strings.HasPrefix(str, ";; example.com. A, different answers from 3 servers\n"))
+
+	for _, line := range strings.Split(str, "\n") {
+		switch {
+		case strings.Contains(line, "192.0.2.1"), strings.Contains(line, "rcode"):
+			assert.Assert(t, strings.HasPrefix(line, " "), line)
+		case strings.Contains(line, "192.0.2.2"):
+			assert.Assert(t, strings.HasPrefix(line, "!"), line)
+			assert.DeepEqual(t, strings.Fields(line)[5:], []string{"yes", "yes", "no"})
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package query
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/miekg/dns"
+)
+
+// Decode reads a DNS message given as hex, base64 or base64url, a DoH GET URL
+// with a dns= parameter, or the name of a file holding any of those or the
+// message in binary. - reads from stdin.
+func Decode(input string) (*dns.Msg, error) {
+	if input == "-" {
+		return ReadMessage(os.Stdin)
+	}
+
+	if file, err := os.Open(input); err == nil { //nolint:gosec // Reading files is the point.
+		defer file.Close()
+
+		return ReadMessage(file)
+	}
+
+	// Only as a parameter, base64 can end with dns= too
+	if strings.HasPrefix(input, "dns=") || strings.Contains(input, "?dns=") || strings.Contains(input, "&dns=") {
+		return decodeURL(input)
+	}
+
+	return DecodeString(input)
+}
+
+// ReadMessage reads a DNS message in binary, or encoded as [DecodeString] accepts.
+func ReadMessage(r io.Reader) (*dns.Msg, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+
+	msg := new(dns.Msg)
+	if err := msg.Unpack(data); err == nil {
+		return msg, nil
+	}
+
+	return DecodeString(string(data))
+}
+
+// DecodeString decodes a DNS message in hex, base64 or base64url, with or
+// without padding. Whitespace is ignored, so hex dumps split over lines work.
+func DecodeString(str string) (*dns.Msg, error) {
+	str = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+
+		return r
+	}, str)
+	str = strings.TrimPrefix(strings.TrimPrefix(str, "0x"), "0X")
+
+	if str == "" {
+		return nil, fmt.Errorf("decode: %w", errNoInput)
+	}
+
+	// Hex is tried first, as it is also valid base64
+	decoders := []func(string) ([]byte, error){
+		hex.DecodeString,
+		base64.StdEncoding.DecodeString,
+		base64.RawStdEncoding.DecodeString,
+		base64.URLEncoding.DecodeString,
+		base64.RawURLEncoding.DecodeString,
+	}
+
+	var firstErr error
+
+	for _, decode := range decoders {
+		data, err := decode(str)
+		if err != nil {
+			continue
+		}
+
+		msg := new(dns.Msg)
+		if err := msg.Unpack(data); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+
+			continue
+		}
+
+		return msg, nil
+	}
+
+	if firstErr != nil {
+		return nil, fmt.Errorf("decode: %w", firstErr)
+	}
+
+	return nil, fmt.Errorf("decode: %w", errNotEncoded)
+}
+
+// decodeURL decodes the dns parameter of a DoH GET URL, as in RFC 8484.
+func decodeURL(input string) (*dns.Msg, error) {
+	query := input
+	if _, after, ok := strings.Cut(input, "?"); ok {
+		query = after
+	}
+
+	// Not url.ParseQuery, which would turn the + of base64 into spaces
+	for _, param := range strings.Split(query, "&") {
+		if val, ok := strings.CutPrefix(param, "dns="); ok {
+			val, err := url.PathUnescape(val)
+			if err != nil {
+				return nil, fmt.Errorf("decode: %w", err)
+			}
+
+			return DecodeString(val)
+		}
+	}
+
+	return nil, fmt.Errorf("decode: %w", errNoInput)
+}
+
+var (
+	errNoInput    = errors.New("nothing to decode")
+	errNotEncoded = errors.New("not hex or base64")
+)
@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package query_test
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dns.froth.zone/awl/pkg/query"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+func TestDecode(t *testing.T) {
+	t.Parallel()
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.com.", dns.TypeAAAA)
+	msg.Id = 0
+
+	wire, err := msg.Pack()
+	assert.NilError(t, err)
+
+	dir := t.TempDir()
+
+	binFile := filepath.Join(dir, "query.bin")
+	assert.NilError(t, os.WriteFile(binFile, wire, 0o600))
+
+	hexFile := filepath.Join(dir, "query.hex")
+	assert.NilError(t, os.WriteFile(hexFile, []byte(hex.EncodeToString(wire)[:20]+"\n"+hex.EncodeToString(wire)[20:]+"\n"), 0o600))
+
+	tests := map[string]string{
+		"hex":       hex.EncodeToString(wire),
+		"upper hex": "0x" + strings.ToUpper(hex.EncodeToString(wire)),
+		"base64":    base64.StdEncoding.EncodeToString(wire),
+		"base64url": base64.RawURLEncoding.EncodeToString(wire),
+		"doh":       "https://dns.example/dns-query?ct&dns=" + base64.RawURLEncoding.EncodeToString(wire),
+		"binary":    binFile,
+		"hex file":  hexFile,
+	}
+
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := query.Decode(input)
+			assert.NilError(t, err)
+			assert.Equal(t, got.String(), msg.String())
+		})
+	}
+}
+
+func TestDecodePadding(t *testing.T) {
+	t.Parallel()
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.com.", dns.TypeTXT)
+
+	// A TXT record ending in "v{", with a length making the base64 end with "dns="
+	for i := range 3 {
+		msg.Answer = []dns.RR{&dns.TXT{
+			Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeTXT, Class: dns.ClassINET},
+			Txt: []string{strings.Repeat("a", i) + "v{"},
+		}}
+
+		wire, err := msg.Pack()
+		assert.NilError(t, err)
+
+		input := base64.StdEncoding.EncodeToString(wire)
+		if !strings.HasSuffix(input, "dns=") {
+			continue
+		}
+
+		got, err := query.Decode(input)
+		assert.NilError(t, err)
+		assert.Equal(t, got.String(), msg.String())
+
+		return
+	}
+
+	t.Fatal("no padding ending in dns=")
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, input := range []string{"", "not a message", "abcd", "https://dns.example/dns-query?name=example.com"} {
+		_, err := query.Decode(input)
+		assert.ErrorContains(t, err, "decode", input)
+	}
+}
@@ -0,0 +1,264 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package query
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"dns.froth.zone/awl/pkg/util"
+	"gopkg.in/yaml.v3"
+)
+
+// Formatter prints results in a format other than the default dig-like text,
+// selected with --format.
+type Formatter interface {
+	// FormatResponse prints a single DNS response
+	FormatResponse(res util.Response, opts *util.Options) (string, error)
+	// FormatValue prints anything else awl prints, eg. a trace or a zone walk
+	FormatValue(v any, opts *util.Options) (string, error)
+}
+
+var formatters = map[string]Formatter{
+	"json":     &marshalFormatter{"JSON", func(v any) ([]byte, error) { return json.MarshalIndent(v, " ", "  ") }},
+	"xml":      &marshalFormatter{"XML", func(v any) ([]byte, error) { return xml.MarshalIndent(v, " ", "  ") }},
+	"yaml":     &marshalFormatter{"YAML", yaml.Marshal},
+	"rfc8427":  &rfc8427Formatter{},
+	"table":    &tableFormatter{},
+	"csv":      &csvFormatter{','},
+	"tsv":      &csvFormatter{'\t'},
+	"ndjson":   &ndjsonFormatter{},
+	"template": &templateFormatter{},
+	"zone":     &zoneFormatter{},
+}
+
+// responseFormatter is implemented by formatters that can only print DNS responses.
+type responseFormatter interface {
+	onlyResponses()
+}
+
+// OnlyResponses reports whether a formatter can only print DNS responses,
+// and not eg. a trace or a zone walk.
+func OnlyResponses(f Formatter) bool {
+	_, ok := f.(responseFormatter)
+
+	return ok
+}
+
+// RegisterFormatter adds a formatter that can be selected with --format,
+// replacing any formatter with the same name.
+func RegisterFormatter(name string, f Formatter) {
+	formatters[strings.ToLower(name)] = f
+}
+
+// Formats returns the names of every formatter, sorted.
+func Formats() []string {
+	names := make([]string, 0, len(formatters))
+	for name := range formatters {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// LoadFormatter loads the formatter selected by the options.
+func LoadFormatter(opts *util.Options) (Formatter, error) {
+	f, ok := formatters[strings.ToLower(opts.Format)]
+	if !ok {
+		return nil, fmt.Errorf("%w %q, use one of %s", errInvalidFormat, opts.Format, strings.Join(Formats(), ", "))
+	}
+
+	return f, nil
+}
+
+// marshalFormatter prints everything with a marshaler, eg. JSON.
+type marshalFormatter struct {
+	name    string
+	marshal func(any) ([]byte, error)
+}
+
+func (f *marshalFormatter) FormatResponse(res util.Response, opts *util.Options) (string, error) {
+	formatted, err := MakePrintable(res, opts)
+	if err != nil {
+		return "", err
+	}
+
+	return f.FormatValue(formatted, opts)
+}
+
+func (f *marshalFormatter) FormatValue(v any, opts *util.Options) (string, error) {
+	opts.Logger.Info("Printing as", f.name)
+
+	ret, err := f.marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", f.name, err)
+	}
+
+	return highlight(strings.ToLower(f.name), string(ret), opts), nil
+}
+
+// rfc8427Formatter prints responses as in RFC 8427, and anything else as JSON.
+type rfc8427Formatter struct{}
+
+func (f *rfc8427Formatter) FormatResponse(res util.Response, opts *util.Options) (string, error) {
+	ret, err := PrintRFC8427(res, opts)
+	if err != nil {
+		return "", err
+	}
+
+	return highlight("rfc8427", ret, opts), nil
+}
+
+func (f *rfc8427Formatter) FormatValue(v any, opts *util.Options) (string, error) {
+	return formatters["json"].FormatValue(v, opts)
+}
+
+// tableFormatter prints the records of responses as an aligned table.
+type tableFormatter struct{}
+
+func (f *tableFormatter) FormatResponse(res util.Response, opts *util.Options) (string, error) {
+	opts.Logger.Info("Printing as a table")
+
+	formatted, err := MakePrintable(res, opts)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+
+	for _, row := range append([][]string{header}, rows(formatted)...) {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+
+	if err := w.Flush(); err != nil {
+		return "", fmt.Errorf("table: %w", err)
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+func (f *tableFormatter) FormatValue(any, *util.Options) (string, error) {
+	return "", fmt.Errorf("table: %w", ErrOnlyResponses)
+}
+
+func (f *tableFormatter) onlyResponses() {}
+
+// csvFormatter prints one row per record, separated by commas or tabs.
+type csvFormatter struct {
+	comma rune
+}
+
+func (f *csvFormatter) FormatResponse(res util.Response, opts *util.Options) (string, error) {
+	opts.Logger.Info("Printing as CSV")
+
+	formatted, err := MakePrintable(res, opts)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+
+	w := csv.NewWriter(&buf)
+	w.Comma = f.comma
+
+	lower := make([]string, 0, len(header))
+	for _, col := range header {
+		lower = append(lower, strings.ToLower(col))
+	}
+
+	if err := w.WriteAll(append([][]string{lower}, rows(formatted)...)); err != nil {
+		return "", fmt.Errorf("csv: %w", err)
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+func (f *csvFormatter) FormatValue(any, *util.Options) (string, error) {
+	return "", fmt.Errorf("csv: %w", ErrOnlyResponses)
+}
+
+func (f *csvFormatter) onlyResponses() {}
+
+// ndjsonFormatter prints every result as JSON on its own line, to be streamed.
+type ndjsonFormatter struct{}
+
+func (f *ndjsonFormatter) FormatResponse(res util.Response, opts *util.Options) (string, error) {
+	formatted, err := MakePrintable(res, opts)
+	if err != nil {
+		return "", err
+	}
+
+	return f.FormatValue(formatted, opts)
+}
+
+// FormatValue prints every element on its own line when given a slice, eg. the steps of a trace.
+func (f *ndjsonFormatter) FormatValue(v any, opts *util.Options) (string, error) {
+	opts.Logger.Info("Printing as NDJSON")
+
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Slice {
+		ret, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("ndjson: %w", err)
+		}
+
+		return highlight("ndjson", string(ret), opts), nil
+	}
+
+	lines := make([]string, 0, val.Len())
+
+	for i := range val.Len() {
+		line, err := json.Marshal(val.Index(i).Interface())
+		if err != nil {
+			return "", fmt.Errorf("ndjson: %w", err)
+		}
+
+		lines = append(lines, string(line))
+	}
+
+	return highlight("ndjson", strings.Join(lines, "\n"), opts), nil
+}
+
+// header is the first row of tables and CSV.
+var header = []string{"SECTION", "NAME", "TTL", "CLASS", "TYPE", "RDATA"}
+
+// rows makes a row for every record shown.
+func rows(msg *Message) [][]string {
+	var ret [][]string
+
+	for _, section := range []struct {
+		name string
+		rrs  []Answer
+	}{
+		{"answer", msg.AnswerRRs},
+		{"authority", msg.AuthoritativeRRs},
+		{"additional", msg.AdditionalRRs},
+	} {
+		for _, rr := range section.rrs {
+			ttl := ""
+			if rr.TTL != nil {
+				ttl = fmt.Sprint(rr.TTL)
+			}
+
+			ret = append(ret, []string{section.name, rr.Name, ttl, rr.ClassName, rr.TypeName, rr.Value})
+		}
+	}
+
+	return ret
+}
+
+// ErrOnlyResponses is returned when printing something other than a DNS response
+// in a format that can only print DNS responses.
+var ErrOnlyResponses = errors.New("only DNS responses can be printed in this format, use json, xml, yaml, rfc8427, ndjson or a template")
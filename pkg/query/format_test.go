@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package query_test
+
+import (
+	"strings"
+	"testing"
+
+	"dns.froth.zone/awl/pkg/query"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+func TestFormats(t *testing.T) {
+	t.Parallel()
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.com.", dns.TypeTXT)
+
+	for _, str := range []string{
+		`example.com. 300 IN TXT "v=spf1 -all"`,
+		"example.com. 300 IN NS ns.example.com.",
+	} {
+		rr, err := dns.NewRR(str)
+		assert.NilError(t, err)
+
+		msg.Answer = append(msg.Answer, rr)
+	}
+
+	rr, err := dns.NewRR("ns.example.com. 60 IN A 192.0.2.1")
+	assert.NilError(t, err)
+
+	msg.Extra = []dns.RR{rr}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			"table",
+			"SECTION     NAME             TTL  CLASS  TYPE  RDATA\n" +
+				"answer      example.com.     300  IN     TXT   \"v=spf1 -all\"\n" +
+				"answer      example.com.     300  IN     NS    ns.example.com.\n" +
+				"additional  ns.example.com.  60   IN     A     192.0.2.1",
+		},
+		{
+			"csv",
+			"section,name,ttl,class,type,rdata\n" +
+				"answer,example.com.,300,IN,TXT,\"\"\"v=spf1 -all\"\"\"\n" +
+				"answer,example.com.,300,IN,NS,ns.example.com.\n" +
+				"additional,ns.example.com.,60,IN,A,192.0.2.1",
+		},
+		{
+			"tsv",
+			"section\tname\tttl\tclass\ttype\trdata\n" +
+				"answer\texample.com.\t300\tIN\tTXT\t\"\"\"v=spf1 -all\"\"\"\n" +
+				"answer\texample.com.\t300\tIN\tNS\tns.example.com.\n" +
+				"additional\tns.example.com.\t60\tIN\tA\t192.0.2.1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			t.Parallel()
+
+			opts := &util.Options{
+				Logger:  util.InitLogger(0),
+				Format:  test.format,
+				Display: util.Display{Answer: true, Additional: true, TTL: true, ShowClass: true},
+			}
+
+			str, err := query.PrintSpecial(util.Response{DNS: msg}, opts)
+			assert.NilError(t, err)
+			assert.Equal(t, str, test.want)
+
+			_, err = query.Marshal([]string{"a"}, opts)
+			assert.ErrorContains(t, err, "only DNS responses")
+		})
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	t.Parallel()
+
+	opts := &util.Options{Logger: util.InitLogger(0), Format: "ndjson"}
+
+	str, err := query.Marshal([]map[string]int{{"a": 1}, {"b": 2}}, opts)
+	assert.NilError(t, err)
+	assert.Equal(t, str, "{\"a\":1}\n{\"b\":2}")
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.com.", dns.TypeA)
+
+	str, err = query.PrintSpecial(util.Response{DNS: msg}, opts)
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(str, "\n"), str)
+	assert.Assert(t, strings.HasPrefix(str, "{"), str)
+}
+
+func TestLoadFormatter(t *testing.T) {
+	t.Parallel()
+
+	_, err := query.LoadFormatter(&util.Options{Format: "toml"})
+	assert.ErrorContains(t, err, "unknown format \"toml\", use one of csv, json, ndjson, rfc8427, table, template, tsv, xml, yaml, zone")
+
+	f, err := query.LoadFormatter(&util.Options{Format: "JSON"})
+	assert.NilError(t, err)
+	assert.Assert(t, f != nil)
+}
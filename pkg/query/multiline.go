@@ -0,0 +1,191 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+)
+
+const (
+	// Indentation of continuation lines, the same as dig
+	indent = "\t\t\t\t"
+	// Width to wrap base64 and hex at
+	wrap = 44
+)
+
+// multiline formats a record over multiple lines with comments, like dig's +multiline.
+// str is the record as already formatted, only the RDATA is changed.
+func multiline(r dns.RR, str string, opts *util.Options) string {
+	if !opts.Display.Multiline {
+		return str
+	}
+
+	// The RDATA is always after the last tab
+	idx := strings.LastIndex(str, "\t")
+	if idx == -1 {
+		return str
+	}
+
+	head := str[:idx+1]
+
+	switch r := r.(type) {
+	case *dns.SOA:
+		return head + fmt.Sprintf("%s %s (\n", r.Ns, r.Mbox) +
+			indent + fmt.Sprintf("%-10d ; serial\n", r.Serial) +
+			indent + fmt.Sprintf("%-10d ; refresh (%s)\n", r.Refresh, humanize(r.Refresh)) +
+			indent + fmt.Sprintf("%-10d ; retry (%s)\n", r.Retry, humanize(r.Retry)) +
+			indent + fmt.Sprintf("%-10d ; expire (%s)\n", r.Expire, humanize(r.Expire)) +
+			indent + fmt.Sprintf("%-10d ; minimum (%s)\n", r.Minttl, humanize(r.Minttl)) +
+			indent + ")"
+	case *dns.DNSKEY:
+		return head + key(r)
+	case *dns.CDNSKEY:
+		return head + key(&r.DNSKEY)
+	case *dns.DS:
+		return head + digest(r)
+	case *dns.CDS:
+		return head + digest(&r.DS)
+	case *dns.RRSIG:
+		return head + signature(r)
+	case *dns.TXT:
+		return head + strs(r.Txt)
+	case *dns.SPF:
+		return head + strs(r.Txt)
+	case *dns.SVCB:
+		return head + svcb(r)
+	case *dns.HTTPS:
+		return head + svcb(&r.SVCB)
+	default:
+		return str
+	}
+}
+
+func key(k *dns.DNSKEY) string {
+	return fmt.Sprintf("%d %d %d (\n", k.Flags, k.Protocol, k.Algorithm) +
+		lines(k.PublicKey) + "\n" +
+		indent + ")"
+}
+
+func digest(ds *dns.DS) string {
+	return fmt.Sprintf("%d %d %d (\n", ds.KeyTag, ds.Algorithm, ds.DigestType) +
+		lines(strings.ToUpper(ds.Digest)) + " )"
+}
+
+func signature(sig *dns.RRSIG) string {
+	now := time.Now()
+
+	return fmt.Sprintf("%s %d %d %d (\n", dns.TypeToString[sig.TypeCovered], sig.Algorithm, sig.Labels, sig.OrigTtl) +
+		indent + dns.TimeToString(sig.Expiration) + " ; expiration (" + when(sig.Expiration, now) + ")\n" +
+		indent + dns.TimeToString(sig.Inception) + " ; inception (" + when(sig.Inception, now) + ")\n" +
+		indent + strconv.Itoa(int(sig.KeyTag)) + " " + sig.SignerName + "\n" +
+		lines(sig.Signature) + " )"
+}
+
+func strs(txt []string) string {
+	parts := make([]string, 0, len(txt))
+
+	// Let miekg/dns handle the escaping
+	for _, t := range txt {
+		str := (&dns.TXT{Txt: []string{t}}).String()
+		parts = append(parts, str[strings.LastIndex(str, "\t")+1:])
+	}
+
+	if len(parts) <= 1 {
+		return strings.Join(parts, "")
+	}
+
+	return "( " + strings.Join(parts, "\n"+indent) + " )"
+}
+
+func svcb(s *dns.SVCB) string {
+	if len(s.Value) == 0 {
+		return fmt.Sprintf("%d %s", s.Priority, s.Target)
+	}
+
+	ret := fmt.Sprintf("%d %s (\n", s.Priority, s.Target)
+	for _, kv := range s.Value {
+		ret += indent + kv.Key().String() + "=\"" + kv.String() + "\"\n"
+	}
+
+	return ret + indent + ")"
+}
+
+// lines wraps a long string of base64 or hex.
+func lines(str string) string {
+	var parts []string
+
+	for len(str) > wrap {
+		parts = append(parts, indent+str[:wrap])
+		str = str[wrap:]
+	}
+
+	parts = append(parts, indent+str)
+
+	return strings.Join(parts, "\n")
+}
+
+// when prints an RRSIG time with roughly how long ago or until it is.
+func when(t uint32, now time.Time) string {
+	at := time.Unix(int64(t), 0).UTC()
+	diff := at.Sub(now).Round(time.Second)
+
+	if diff < 0 {
+		return at.Format(time.DateTime) + " UTC, " + approx(uint32(-diff/time.Second)) + " ago"
+	}
+
+	return at.Format(time.DateTime) + " UTC, in " + approx(uint32(diff/time.Second))
+}
+
+// approx prints only the two largest units of a duration.
+func approx(secs uint32) string {
+	parts := strings.Split(humanize(secs), " ")
+	if len(parts) > 4 {
+		parts = parts[:4]
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// humanize prints a number of seconds the way dig does, eg. "1 week 6 days".
+func humanize(secs uint32) string {
+	units := []struct {
+		name string
+		size uint32
+	}{
+		{"week", 604800},
+		{"day", 86400},
+		{"hour", 3600},
+		{"minute", 60},
+		{"second", 1},
+	}
+
+	var parts []string
+
+	for _, unit := range units {
+		n := secs / unit.size
+		if n == 0 {
+			continue
+		}
+
+		secs -= n * unit.size
+
+		part := strconv.Itoa(int(n)) + " " + unit.name
+		if n != 1 {
+			part += "s"
+		}
+
+		parts = append(parts, part)
+	}
+
+	if len(parts) == 0 {
+		return "0 seconds"
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -3,18 +3,19 @@
 package query
 
 import (
-	"encoding/json"
-	"encoding/xml"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 	"time"
 
+	"dns.froth.zone/awl/pkg/dnskey"
+	"dns.froth.zone/awl/pkg/dnssec"
 	"dns.froth.zone/awl/pkg/util"
 	"github.com/miekg/dns"
 	"golang.org/x/net/idna"
-	"gopkg.in/yaml.v3"
 )
 
 // ToString turns the response into something that looks a lot like dig
@@ -29,11 +30,21 @@ func ToString(res util.Response, opts *util.Options) (s string, err error) {
 
 	if !opts.Short {
 		if opts.Display.Comments {
-			s += res.DNS.MsgHdr.String() + " "
+			s += colorHeader(res.DNS.MsgHdr.String(), res.DNS.Rcode, opts) + " "
 			s += "QUERY: " + strconv.Itoa(len(res.DNS.Question)) + ", "
 			s += "ANSWER: " + strconv.Itoa(len(res.DNS.Answer)) + ", "
 			s += "AUTHORITY: " + strconv.Itoa(len(res.DNS.Ns)) + ", "
 			s += "ADDITIONAL: " + strconv.Itoa(len(res.DNS.Extra)) + "\n"
+
+			if res.DNSSEC != nil {
+				s += ";; DNSSEC: " + paint(opts, statusColor(res.DNSSEC.Status), res.DNSSEC.Status.String())
+				if res.DNSSEC.Reason != "" {
+					s += " (" + res.DNSSEC.Reason + ")"
+				}
+
+				s += "\n"
+			}
+
 			opt = res.DNS.IsEdns0()
 
 			if opt != nil && opts.Display.Opt {
@@ -45,7 +56,7 @@ func ToString(res util.Response, opts *util.Options) (s string, err error) {
 		if opts.Display.Question {
 			if len(res.DNS.Question) > 0 {
 				if opts.Display.Comments {
-					s += "\n;; QUESTION SECTION:\n"
+					s += "\n" + paint(opts, bold, ";; QUESTION SECTION:") + "\n"
 				}
 
 				for _, r := range res.DNS.Question {
@@ -54,7 +65,7 @@ func ToString(res util.Response, opts *util.Options) (s string, err error) {
 						return "", fmt.Errorf("%w", err)
 					}
 
-					s += str + "\n"
+					s += paint(opts, cyan, str) + "\n"
 				}
 			}
 		}
@@ -62,7 +73,7 @@ func ToString(res util.Response, opts *util.Options) (s string, err error) {
 		if opts.Display.Answer {
 			if len(res.DNS.Answer) > 0 {
 				if opts.Display.Comments {
-					s += "\n;; ANSWER SECTION:\n"
+					s += "\n" + paint(opts, bold, ";; ANSWER SECTION:") + "\n"
 				}
 
 				for _, r := range res.DNS.Answer {
@@ -72,7 +83,7 @@ func ToString(res util.Response, opts *util.Options) (s string, err error) {
 							return "", fmt.Errorf("%w", err)
 						}
 
-						s += str + "\n"
+						s += multiline(r, colorRR(str, opts), opts) + rrComment(r, opts) + "\n"
 					}
 				}
 			}
@@ -81,7 +92,7 @@ func ToString(res util.Response, opts *util.Options) (s string, err error) {
 		if opts.Display.Authority {
 			if len(res.DNS.Ns) > 0 {
 				if opts.Display.Comments {
-					s += "\n;; AUTHORITY SECTION:\n"
+					s += "\n" + paint(opts, bold, ";; AUTHORITY SECTION:") + "\n"
 				}
 
 				for _, r := range res.DNS.Ns {
@@ -91,7 +102,7 @@ func ToString(res util.Response, opts *util.Options) (s string, err error) {
 							return "", fmt.Errorf("%w", err)
 						}
 
-						s += str + "\n"
+						s += multiline(r, colorRR(str, opts), opts) + rrComment(r, opts) + "\n"
 					}
 				}
 			}
@@ -100,7 +111,7 @@ func ToString(res util.Response, opts *util.Options) (s string, err error) {
 		if opts.Display.Additional {
 			if len(res.DNS.Extra) > 0 && (opt == nil || len(res.DNS.Extra) > 1) {
 				if opts.Display.Comments {
-					s += "\n;; ADDITIONAL SECTION:\n"
+					s += "\n" + paint(opts, bold, ";; ADDITIONAL SECTION:") + "\n"
 				}
 
 				for _, r := range res.DNS.Extra {
@@ -110,15 +121,29 @@ func ToString(res util.Response, opts *util.Options) (s string, err error) {
 							return "", fmt.Errorf("%w", err)
 						}
 
-						s += str + "\n"
+						s += multiline(r, colorRR(str, opts), opts) + rrComment(r, opts) + "\n"
 					}
 				}
 			}
 		}
 
+		if res.DNSSEC != nil && len(res.DNSSEC.Chain) > 0 {
+			s += "\n" + paint(opts, bold, ";; CHAIN OF TRUST:") + "\n"
+			s += chainString(res.DNSSEC.Chain, 1)
+		}
+
+		if opts.Display.Wire || opts.Display.Annotate {
+			str, err := wireString(res, opts)
+			if err != nil {
+				return "", err
+			}
+
+			s += str
+		}
+
 		if opts.Display.Statistics {
 			s += "\n;; Query time: " + res.RTT.String()
-			s += "\n;; SERVER: " + opts.Request.Server + serverExtra(opts)
+			s += "\n;; SERVER: " + opts.Request.Server + serverExtra(res, opts)
 			s += "\n;; WHEN: " + time.Now().Format(time.RFC1123Z)
 			s += "\n;; MSG SIZE  rcvd: " + strconv.Itoa(res.DNS.Len()) + "\n"
 		}
@@ -142,21 +167,110 @@ func ToString(res util.Response, opts *util.Options) (s string, err error) {
 	return
 }
 
-func serverExtra(opts *util.Options) string {
-	switch {
-	case opts.TCP:
-		return " (TCP)"
-	case opts.TLS:
-		return " (TLS)"
-	case opts.HTTPS, opts.DNSCrypt:
+// chainString prints a chain of trust as an indented tree.
+func chainString(links []*dnssec.Link, depth int) (s string) {
+	pad := "; " + strings.Repeat("  ", depth-1)
+	indent := pad + "  "
+
+	for _, link := range links {
+		s += pad + link.Zone + " (" + link.Status.String()
+		if link.Reason != "" && link.Status != dnssec.Secure {
+			s += ": " + link.Reason
+		}
+
+		s += ")\n"
+
+		for _, ds := range link.DS {
+			s += fmt.Sprintf("%sDS %d %s %s", indent, ds.KeyTag, ds.Algorithm, ds.DigestType)
+			if ds.Anchor {
+				s += " (trust anchor)"
+			}
+
+			s += "\n"
+		}
+
+		for _, key := range link.Keys {
+			s += fmt.Sprintf("%sDNSKEY %d %s %s", indent, key.KeyTag, key.Algorithm, key.Role)
+			if key.Trusted {
+				s += " (matches DS)"
+			}
+
+			s += "\n"
+		}
+
+		for _, sig := range link.Signatures {
+			s += fmt.Sprintf("%sRRSIG %s %s by key %d, %s to %s",
+				indent, sig.Name, sig.Type, sig.KeyTag,
+				sig.Inception.Format(time.DateTime), sig.Expiry.Format(time.DateTime))
+
+			if sig.Remaining > 0 {
+				s += " (" + sig.Remaining.String() + " left)"
+			} else {
+				s += " (expired)"
+			}
+
+			if sig.Error != "" {
+				s += ": " + sig.Error
+			}
+
+			s += "\n"
+		}
+
+		s += chainString(link.Children, depth+1)
+	}
+
+	return s
+}
+
+// rrComment returns a comment to print after a record, like dig's +rrcomments.
+func rrComment(r dns.RR, opts *util.Options) string {
+	if !opts.Display.RRComments {
 		return ""
-	case opts.QUIC:
-		return " (QUIC)"
+	}
+
+	switch r := r.(type) {
+	case *dns.DNSKEY:
+		return " " + dnskey.Comment(r)
+	case *dns.CDNSKEY:
+		return " " + dnskey.Comment(&r.DNSKEY)
 	default:
-		return " (UDP)"
+		return ""
+	}
+}
+
+func serverExtra(res util.Response, opts *util.Options) string {
+	switch proto := protocol(res, opts); proto {
+	case "HTTPS", "DNSCrypt":
+		return ""
+	default:
+		return " (" + proto + ")"
 	}
 }
 
+// protocol is the transport the response was received over.
+func protocol(res util.Response, opts *util.Options) string {
+	if res.TCPFallback {
+		return "TCP"
+	}
+
+	return util.Protocol(opts)
+}
+
+// server splits the server queried from its port, when it has one.
+func server(opts *util.Options) (string, int) {
+	host, port, err := net.SplitHostPort(opts.Request.Server)
+	if err != nil {
+		return opts.Request.Server, opts.Request.Port
+	}
+
+	num, err := strconv.Atoi(port)
+	if err != nil {
+		return opts.Request.Server, opts.Request.Port
+	}
+
+	return host, num
+}
+
 // stringParse edits the raw responses to user requests.
 func stringParse(str string, isAns bool, opts *util.Options) (string, error) {
 	split := strings.Split(str, "\t")
@@ -209,36 +323,24 @@ func stringParse(str string, isAns bool, opts *util.Options) (string, error) {
 	return strings.Join(split, "\t"), nil
 }
 
-// PrintSpecial is for printing as JSON, XML or YAML.
-// As of now JSON and XML use the stdlib version.
+// PrintSpecial prints a response in the format given by --format, eg. JSON.
 func PrintSpecial(res util.Response, opts *util.Options) (string, error) {
-	formatted, err := MakePrintable(res, opts)
+	f, err := LoadFormatter(opts)
 	if err != nil {
 		return "", err
 	}
 
-	switch {
-	case opts.JSON:
-		opts.Logger.Info("Printing as JSON")
-
-		json, err := json.MarshalIndent(formatted, " ", "  ")
-
-		return string(json), err
-	case opts.XML:
-		opts.Logger.Info("Printing as XML")
-
-		xml, err := xml.MarshalIndent(formatted, " ", "  ")
-
-		return string(xml), err
-	case opts.YAML:
-		opts.Logger.Info("Printing as YAML")
-
-		yaml, err := yaml.Marshal(formatted)
+	return f.FormatResponse(res, opts)
+}
 
-		return string(yaml), err
-	default:
-		return "", errInvalidFormat
+// Marshal prints anything printable, such as a trace, in the format given by --format.
+func Marshal(formatted any, opts *util.Options) (string, error) {
+	f, err := LoadFormatter(opts)
+	if err != nil {
+		return "", err
 	}
+
+	return f.FormatValue(formatted, opts)
 }
 
 // MakePrintable takes a DNS message and makes it nicer to be printed as JSON,YAML,
@@ -269,6 +371,31 @@ func MakePrintable(res util.Response, opts *util.Options) (*Message, error) {
 		AnCount: len(msg.Answer),
 		NsCount: len(msg.Ns),
 		ArCount: len(msg.Extra),
+
+		DNSSEC: res.DNSSEC,
+	}
+
+	// Messages that can't be packed again are still printed, without their octets
+	raw := rawWire(res)
+
+	if buf, err := wire(msg, raw.Response); err == nil {
+		ret.MessageOctetsHEX = strings.ToUpper(hex.EncodeToString(buf))
+	}
+
+	if res.Query != nil && (opts.Display.Wire || opts.Display.Annotate) {
+		if buf, err := wire(res.Query, raw.Query); err == nil {
+			ret.QueryOctetsHEX = strings.ToUpper(hex.EncodeToString(buf))
+		}
+	}
+
+	// Only queries made have somewhere they were sent
+	if res.Query != nil {
+		ret.Server, ret.Port = server(opts)
+		ret.Protocol = protocol(res, opts)
+		ret.RTT = res.RTT
+		ret.Retries = res.Retries
+		ret.TCPFallback = res.TCPFallback
+		ret.BadCookie = res.BadCookie
 	}
 
 	opt := msg.IsEdns0()
@@ -310,4 +437,4 @@ func MakePrintable(res util.Response, opts *util.Options) (*Message, error) {
 	return ret, nil
 }
 
-var errInvalidFormat = errors.New("this should never happen")
+var errInvalidFormat = errors.New("unknown format")
@@ -3,8 +3,11 @@
 package query_test
 
 import (
+	"strings"
 	"testing"
+	"time"
 
+	"dns.froth.zone/awl/pkg/dnssec"
 	"dns.froth.zone/awl/pkg/query"
 	"dns.froth.zone/awl/pkg/util"
 	"github.com/miekg/dns"
@@ -24,7 +27,7 @@ func TestRealPrint(t *testing.T) {
 				RD: true,
 			},
 
-			JSON: true,
+			Format: "json",
 			Display: util.Display{
 				Comments:       true,
 				Question:       true,
@@ -60,7 +63,6 @@ func TestRealPrint(t *testing.T) {
 
 			Short:    true,
 			Identify: true,
-			YAML:     false,
 			Display: util.Display{
 				Comments:       true,
 				Question:       true,
@@ -91,7 +93,7 @@ func TestRealPrint(t *testing.T) {
 				RD: true,
 			},
 			Identify: true,
-			XML:      true,
+			Format:   "xml",
 			Display: util.Display{
 				Comments:       true,
 				Question:       true,
@@ -154,7 +156,7 @@ func TestRealPrint(t *testing.T) {
 			},
 			Verbosity: 0,
 
-			YAML: true,
+			Format: "yaml",
 			Display: util.Display{
 				Comments:       true,
 				Question:       true,
@@ -200,7 +202,7 @@ func TestRealPrint(t *testing.T) {
 			}
 			assert.NilError(t, err)
 
-			if test.JSON || test.XML || test.YAML {
+			if test.Format != "" {
 				str := ""
 				str, err = query.PrintSpecial(res, test)
 				assert.NilError(t, err)
@@ -217,7 +219,7 @@ func TestBadFormat(t *testing.T) {
 	t.Parallel()
 
 	_, err := query.PrintSpecial(util.Response{DNS: new(dns.Msg)}, new(util.Options))
-	assert.ErrorContains(t, err, "never happen")
+	assert.ErrorContains(t, err, "unknown format")
 }
 
 func TestEmpty(t *testing.T) {
@@ -228,3 +230,132 @@ func TestEmpty(t *testing.T) {
 	assert.Error(t, err, "no message")
 	assert.Assert(t, str == "<nil> MsgHdr")
 }
+
+func TestPrintChain(t *testing.T) {
+	t.Parallel()
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.", dns.TypeA)
+
+	res := util.Response{
+		DNS: msg,
+		DNSSEC: &dnssec.Result{
+			Status: dnssec.Secure,
+			Chain: []*dnssec.Link{{
+				Zone:   ".",
+				Status: dnssec.Secure,
+				DS:     []dnssec.DS{{KeyTag: 20326, Algorithm: "RSASHA256", DigestType: "SHA256", Anchor: true}},
+				Keys:   []dnssec.Key{{KeyTag: 20326, Algorithm: "RSASHA256", Role: "KSK", Trusted: true}},
+				Children: []*dnssec.Link{{
+					Zone:   "example.",
+					Status: dnssec.Insecure,
+					Reason: "insecure delegation to example.",
+				}},
+			}},
+		},
+	}
+
+	str, err := query.ToString(res, &util.Options{Logger: util.InitLogger(0), Display: util.Display{Comments: true}})
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(str, ";; DNSSEC: Secure\n"))
+	assert.Assert(t, strings.Contains(str, "; . (Secure)\n"))
+	assert.Assert(t, strings.Contains(str, ";   DS 20326 RSASHA256 SHA256 (trust anchor)\n"))
+	assert.Assert(t, strings.Contains(str, ";   DNSKEY 20326 RSASHA256 KSK (matches DS)\n"))
+	assert.Assert(t, strings.Contains(str, ";   example. (Insecure: insecure delegation to example.)\n"))
+}
+
+func TestRRComments(t *testing.T) {
+	t.Parallel()
+
+	rr, err := dns.NewRR("example. 300 IN DNSKEY 256 3 13 aRS/DcPWGQj2wVJydT8EcAVoC0kXn5pDVm2IMvDDPXeD32XIabDusgfasehKnC29BUf0JOYe4I9qZIDh38vtoQ==")
+	assert.NilError(t, err)
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.", dns.TypeDNSKEY)
+	msg.Answer = []dns.RR{rr}
+
+	opts := &util.Options{Logger: util.InitLogger(0), Display: util.Display{Answer: true, TTL: true, ShowClass: true, RRComments: true}}
+
+	str, err := query.ToString(util.Response{DNS: msg}, opts)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(str, "== ; ZSK; alg = ECDSAP256SHA256 ; key id = "), str)
+
+	opts.Display.RRComments = false
+
+	str, err = query.ToString(util.Response{DNS: msg}, opts)
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(str, "key id"), str)
+}
+
+func TestMultiline(t *testing.T) {
+	t.Parallel()
+
+	soa, err := dns.NewRR("example. 300 IN SOA ns.example. hostmaster.example. 2024010101 7200 3600 1209600 300")
+	assert.NilError(t, err)
+
+	key, err := dns.NewRR("example. 300 IN DNSKEY 256 3 13 aRS/DcPWGQj2wVJydT8EcAVoC0kXn5pDVm2IMvDDPXeD32XIabDusgfasehKnC29BUf0JOYe4I9qZIDh38vtoQ==")
+	assert.NilError(t, err)
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.", dns.TypeSOA)
+	msg.Answer = []dns.RR{soa, key}
+
+	opts := &util.Options{Logger: util.InitLogger(0), Display: util.Display{Answer: true, TTL: true, ShowClass: true, RRComments: true, Multiline: true}}
+
+	str, err := query.ToString(util.Response{DNS: msg}, opts)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(str, "ns.example. hostmaster.example. (\n"), str)
+	assert.Assert(t, strings.Contains(str, "7200       ; refresh (2 hours)\n"), str)
+	assert.Assert(t, strings.Contains(str, "1209600    ; expire (2 weeks)\n"), str)
+	assert.Assert(t, strings.Contains(str, "256 3 13 (\n\t\t\t\taRS/DcPWGQj2wVJydT8EcAVoC0kXn5pDVm2IMvDDPXeD\n"), str)
+	assert.Assert(t, strings.Contains(str, "\t\t\t\t) ; ZSK; alg = ECDSAP256SHA256 ; key id = "), str)
+
+	opts.Display.Multiline = false
+
+	str, err = query.ToString(util.Response{DNS: msg}, opts)
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(str, "refresh"), str)
+}
+
+func TestMetadata(t *testing.T) {
+	t.Parallel()
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.", dns.TypeA)
+	msg.Response = true
+
+	res := util.Response{DNS: msg, Query: msg.Copy(), RTT: 12 * time.Millisecond, Retries: 1, TCPFallback: true}
+	opts := &util.Options{Logger: util.InitLogger(0), Request: util.Request{Server: "192.0.2.1:53", Port: 53}}
+
+	ret, err := query.MakePrintable(res, opts)
+	assert.NilError(t, err)
+	assert.Equal(t, ret.Server, "192.0.2.1")
+	assert.Equal(t, ret.Port, 53)
+	assert.Equal(t, ret.Protocol, "TCP")
+	assert.Equal(t, ret.RTT, 12*time.Millisecond)
+	assert.Equal(t, ret.Retries, 1)
+	assert.Equal(t, ret.TCPFallback, true)
+	assert.Equal(t, ret.BadCookie, false)
+
+	opts.Display.Statistics = true
+
+	str, err := query.ToString(res, opts)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(str, ";; SERVER: 192.0.2.1:53 (TCP)"), str)
+
+	opts.HTTPS = true
+	opts.Request.Server = "https://dns.example/dns-query"
+	opts.Request.Port = 443
+
+	ret, err = query.MakePrintable(util.Response{DNS: msg, Query: msg.Copy()}, opts)
+	assert.NilError(t, err)
+	assert.Equal(t, ret.Server, "https://dns.example/dns-query")
+	assert.Equal(t, ret.Port, 443)
+	assert.Equal(t, ret.Protocol, "HTTPS")
+
+	// Decoded messages were not sent anywhere by us
+	ret, err = query.MakePrintable(util.Response{DNS: msg}, opts)
+	assert.NilError(t, err)
+	assert.Equal(t, ret.Server, "")
+	assert.Equal(t, ret.Protocol, "")
+}
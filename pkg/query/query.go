@@ -19,6 +19,16 @@ func CreateQuery(opts *util.Options) (util.Response, error) {
 	req.SetQuestion(opts.Request.Name, opts.Request.Type)
 	req.Question[0].Qclass = opts.Request.Class
 
+	switch {
+	case opts.Request.FixedID:
+		req.Id = opts.Request.ID
+
+		opts.Logger.Info("Setting message ID to", req.Id)
+	case opts.QUIC:
+		// RFC 9250 requires the ID to be 0
+		req.Id = 0
+	}
+
 	// Set standard flags
 	req.MsgHdr.Response = opts.QR
 	req.MsgHdr.Authoritative = opts.AA
@@ -40,7 +50,11 @@ func CreateQuery(opts *util.Options) (util.Response, error) {
 		if opts.EDNS.Cookie {
 			cookie := new(dns.EDNS0_COOKIE)
 			cookie.Code = dns.EDNS0COOKIE
-			cookie.Cookie = uniuri.NewLenChars(16, []byte("1234567890abcdef"))
+			if opts.EDNS.CookieValue != "" {
+				cookie.Cookie = opts.EDNS.CookieValue
+			} else {
+				cookie.Cookie = uniuri.NewLenChars(16, []byte("1234567890abcdef"))
+			}
 			edns.Option = append(edns.Option, cookie)
 
 			opts.Logger.Info("Setting EDNS cookie to", cookie.Cookie)
@@ -106,7 +120,7 @@ func CreateQuery(opts *util.Options) (util.Response, error) {
 				err error
 			)
 
-			if opts.JSON || opts.XML || opts.YAML {
+			if opts.Format != "" {
 				str, err = PrintSpecial(util.Response{DNS: req}, opts)
 				if err != nil {
 					return util.Response{}, err
@@ -140,6 +154,13 @@ func CreateQuery(opts *util.Options) (util.Response, error) {
 
 	opts.Logger.Info("Query successfully loaded")
 
-	//nolint:wrapcheck // Error wrapping not needed here
-	return resolver.LookUp(req)
+	resp, err := resolver.LookUp(req)
+	if err != nil {
+		//nolint:wrapcheck // Error wrapping not needed here
+		return util.Response{}, err
+	}
+
+	resp.Query = req
+
+	return resp, nil
 }
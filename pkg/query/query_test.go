@@ -27,7 +27,7 @@ func TestCreateQ(t *testing.T) {
 				HeaderFlags: util.HeaderFlags{
 					Z: true,
 				},
-				YAML: true,
+				Format: "yaml",
 				Request: util.Request{
 					Server:  "8.8.4.4",
 					Port:    53,
@@ -66,7 +66,7 @@ func TestCreateQ(t *testing.T) {
 				HeaderFlags: util.HeaderFlags{
 					Z: true,
 				},
-				XML: true,
+				Format: "xml",
 
 				Request: util.Request{
 					Server:  "8.8.4.4",
@@ -92,7 +92,7 @@ func TestCreateQ(t *testing.T) {
 			"3",
 			&util.Options{
 				Logger: util.InitLogger(0),
-				JSON:   true,
+				Format: "json",
 				QUIC:   true,
 
 				Request: util.Request{
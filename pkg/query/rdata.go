@@ -0,0 +1,361 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package query
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/miekg/dns"
+	"gopkg.in/yaml.v3"
+)
+
+// Rdata is the RDATA of a record split into its fields, in wire order.
+//
+// It is a slice instead of a map so the fields keep their order in every format.
+type Rdata []Field
+
+// Field is a single field of the RDATA of a record.
+type Field struct {
+	Name  string
+	Value any
+}
+
+// ParseRdata splits the RDATA of a record into its fields.
+//
+// The fields are found from the structs of miekg/dns, so every type it supports
+// is handled. Unknown types (RFC 3597) return nil, use [Rdhex] for those instead.
+func ParseRdata(rr dns.RR) Rdata {
+	if rr == nil {
+		return nil
+	}
+
+	if _, ok := rr.(*dns.RFC3597); ok {
+		return nil
+	}
+
+	val := reflect.ValueOf(rr)
+	if val.Kind() != reflect.Pointer || val.IsNil() || val.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	return fields(val.Elem())
+}
+
+// Rdhex gives the RDATA of a record in hex, as in RFC 3597.
+func Rdhex(rr dns.RR) string {
+	if r, ok := rr.(*dns.RFC3597); ok {
+		return strings.ToLower(r.Rdata)
+	}
+
+	buf := make([]byte, dns.Len(rr))
+
+	off, err := dns.PackRR(rr, buf, 0, nil, false)
+	if err != nil {
+		return ""
+	}
+
+	// The header is before the RDATA
+	hdr := dns.Len(&dns.RR_Header{Name: rr.Header().Name})
+
+	return hex.EncodeToString(buf[hdr:off])
+}
+
+// fields goes through a struct, flattening embedded ones.
+func fields(val reflect.Value) Rdata {
+	var ret Rdata
+
+	for i := range val.NumField() {
+		field := val.Type().Field(i)
+
+		if !field.IsExported() || field.Type == reflect.TypeOf(dns.RR_Header{}) {
+			continue
+		}
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			ret = append(ret, fields(val.Field(i))...)
+
+			continue
+		}
+
+		ret = append(ret, Field{camel(field.Name), value(field.Name, val.Field(i))})
+	}
+
+	return ret
+}
+
+// value turns a field into something that can be printed in every format.
+func value(name string, val reflect.Value) any {
+	switch v := val.Interface().(type) {
+	case net.IP:
+		if v == nil {
+			return ""
+		}
+
+		return v.String()
+	case []net.IP:
+		ret := make([]string, 0, len(v))
+		for _, ip := range v {
+			ret = append(ret, ip.String())
+		}
+
+		return ret
+	case []byte:
+		return hex.EncodeToString(v)
+	case []dns.SVCBKeyValue:
+		ret := make(Rdata, 0, len(v))
+		for _, kv := range v {
+			ret = append(ret, Field{kv.Key().String(), kv.String()})
+		}
+
+		return ret
+	case []uint16:
+		// Type bitmaps
+		ret := make([]string, 0, len(v))
+		for _, t := range v {
+			ret = append(ret, dns.Type(t).String())
+		}
+
+		return ret
+	case uint16:
+		if name == "TypeCovered" {
+			return dns.Type(v).String()
+		}
+
+		return v
+	case string, []string, bool, uint8, uint32, uint64, int:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	default:
+		if val.Kind() == reflect.Slice {
+			ret := make([]string, 0, val.Len())
+			for i := range val.Len() {
+				ret = append(ret, fmt.Sprint(val.Index(i).Interface()))
+			}
+
+			return ret
+		}
+
+		return fmt.Sprint(v)
+	}
+}
+
+// camel changes a Go field name to lower camel case, eg. "NextDomain" to "nextDomain"
+// and "AAAA" to "aaaa".
+func camel(name string) string {
+	runes := []rune(name)
+
+	for i := range runes {
+		if !unicode.IsUpper(runes[i]) {
+			break
+		}
+
+		// Keep the start of the next word
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+
+		runes[i] = unicode.ToLower(runes[i])
+	}
+
+	return string(runes)
+}
+
+// MarshalJSON keeps the fields in order.
+func (r Rdata) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+
+	for i, f := range r {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, err := json.Marshal(f.Name)
+		if err != nil {
+			return nil, fmt.Errorf("rdata: %w", err)
+		}
+
+		val, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, fmt.Errorf("rdata: %w", err)
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// MarshalXML writes every field as an element.
+func (r Rdata) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return fmt.Errorf("rdata: %w", err)
+	}
+
+	for _, f := range r {
+		if err := e.EncodeElement(f.Value, xml.StartElement{Name: xml.Name{Local: f.Name}}); err != nil {
+			return fmt.Errorf("rdata: %w", err)
+		}
+	}
+
+	if err := e.EncodeToken(start.End()); err != nil {
+		return fmt.Errorf("rdata: %w", err)
+	}
+
+	return nil
+}
+
+// MarshalYAML keeps the fields in order.
+func (r Rdata) MarshalYAML() (any, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+
+	for _, f := range r {
+		val := new(yaml.Node)
+		if err := val.Encode(f.Value); err != nil {
+			return nil, fmt.Errorf("rdata: %w", err)
+		}
+
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: f.Name}, val)
+	}
+
+	return node, nil
+}
+
+// rdhex only gives the hex RDATA of records that could not be split into fields.
+func rdhex(rr dns.RR) string {
+	if ParseRdata(rr) != nil {
+		return ""
+	}
+
+	return Rdhex(rr)
+}
+
+// UnmarshalJSON reads the fields back in order.
+func (r *Rdata) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return fmt.Errorf("rdata: %w", errNotObject)
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("rdata: %w", err)
+		}
+
+		name, _ := tok.(string)
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return fmt.Errorf("rdata: %w", err)
+		}
+
+		var val any
+
+		if bytes.HasPrefix(raw, []byte("{")) {
+			var nested Rdata
+			if err := nested.UnmarshalJSON(raw); err != nil {
+				return err
+			}
+
+			val = nested
+		} else if err := json.Unmarshal(raw, &val); err != nil {
+			return fmt.Errorf("rdata: %w", err)
+		}
+
+		*r = append(*r, Field{name, val})
+	}
+
+	return nil
+}
+
+// UnmarshalXML reads the fields back in order, every value is read as a string.
+func (r *Rdata) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return fmt.Errorf("rdata: %w", err)
+		}
+
+		switch el := tok.(type) {
+		case xml.StartElement:
+			var inner struct {
+				XML string `xml:",innerxml"`
+			}
+
+			if err := d.DecodeElement(&inner, &el); err != nil {
+				return fmt.Errorf("rdata: %w", err)
+			}
+
+			var (
+				val  any
+				text string
+				// Wrap it again to decode it on its own
+				wrapped = []byte("<v>" + inner.XML + "</v>")
+			)
+
+			if strings.Contains(inner.XML, "<") {
+				var nested Rdata
+				if err := xml.Unmarshal(wrapped, &nested); err != nil {
+					return fmt.Errorf("rdata: %w", err)
+				}
+
+				val = nested
+			} else {
+				if err := xml.Unmarshal(wrapped, &text); err != nil {
+					return fmt.Errorf("rdata: %w", err)
+				}
+
+				val = text
+			}
+
+			*r = append(*r, Field{el.Name.Local, val})
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// UnmarshalYAML reads the fields back in order.
+func (r *Rdata) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("rdata: %w", errNotObject)
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		var val any
+
+		if node.Content[i+1].Kind == yaml.MappingNode {
+			var nested Rdata
+			if err := nested.UnmarshalYAML(node.Content[i+1]); err != nil {
+				return err
+			}
+
+			val = nested
+		} else if err := node.Content[i+1].Decode(&val); err != nil {
+			return fmt.Errorf("rdata: %w", err)
+		}
+
+		*r = append(*r, Field{node.Content[i].Value, val})
+	}
+
+	return nil
+}
+
+var errNotObject = errors.New("not an object")
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package query_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"dns.froth.zone/awl/pkg/query"
+	"github.com/miekg/dns"
+	"gopkg.in/yaml.v3"
+	"gotest.tools/v3/assert"
+)
+
+func TestRdata(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		rr   string
+		json string
+	}{
+		{
+			"example. 300 IN MX 10 mail.example.",
+			`{"preference":10,"mx":"mail.example."}`,
+		},
+		{
+			"example. 300 IN SOA ns.example. hostmaster.example. 2024010101 7200 3600 1209600 300",
+			`{"ns":"ns.example.","mbox":"hostmaster.example.","serial":2024010101,"refresh":7200,"retry":3600,"expire":1209600,"minttl":300}`,
+		},
+		{
+			"_sip._tcp.example. 300 IN SRV 0 5 5060 sip.example.",
+			`{"priority":0,"weight":5,"port":5060,"target":"sip.example."}`,
+		},
+		{
+			`example. 300 IN CAA 0 issue "letsencrypt.org"`,
+			`{"flag":0,"tag":"issue","value":"letsencrypt.org"}`,
+		},
+		{
+			"example. 300 IN HTTPS 1 . alpn=h2,h3 port=443",
+			`{"priority":1,"target":".","value":{"alpn":"h2,h3","port":"443"}}`,
+		},
+		{
+			"example. 300 IN AAAA 2001:db8::1",
+			`{"aaaa":"2001:db8::1"}`,
+		},
+		{
+			"example. 300 IN NSEC a.example. A RRSIG NSEC",
+			`{"nextDomain":"a.example.","typeBitMap":["A","RRSIG","NSEC"]}`,
+		},
+		{
+			"example. 300 IN CDS 60485 5 1 2BB183AF5F22588179A53B0A98631FAD1A292118",
+			`{"keyTag":60485,"algorithm":5,"digestType":1,"digest":"2BB183AF5F22588179A53B0A98631FAD1A292118"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.rr, func(t *testing.T) {
+			t.Parallel()
+
+			rr, err := dns.NewRR(test.rr)
+			assert.NilError(t, err)
+
+			res, err := json.Marshal(query.ParseRdata(rr))
+			assert.NilError(t, err)
+			assert.Equal(t, string(res), test.json)
+
+			_, err = xml.Marshal(query.Answer{Rdata: query.ParseRdata(rr)})
+			assert.NilError(t, err)
+
+			_, err = yaml.Marshal(query.Answer{Rdata: query.ParseRdata(rr)})
+			assert.NilError(t, err)
+
+			assert.Equal(t, query.Rdhex(rr) != "", true)
+		})
+	}
+}
+
+func TestRdataUnknown(t *testing.T) {
+	t.Parallel()
+
+	rr, err := dns.NewRR(`example. 300 IN TYPE65280 \# 4 0A000001`)
+	assert.NilError(t, err)
+
+	assert.Assert(t, query.ParseRdata(rr) == nil)
+	assert.Equal(t, query.Rdhex(rr), "0a000001")
+
+	rr, err = dns.NewRR("example. 300 IN A 10.0.0.1")
+	assert.NilError(t, err)
+	assert.Equal(t, query.Rdhex(rr), "0a000001")
+}
+
+func TestRdataOrder(t *testing.T) {
+	t.Parallel()
+
+	rr, err := dns.NewRR("example. 300 IN MX 10 mail.example.")
+	assert.NilError(t, err)
+
+	res, err := xml.Marshal(query.Answer{Rdata: query.ParseRdata(rr)})
+	assert.NilError(t, err)
+	assert.Equal(t, string(res), "<Answer><RDATA><preference>10</preference><mx>mail.example.</mx></RDATA></Answer>")
+
+	out, err := yaml.Marshal(query.Answer{Rdata: query.ParseRdata(rr)})
+	assert.NilError(t, err)
+	assert.Equal(t, string(out), "RDATA:\n    preference: 10\n    mx: mail.example.\n")
+}
+
+func TestRdataRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	rr, err := dns.NewRR("example. 300 IN HTTPS 1 . alpn=h2,h3 port=443")
+	assert.NilError(t, err)
+
+	in := query.Answer{Rdata: query.ParseRdata(rr)}
+
+	var out query.Answer
+
+	res, err := json.Marshal(in)
+	assert.NilError(t, err)
+	assert.NilError(t, json.Unmarshal(res, &out))
+	assert.Equal(t, out.Rdata[2].Name, "value")
+	assert.Equal(t, out.Rdata[2].Value.(query.Rdata)[1].Value, "443")
+
+	out = query.Answer{}
+
+	res, err = xml.Marshal(in)
+	assert.NilError(t, err)
+	assert.NilError(t, xml.Unmarshal(res, &out))
+	assert.Equal(t, out.Rdata[1].Value, ".")
+	assert.Equal(t, out.Rdata[2].Value.(query.Rdata)[0].Value, "h2,h3")
+
+	out = query.Answer{}
+
+	res, err = yaml.Marshal(in)
+	assert.NilError(t, err)
+	assert.NilError(t, yaml.Unmarshal(res, &out))
+	assert.Equal(t, out.Rdata[0].Value, 1)
+	assert.Equal(t, out.Rdata[2].Value.(query.Rdata)[1].Value, "443")
+}
@@ -0,0 +1,418 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package query
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+)
+
+// RFC8427 is a DNS message in the format of RFC 8427, "Representing DNS Messages in JSON".
+//
+// Unlike [Message], the member names and types are the ones in the RFC, so it
+// can be read by other tools.
+//
+//nolint:govet,tagliatelle // Names are from the RFC.
+type RFC8427 struct {
+	ID     uint16 `json:"ID"`
+	QR     bool   `json:"QR"`
+	Opcode int    `json:"Opcode"`
+	AA     bool   `json:"AA"`
+	TC     bool   `json:"TC"`
+	RD     bool   `json:"RD"`
+	RA     bool   `json:"RA"`
+	AD     bool   `json:"AD"`
+	CD     bool   `json:"CD"`
+	RCODE  int    `json:"RCODE"`
+
+	QDCOUNT int `json:"QDCOUNT"`
+	ANCOUNT int `json:"ANCOUNT"`
+	NSCOUNT int `json:"NSCOUNT"`
+	ARCOUNT int `json:"ARCOUNT"`
+
+	QNAME      string `json:"QNAME,omitempty"`
+	QTYPE      uint16 `json:"QTYPE,omitempty"`
+	QTYPEname  string `json:"QTYPEname,omitempty"`
+	QCLASS     uint16 `json:"QCLASS,omitempty"`
+	QCLASSname string `json:"QCLASSname,omitempty"`
+
+	QuestionRRs   []RFC8427RR `json:"questionRRs,omitempty"`
+	AnswerRRs     []RFC8427RR `json:"answerRRs,omitempty"`
+	AuthorityRRs  []RFC8427RR `json:"authorityRRs,omitempty"`
+	AdditionalRRs []RFC8427RR `json:"additionalRRs,omitempty"`
+
+	MessageOctetsHEX string `json:"messageOctetsHEX,omitempty"`
+
+	DateString  string `json:"dateString,omitempty"`
+	DateSeconds int64  `json:"dateSeconds,omitempty"`
+	Comment     string `json:"comment,omitempty"`
+}
+
+// RFC8427RR is a resource record in the format of RFC 8427.
+//
+//nolint:govet,tagliatelle // Names are from the RFC.
+type RFC8427RR struct {
+	NAME      string  `json:"NAME"`
+	TYPE      uint16  `json:"TYPE"`
+	TYPEname  string  `json:"TYPEname,omitempty"`
+	CLASS     uint16  `json:"CLASS"`
+	CLASSname string  `json:"CLASSname,omitempty"`
+	TTL       *uint32 `json:"TTL,omitempty"`
+	RDLENGTH  *int    `json:"RDLENGTH,omitempty"`
+	RDATAHEX  string  `json:"RDATAHEX,omitempty"`
+	// The RDATA in presentation format, written as the "rdata" member followed
+	// by the type, eg. "rdataA"
+	Rdata string `json:"-"`
+}
+
+// rfc8427RR stops MarshalJSON and UnmarshalJSON from calling themselves.
+type rfc8427RR RFC8427RR
+
+// MarshalJSON adds the "rdata" member named after the type.
+func (rr RFC8427RR) MarshalJSON() ([]byte, error) {
+	ret, err := json.Marshal(rfc8427RR(rr))
+	if err != nil {
+		return nil, fmt.Errorf("rfc8427: %w", err)
+	}
+
+	if rr.Rdata == "" || rr.TYPEname == "" {
+		return ret, nil
+	}
+
+	rdata, err := json.Marshal(rr.Rdata)
+	if err != nil {
+		return nil, fmt.Errorf("rfc8427: %w", err)
+	}
+
+	ret = bytes.TrimSuffix(ret, []byte("}"))
+	ret = append(ret, `,"rdata`+rr.TYPEname+`":`...)
+	ret = append(ret, rdata...)
+
+	return append(ret, '}'), nil
+}
+
+// UnmarshalJSON reads the "rdata" member named after the type.
+func (rr *RFC8427RR) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*rfc8427RR)(rr)); err != nil {
+		return fmt.Errorf("rfc8427: %w", err)
+	}
+
+	members := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &members); err != nil {
+		return fmt.Errorf("rfc8427: %w", err)
+	}
+
+	for key, val := range members {
+		if !strings.HasPrefix(key, "rdata") {
+			continue
+		}
+
+		if err := json.Unmarshal(val, &rr.Rdata); err != nil {
+			return fmt.Errorf("rfc8427: %s: %w", key, err)
+		}
+
+		if rr.TYPEname == "" {
+			rr.TYPEname = strings.TrimPrefix(key, "rdata")
+		}
+	}
+
+	return nil
+}
+
+// ToRFC8427 converts a DNS message to the format of RFC 8427.
+func ToRFC8427(msg *dns.Msg, date time.Time) (*RFC8427, error) {
+	if msg == nil {
+		return nil, errNoMessage
+	}
+
+	ret := &RFC8427{
+		ID:      msg.Id,
+		QR:      msg.Response,
+		Opcode:  msg.Opcode,
+		AA:      msg.Authoritative,
+		TC:      msg.Truncated,
+		RD:      msg.RecursionDesired,
+		RA:      msg.RecursionAvailable,
+		AD:      msg.AuthenticatedData,
+		CD:      msg.CheckingDisabled,
+		RCODE:   msg.Rcode,
+		QDCOUNT: len(msg.Question),
+		ANCOUNT: len(msg.Answer),
+		NSCOUNT: len(msg.Ns),
+		ARCOUNT: len(msg.Extra),
+
+		DateString:  date.Format(time.RFC3339),
+		DateSeconds: date.Unix(),
+	}
+
+	if len(msg.Question) > 0 {
+		q := msg.Question[0]
+
+		ret.QNAME = q.Name
+		ret.QTYPE = q.Qtype
+		ret.QTYPEname = dns.TypeToString[q.Qtype]
+		ret.QCLASS = q.Qclass
+		ret.QCLASSname = dns.ClassToString[q.Qclass]
+	}
+
+	// More than one question can't be shown with QNAME
+	if len(msg.Question) > 1 {
+		for _, q := range msg.Question {
+			ret.QuestionRRs = append(ret.QuestionRRs, RFC8427RR{
+				NAME:      q.Name,
+				TYPE:      q.Qtype,
+				TYPEname:  dns.TypeToString[q.Qtype],
+				CLASS:     q.Qclass,
+				CLASSname: dns.ClassToString[q.Qclass],
+			})
+		}
+	}
+
+	for _, section := range []struct {
+		rrs []dns.RR
+		out *[]RFC8427RR
+	}{
+		{msg.Answer, &ret.AnswerRRs},
+		{msg.Ns, &ret.AuthorityRRs},
+		{msg.Extra, &ret.AdditionalRRs},
+	} {
+		for _, rr := range section.rrs {
+			if rr == nil {
+				continue
+			}
+
+			*section.out = append(*section.out, toRFC8427RR(rr))
+		}
+	}
+
+	// Messages that can't be packed are still printed, without their octets
+	if wire, err := msg.Pack(); err == nil {
+		ret.MessageOctetsHEX = strings.ToUpper(hex.EncodeToString(wire))
+	}
+
+	return ret, nil
+}
+
+func toRFC8427RR(rr dns.RR) RFC8427RR {
+	hdr := rr.Header()
+	ttl := hdr.Ttl
+
+	ret := RFC8427RR{
+		NAME:      hdr.Name,
+		TYPE:      hdr.Rrtype,
+		TYPEname:  dns.TypeToString[hdr.Rrtype],
+		CLASS:     hdr.Class,
+		CLASSname: dns.ClassToString[hdr.Class],
+		TTL:       &ttl,
+	}
+
+	buf := make([]byte, dns.Len(rr))
+
+	// Likewise for records, which keep their presentation format
+	if off, err := dns.PackRR(rr, buf, 0, nil, false); err == nil {
+		rdata := buf[dns.Len(&dns.RR_Header{Name: hdr.Name}):off]
+		length := len(rdata)
+
+		ret.RDLENGTH = &length
+		ret.RDATAHEX = strings.ToUpper(hex.EncodeToString(rdata))
+	}
+
+	// OPT and unknown types have no presentation format
+	switch rr.(type) {
+	case *dns.OPT, *dns.RFC3597:
+	default:
+		if str := rr.String(); strings.HasPrefix(str, hdr.String()) {
+			ret.Rdata = strings.TrimPrefix(str, hdr.String())
+		}
+	}
+
+	return ret
+}
+
+// PrintRFC8427 prints a response as JSON in the format of RFC 8427.
+func PrintRFC8427(res util.Response, opts *util.Options) (string, error) {
+	opts.Logger.Info("Printing as RFC 8427 JSON")
+
+	msg, err := ToRFC8427(res.DNS, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	ret, err := json.MarshalIndent(msg, " ", "  ")
+	if err != nil {
+		return "", fmt.Errorf("rfc8427: %w", err)
+	}
+
+	return string(ret), nil
+}
+
+// ReadRFC8427 reads a DNS message in the format of RFC 8427.
+//
+// When messageOctetsHEX is given it is used, otherwise the message is built
+// from the other members.
+func ReadRFC8427(r io.Reader) (*dns.Msg, error) {
+	var in RFC8427
+
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, fmt.Errorf("rfc8427: %w", err)
+	}
+
+	return in.Msg()
+}
+
+// LoadRFC8427 reads a DNS message in the format of RFC 8427 from a file, "-" is stdin.
+func LoadRFC8427(path string) (*dns.Msg, error) {
+	if path == "-" {
+		return ReadRFC8427(os.Stdin)
+	}
+
+	file, err := os.Open(path) //nolint:gosec // Reading files is the point.
+	if err != nil {
+		return nil, fmt.Errorf("rfc8427: %w", err)
+	}
+	defer file.Close()
+
+	return ReadRFC8427(file)
+}
+
+// Msg converts the message back to a [dns.Msg].
+func (m *RFC8427) Msg() (*dns.Msg, error) {
+	msg := new(dns.Msg)
+
+	if m.MessageOctetsHEX != "" {
+		wire, err := hex.DecodeString(m.MessageOctetsHEX)
+		if err != nil {
+			return nil, fmt.Errorf("rfc8427: messageOctetsHEX: %w", err)
+		}
+
+		if err := msg.Unpack(wire); err != nil {
+			return nil, fmt.Errorf("rfc8427: messageOctetsHEX: %w", err)
+		}
+
+		return msg, nil
+	}
+
+	msg.Id = m.ID
+	msg.Response = m.QR
+	msg.Opcode = m.Opcode
+	msg.Authoritative = m.AA
+	msg.Truncated = m.TC
+	msg.RecursionDesired = m.RD
+	msg.RecursionAvailable = m.RA
+	msg.AuthenticatedData = m.AD
+	msg.CheckingDisabled = m.CD
+	msg.Rcode = m.RCODE
+
+	switch {
+	case len(m.QuestionRRs) > 0:
+		for _, q := range m.QuestionRRs {
+			msg.Question = append(msg.Question, dns.Question{
+				Name:   dns.Fqdn(q.NAME),
+				Qtype:  orType(q.TYPE, q.TYPEname),
+				Qclass: orClass(q.CLASS, q.CLASSname),
+			})
+		}
+	case m.QNAME != "":
+		msg.Question = []dns.Question{{
+			Name:   dns.Fqdn(m.QNAME),
+			Qtype:  orType(m.QTYPE, m.QTYPEname),
+			Qclass: orClass(m.QCLASS, m.QCLASSname),
+		}}
+	}
+
+	for _, section := range []struct {
+		in  []RFC8427RR
+		out *[]dns.RR
+	}{
+		{m.AnswerRRs, &msg.Answer},
+		{m.AuthorityRRs, &msg.Ns},
+		{m.AdditionalRRs, &msg.Extra},
+	} {
+		for _, r := range section.in {
+			rr, err := r.rr()
+			if err != nil {
+				return nil, err
+			}
+
+			*section.out = append(*section.out, rr)
+		}
+	}
+
+	return msg, nil
+}
+
+// rr converts a record back, using RDATAHEX if given and the presentation format if not.
+func (r RFC8427RR) rr() (dns.RR, error) {
+	hdr := dns.RR_Header{
+		Name:   dns.Fqdn(r.NAME),
+		Rrtype: orType(r.TYPE, r.TYPEname),
+		Class:  orClass(r.CLASS, r.CLASSname),
+	}
+
+	if r.TTL != nil {
+		hdr.Ttl = *r.TTL
+	}
+
+	switch {
+	case r.RDATAHEX != "", r.RDLENGTH != nil && *r.RDLENGTH == 0:
+		// Pack as an unknown type and unpack, which works for every type, even OPT
+		unknown := &dns.RFC3597{Hdr: hdr, Rdata: r.RDATAHEX}
+		buf := make([]byte, dns.Len(unknown))
+
+		off, err := dns.PackRR(unknown, buf, 0, nil, false)
+		if err != nil {
+			return nil, fmt.Errorf("rfc8427: %s RDATAHEX: %w", hdr.Name, err)
+		}
+
+		rr, _, err := dns.UnpackRR(buf[:off], 0)
+		if err != nil {
+			return nil, fmt.Errorf("rfc8427: %s RDATAHEX: %w", hdr.Name, err)
+		}
+
+		return rr, nil
+	case r.Rdata != "":
+		rr, err := dns.NewRR(fmt.Sprintf("%s %d %s %s %s",
+			hdr.Name, hdr.Ttl, dns.Class(hdr.Class), dns.Type(hdr.Rrtype), r.Rdata))
+		if err != nil {
+			return nil, fmt.Errorf("rfc8427: %s rdata: %w", hdr.Name, err)
+		}
+
+		return rr, nil
+	default:
+		return nil, fmt.Errorf("rfc8427: %s: %w", hdr.Name, errNoRdata)
+	}
+}
+
+// orType uses the number of a type, or the name if the number is not given.
+func orType(num uint16, name string) uint16 {
+	if num == 0 {
+		return dns.StringToType[strings.ToUpper(name)]
+	}
+
+	return num
+}
+
+// orClass uses the number of a class, or the name if the number is not given.
+func orClass(num uint16, name string) uint16 {
+	if num == 0 {
+		if class, ok := dns.StringToClass[strings.ToUpper(name)]; ok {
+			return class
+		}
+
+		return dns.ClassINET
+	}
+
+	return num
+}
+
+var errNoRdata = errors.New("no RDATAHEX or rdata member")
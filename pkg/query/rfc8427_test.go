@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package query_test
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"dns.froth.zone/awl/pkg/query"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+func TestRFC8427(t *testing.T) {
+	t.Parallel()
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.com.", dns.TypeMX)
+	msg.Response = true
+	msg.RecursionAvailable = true
+
+	for _, str := range []string{
+		"example.com. 300 IN MX 10 mail.example.com.",
+		`example.com. 300 IN TYPE65280 \# 2 abcd`,
+	} {
+		rr, err := dns.NewRR(str)
+		assert.NilError(t, err)
+
+		msg.Answer = append(msg.Answer, rr)
+	}
+
+	msg.SetEdns0(1232, true)
+
+	out, err := query.ToRFC8427(msg, time.Unix(0, 0))
+	assert.NilError(t, err)
+
+	res, err := json.Marshal(out)
+	assert.NilError(t, err)
+
+	str := string(res)
+	assert.Assert(t, strings.Contains(str, `"QR":true`), str)
+	assert.Assert(t, strings.Contains(str, `"QNAME":"example.com."`), str)
+	assert.Assert(t, strings.Contains(str, `"RDATAHEX":"000A046D61696C076578616D706C6503636F6D00","rdataMX":"10 mail.example.com."`), str)
+	assert.Assert(t, strings.Contains(str, `"messageOctetsHEX":"`), str)
+
+	back, err := query.ReadRFC8427(strings.NewReader(str))
+	assert.NilError(t, err)
+	assert.Equal(t, back.String(), msg.String())
+
+	// Without the whole message, it has to be rebuilt from the records
+	out.MessageOctetsHEX = ""
+
+	res, err = json.Marshal(out)
+	assert.NilError(t, err)
+
+	back, err = query.ReadRFC8427(strings.NewReader(string(res)))
+	assert.NilError(t, err)
+	assert.Equal(t, back.String(), msg.String())
+
+	// Records that can't be packed are printed without their octets
+	msg.Answer = append(msg.Answer, &dns.A{
+		Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300},
+		A:   net.IP{192, 0, 2},
+	})
+
+	out, err = query.ToRFC8427(msg, time.Unix(0, 0))
+	assert.NilError(t, err)
+	assert.Equal(t, out.MessageOctetsHEX, "")
+	assert.Equal(t, len(out.AnswerRRs), 3)
+	assert.Equal(t, out.AnswerRRs[2].RDATAHEX, "")
+	assert.Assert(t, out.AnswerRRs[2].RDLENGTH == nil)
+}
+
+func TestReadRFC8427(t *testing.T) {
+	t.Parallel()
+
+	// Example from RFC 8427, section 4.1, with only the presentation format
+	in := `{ "ID": 32784, "QR": 1, "AA": 1, "RCODE": 0, "QDCOUNT": 1, "ANCOUNT": 1,
+		"QNAME": "example.com", "QTYPE": 1, "QCLASS": 1,
+		"answerRRs": [{ "NAME": "example.com", "TYPE": 1, "CLASS": 1, "TTL": 3600, "rdataA": "192.0.2.1" }] }`
+
+	_, err := query.ReadRFC8427(strings.NewReader(in))
+	assert.ErrorContains(t, err, "rfc8427")
+
+	in = strings.ReplaceAll(strings.ReplaceAll(in, `"QR": 1`, `"QR": true`), `"AA": 1`, `"AA": true`)
+
+	msg, err := query.ReadRFC8427(strings.NewReader(in))
+	assert.NilError(t, err)
+	assert.Equal(t, msg.Id, uint16(32784))
+	assert.Assert(t, msg.Authoritative)
+	assert.Equal(t, msg.Question[0].Name, "example.com.")
+	assert.Equal(t, len(msg.Answer), 1)
+	assert.Equal(t, msg.Answer[0].String(), "example.com.\t3600\tIN\tA\t192.0.2.1")
+
+	_, err = query.ReadRFC8427(strings.NewReader(`{"answerRRs": [{"NAME": "example.com", "TYPE": 1}]}`))
+	assert.ErrorContains(t, err, "no RDATAHEX or rdata member")
+}
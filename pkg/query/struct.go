@@ -4,6 +4,9 @@ package query
 
 import (
 	"errors"
+	"time"
+
+	"dns.froth.zone/awl/pkg/dnssec"
 )
 
 // Message is for overall DNS responses.
@@ -15,6 +18,19 @@ type Message struct {
 	MsgSize     int    `json:"msgLength,omitempty" xml:"msgSize,omitempty" yaml:"msgSize,omitempty"`
 	ID          uint16 `json:"ID" xml:"ID" yaml:"ID" example:"12"`
 
+	// Where and how the response was received
+	Server      string        `json:"server,omitempty" xml:"server,omitempty" yaml:"server,omitempty" example:"1.1.1.1"`
+	Port        int           `json:"port,omitempty" xml:"port,omitempty" yaml:"port,omitempty" example:"53"`
+	Protocol    string        `json:"protocol,omitempty" xml:"protocol,omitempty" yaml:"protocol,omitempty" example:"UDP"`
+	RTT         time.Duration `json:"rtt,omitempty" xml:"rtt,omitempty" yaml:"rtt,omitempty" example:"2000000"`
+	Retries     int           `json:"retries,omitempty" xml:"retries,omitempty" yaml:"retries,omitempty" example:"0"`
+	TCPFallback bool          `json:"tcpFallback,omitempty" xml:"tcpFallback,omitempty" yaml:"tcpFallback,omitempty" example:"false"`
+	BadCookie   bool          `json:"badCookie,omitempty" xml:"badCookie,omitempty" yaml:"badCookie,omitempty" example:"false"`
+
+	// The packed message, and query with --wire, as in RFC 8427
+	MessageOctetsHEX string `json:"messageOctetsHEX,omitempty" xml:"messageOctetsHEX,omitempty" yaml:"messageOctetsHEX,omitempty"`
+	QueryOctetsHEX   string `json:"queryOctetsHEX,omitempty" xml:"queryOctetsHEX,omitempty" yaml:"queryOctetsHEX,omitempty"`
+
 	Opcode             int  `json:"opcode" xml:"opcode" yaml:"opcode" example:"QUERY"`
 	Response           bool `json:"QR" xml:"QR" yaml:"QR" example:"true"`
 	Authoritative      bool `json:"AA" xml:"AA" yaml:"AA" example:"false"`
@@ -38,6 +54,8 @@ type Message struct {
 
 	EDNS0 EDNS0 `json:",omitempty" xml:",omitempty" yaml:",omitempty"`
 
+	DNSSEC *dnssec.Result `json:"DNSSEC,omitempty" xml:"DNSSEC,omitempty" yaml:"DNSSEC,omitempty"`
+
 	// Answer Section
 	AnswerRRs        []Answer `json:"answersRRs,omitempty" xml:"answersRRs,omitempty" yaml:"answersRRs,omitempty" example:"false"`
 	AuthoritativeRRs []Answer `json:"authorityRRs,omitempty" xml:"authorityRRs,omitempty" yaml:"authorityRRs,omitempty" example:"false"`
@@ -55,8 +73,10 @@ type Answer struct {
 	ClassName string `json:"CLASSname,omitempty" xml:"CLASSname,omitempty" yaml:"CLASSname,omitempty" example:"IN"`
 	TTL       any    `json:"TTL,omitempty" xml:"TTL,omitempty" yaml:"TTL,omitempty" example:"0ms"`
 	Value     string `json:"rdata,omitempty" xml:"rdata,omitempty" yaml:"rdata,omitempty"`
-	Rdlength  uint16 `json:"RDLENGTH,omitempty" xml:"RDLENGTH,omitempty" yaml:"RDLENGTH,omitempty"`
-	Rdhex     string `json:"RDATAHEX,omitempty" xml:"RDATAHEX,omitempty" yaml:"RDATAHEX,omitempty"`
+	// RDATA split into fields, empty for unknown types
+	Rdata    Rdata  `json:"RDATA,omitempty" xml:"RDATA,omitempty" yaml:"RDATA,omitempty"`
+	Rdlength uint16 `json:"RDLENGTH,omitempty" xml:"RDLENGTH,omitempty" yaml:"RDLENGTH,omitempty"`
+	Rdhex    string `json:"RDATAHEX,omitempty" xml:"RDATAHEX,omitempty" yaml:"RDATAHEX,omitempty"`
 }
 
 // EDNS0 is for all EDNS options.
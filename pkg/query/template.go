@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"text/template"
+	"time"
+
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+	"golang.org/x/net/idna"
+)
+
+// TemplateData is what a template given with --template is run against.
+// Every field of [Message] can be used directly, eg. {{.AnswerRRs}}.
+type TemplateData struct {
+	*Message
+	// The full response, for anything not in Message
+	DNS *dns.Msg
+	// Response code, eg. NOERROR
+	Status string
+	// Server the query was sent to
+	Server string
+	// Time the query took
+	RTT time.Duration
+}
+
+// TemplateFuncs are the functions that can be used in templates, on top of
+// the ones built into text/template.
+var TemplateFuncs = template.FuncMap{
+	// TTL in words, eg. "1 hour 30 minutes"
+	"humanTTL": func(ttl any) (string, error) {
+		secs, err := toSeconds(ttl)
+		if err != nil {
+			return "", err
+		}
+
+		return humanize(secs), nil
+	},
+	"toUnicode": idna.ToUnicode,
+	"toASCII":   idna.ToASCII,
+	// Name to query for a reverse lookup, of an IP address or phone number
+	"reverse": func(addr string) (string, error) {
+		if net.ParseIP(addr) != nil {
+			return util.ReverseDNS(addr, dns.TypePTR) //nolint:wrapcheck // Shown as is
+		}
+
+		return util.ReverseDNS(addr, dns.TypeNAPTR) //nolint:wrapcheck // Shown as is
+	},
+	"trimDot": func(name string) string { return strings.TrimSuffix(name, ".") },
+	"join":    strings.Join,
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"json": func(v any) (string, error) {
+		ret, err := json.Marshal(v)
+
+		return string(ret), err //nolint:wrapcheck // Shown as is
+	},
+}
+
+// templateFormatter runs the template given by --template.
+type templateFormatter struct{}
+
+func (f *templateFormatter) FormatResponse(res util.Response, opts *util.Options) (string, error) {
+	formatted, err := MakePrintable(res, opts)
+	if err != nil {
+		return "", err
+	}
+
+	return f.FormatValue(TemplateData{
+		Message: formatted,
+		DNS:     res.DNS,
+		Status:  dns.RcodeToString[res.DNS.Rcode],
+		Server:  opts.Request.Server,
+		RTT:     res.RTT,
+	}, opts)
+}
+
+func (f *templateFormatter) FormatValue(v any, opts *util.Options) (string, error) {
+	opts.Logger.Info("Printing with a template")
+
+	if opts.Template == "" {
+		return "", fmt.Errorf("template: %w", errNoTemplate)
+	}
+
+	tmpl, err := template.New("awl").Funcs(TemplateFuncs).Parse(opts.Template)
+	if err != nil {
+		return "", fmt.Errorf("template: %w", err)
+	}
+
+	var buf bytes.Buffer
+
+	if err := tmpl.Execute(&buf, v); err != nil {
+		return "", fmt.Errorf("template: %w", err)
+	}
+
+	// fmt.Println adds the last newline
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// toSeconds turns a TTL from a template into seconds.
+func toSeconds(ttl any) (uint32, error) {
+	switch ttl := ttl.(type) {
+	case uint32:
+		return ttl, nil
+	case int:
+		return uint32(ttl), nil //nolint:gosec // TTLs are 32 bits
+	case string:
+		// Already humanised with +ttlunits
+		dur, err := time.ParseDuration(ttl)
+		if err != nil {
+			return 0, fmt.Errorf("humanTTL: %w", err)
+		}
+
+		return uint32(dur / time.Second), nil
+	default:
+		return 0, fmt.Errorf("humanTTL: %w: %T", errNotTTL, ttl)
+	}
+}
+
+var (
+	errNoTemplate = errors.New("no template given")
+	errNotTTL     = errors.New("not a TTL")
+)
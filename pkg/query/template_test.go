@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package query_test
+
+import (
+	"testing"
+	"time"
+
+	"dns.froth.zone/awl/pkg/query"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+func TestTemplate(t *testing.T) {
+	t.Parallel()
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("xn--bcher-kva.example.", dns.TypeA)
+	msg.Rcode = dns.RcodeSuccess
+
+	rr, err := dns.NewRR("xn--bcher-kva.example. 5400 IN A 192.0.2.1")
+	assert.NilError(t, err)
+
+	msg.Answer = []dns.RR{rr}
+
+	tests := []struct {
+		name string
+		tmpl string
+		want string
+	}{
+		{
+			"Answers",
+			`{{range .AnswerRRs}}{{.Name}} {{.Value}}{{"\n"}}{{end}}`,
+			"xn--bcher-kva.example. 192.0.2.1",
+		},
+		{
+			"Helpers",
+			`{{range .AnswerRRs}}{{toUnicode .Name | trimDot}} {{humanTTL .TTL}} {{reverse .Value}}{{end}}`,
+			"bücher.example 1 hour 30 minutes 1.2.0.192.in-addr.arpa.",
+		},
+		{
+			"Phone",
+			`{{reverse "+1-555-1234"}}`,
+			"4.3.2.1.5.5.5.1.e164.arpa.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := &util.Options{
+				Logger:   util.InitLogger(0),
+				Format:   "template",
+				Template: test.tmpl,
+				Display:  util.Display{Answer: true, TTL: true},
+				Request:  util.Request{Server: "192.0.2.53"},
+			}
+
+			str, err := query.PrintSpecial(util.Response{DNS: msg}, opts)
+			assert.NilError(t, err)
+			assert.Equal(t, str, test.want)
+		})
+	}
+
+	opts := &util.Options{
+		Logger:   util.InitLogger(0),
+		Format:   "template",
+		Template: `{{.Status}} from {{.Server}} in {{.RTT}}, {{.AnCount}} answer`,
+		Request:  util.Request{Server: "192.0.2.53"},
+	}
+
+	str, err := query.PrintSpecial(util.Response{DNS: msg, RTT: time.Millisecond}, opts)
+	assert.NilError(t, err)
+	assert.Equal(t, str, "NOERROR from 192.0.2.53 in 1ms, 1 answer")
+
+	opts.Template = ""
+
+	_, err = query.PrintSpecial(util.Response{DNS: msg}, opts)
+	assert.ErrorContains(t, err, "no template given")
+
+	opts.Template = "{{.Nope"
+
+	_, err = query.Marshal([]string{"a"}, opts)
+	assert.ErrorContains(t, err, "template:")
+}
@@ -74,6 +74,8 @@ func (message *Message) displayAnswers(msg *dns.Msg, opts *util.Options, opt *dn
 			TTL:       ttl,
 
 			Value: temp[len(temp)-1],
+			Rdata: ParseRdata(answer),
+			Rdhex: rdhex(answer),
 		})
 	}
 
@@ -117,6 +119,8 @@ func (message *Message) displayAuthority(msg *dns.Msg, opts *util.Options, opt *
 			TTL:       ttl,
 
 			Value: temp[len(temp)-1],
+			Rdata: ParseRdata(ns),
+			Rdhex: rdhex(ns),
 		})
 	}
 
@@ -161,6 +165,8 @@ func (message *Message) displayAdditional(msg *dns.Msg, opts *util.Options, opt
 				Rdlength:  additional.Header().Rdlength,
 				TTL:       ttl,
 				Value:     temp[len(temp)-1],
+				Rdata:     ParseRdata(additional),
+				Rdhex:     rdhex(additional),
 			})
 		}
 	}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package query
+
+import (
+	"fmt"
+
+	"dns.froth.zone/awl/pkg/dnssec"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+)
+
+// Validate validates a response with DNSSEC, fetching the DNSKEY and DS records
+// needed from the same server the response came from.
+func Validate(res util.Response, opts *util.Options) (*dnssec.Result, error) {
+	if res.DNS == nil {
+		return nil, errNoMessage
+	}
+
+	anchors := dnssec.RootAnchors()
+
+	if opts.TrustAnchor != "" {
+		var err error
+
+		anchors, err = dnssec.LoadAnchors(opts.TrustAnchor)
+		if err != nil {
+			return nil, fmt.Errorf("trust anchor: %w", err)
+		}
+	}
+
+	exchange := func(name string, qtype uint16) (*dns.Msg, error) {
+		sub := *opts
+		sub.Validate = false
+		sub.Display.ShowQuery = false
+		sub.Request.Name = name
+		sub.Request.Type = qtype
+		sub.Request.Class = dns.ClassINET
+		sub.EDNS.EnableEDNS = true
+		sub.EDNS.DNSSEC = true
+		sub.RD = true
+		sub.CD = true
+
+		var (
+			resp util.Response
+			err  error
+		)
+
+		for i := 0; i <= sub.Request.Retries; i++ {
+			resp, err = CreateQuery(&sub)
+			if err == nil {
+				return resp.DNS, nil
+			}
+		}
+
+		return nil, err
+	}
+
+	opts.Logger.Info("Validating response")
+
+	validator := dnssec.New(anchors, exchange)
+	result := validator.Validate(res.DNS)
+
+	if opts.Sigchase {
+		result.Chain = validator.Chain()
+	}
+
+	return &result, nil
+}
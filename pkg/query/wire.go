@@ -0,0 +1,287 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package query
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+)
+
+// Bytes shown on each line of an annotated dump
+const width = 8
+
+// Hexdump packs a message and dumps it as hex, with offsets.
+//
+// When annotate is set, every field of the header, question and records is
+// shown on its own line with what it contains, instead of 16 bytes a line.
+func Hexdump(msg *dns.Msg, annotate bool) (string, error) {
+	buf, err := msg.Pack()
+	if err != nil {
+		return "", fmt.Errorf("hexdump: %w", err)
+	}
+
+	return hexdump(buf, msg, annotate)
+}
+
+// hexdump dumps a message already packed.
+func hexdump(buf []byte, msg *dns.Msg, annotate bool) (string, error) {
+	if !annotate {
+		return strings.TrimSuffix(hex.Dump(buf), "\n"), nil
+	}
+
+	spans, err := annotations(buf, msg)
+	if err != nil {
+		return "", err
+	}
+
+	var s strings.Builder
+
+	for _, span := range spans {
+		if span.section != "" {
+			s.WriteString("; " + span.section + "\n")
+		}
+
+		// Print empty RDATA too
+		for off := span.off; off < span.end || off == span.off; off += width {
+			line := fmt.Sprintf("%04x  %-*s", off, width*3, hexBytes(buf[off:min(off+width, span.end)]))
+			if off == span.off {
+				line += " ; " + span.note
+			}
+
+			s.WriteString(strings.TrimRight(line, " ") + "\n")
+		}
+	}
+
+	return strings.TrimSuffix(s.String(), "\n"), nil
+}
+
+// wireString dumps the query and response of a result, as printed by --wire.
+func wireString(res util.Response, opts *util.Options) (string, error) {
+	var s string
+
+	raw := rawWire(res)
+
+	for _, sent := range []struct {
+		msg *dns.Msg
+		buf []byte
+	}{
+		{res.Query, raw.Query},
+		{res.DNS, raw.Response},
+	} {
+		if sent.msg == nil {
+			continue
+		}
+
+		buf, err := wire(sent.msg, sent.buf)
+		if err != nil {
+			return "", err
+		}
+
+		dump, err := hexdump(buf, sent.msg, opts.Display.Annotate)
+		if err != nil {
+			return "", err
+		}
+
+		kind := "QUERY"
+		if sent.msg.Response {
+			kind = "RESPONSE"
+		}
+
+		s += "\n" + paint(opts, bold, fmt.Sprintf(";; %s, %d bytes:", kind, len(buf))) + "\n"
+
+		// DoQ sends the length first, as with TCP
+		if opts.QUIC {
+			prefix := binary.BigEndian.AppendUint16(nil, uint16(len(buf))) //nolint:gosec // Messages are under 64k
+			s += ";; DoQ length prefix: " + hexBytes(prefix) + "\n"
+		}
+
+		s += dump + "\n"
+	}
+
+	return s, nil
+}
+
+// rawWire returns the messages of a result as they were sent and received, empty when not known.
+func rawWire(res util.Response) util.Wire {
+	if res.Wire == nil {
+		return util.Wire{}
+	}
+
+	return *res.Wire
+}
+
+// wire returns a message as it was sent or received, packing it again when
+// that is not known, eg. with DNSCrypt or for zone transfers.
+func wire(msg *dns.Msg, buf []byte) ([]byte, error) {
+	if buf != nil {
+		return buf, nil
+	}
+
+	buf, err := msg.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("hexdump: %w", err)
+	}
+
+	return buf, nil
+}
+
+// span is a field of a message, for annotated dumps.
+type span struct {
+	off, end int
+	// Set on the first field of a section
+	section string
+	note    string
+}
+
+// annotations splits a packed message into its fields.
+func annotations(buf []byte, msg *dns.Msg) ([]span, error) {
+	if len(buf) < 12 {
+		return nil, fmt.Errorf("hexdump: %w", dns.ErrShortRead)
+	}
+
+	count := func(off int) uint16 { return binary.BigEndian.Uint16(buf[off:]) }
+
+	spans := []span{
+		{0, 2, "header", "id: " + strconv.Itoa(int(msg.Id))},
+		{2, 4, "", fmt.Sprintf("flags: %s, opcode: %s, rcode: %s",
+			flagNames(msg.MsgHdr), dns.OpcodeToString[msg.Opcode], dns.RcodeToString[msg.Rcode&0xF])},
+		{4, 6, "", fmt.Sprintf("qdcount: %d", count(4))},
+		{6, 8, "", fmt.Sprintf("ancount: %d", count(6))},
+		{8, 10, "", fmt.Sprintf("nscount: %d", count(8))},
+		{10, 12, "", fmt.Sprintf("arcount: %d", count(10))},
+	}
+
+	off := 12
+
+	for i := range int(count(4)) {
+		name, end, err := dns.UnpackDomainName(buf, off)
+		if err != nil || end+4 > len(buf) {
+			return nil, fmt.Errorf("hexdump: question: %w", orShort(err))
+		}
+
+		section := ""
+		if i == 0 {
+			section = "question"
+		}
+
+		spans = append(spans,
+			span{off, end, section, "name: " + name},
+			span{end, end + 2, "", "type: " + dns.Type(binary.BigEndian.Uint16(buf[end:])).String()},
+			span{end + 2, end + 4, "", "class: " + dns.Class(binary.BigEndian.Uint16(buf[end+2:])).String()},
+		)
+
+		off = end + 4
+	}
+
+	for _, section := range []struct {
+		name  string
+		count uint16
+	}{
+		{"answer", count(6)},
+		{"authority", count(8)},
+		{"additional", count(10)},
+	} {
+		for i := range int(section.count) {
+			name := ""
+			if i == 0 {
+				name = section.name
+			}
+
+			rr, err := rrSpans(buf, off, name)
+			if err != nil {
+				return nil, err
+			}
+
+			spans = append(spans, rr...)
+			off = rr[len(rr)-1].end
+		}
+	}
+
+	return spans, nil
+}
+
+// rrSpans splits a record into its fields.
+func rrSpans(buf []byte, off int, section string) ([]span, error) {
+	rr, end, err := dns.UnpackRR(buf, off)
+	if err != nil {
+		return nil, fmt.Errorf("hexdump: %w", err)
+	}
+
+	name, start, err := dns.UnpackDomainName(buf, off)
+	if err != nil {
+		return nil, fmt.Errorf("hexdump: %w", err)
+	}
+
+	hdr := rr.Header()
+	rdata := strings.TrimPrefix(rr.String(), hdr.String())
+
+	class := "class: " + dns.Class(hdr.Class).String()
+	ttl := "ttl: " + strconv.FormatUint(uint64(hdr.Ttl), 10)
+
+	// OPT uses these for other things
+	if hdr.Rrtype == dns.TypeOPT {
+		class = "udp size: " + strconv.Itoa(int(hdr.Class))
+		ttl = fmt.Sprintf("extended rcode and flags: 0x%08x", hdr.Ttl)
+		if opt, ok := rr.(*dns.OPT); ok {
+			rdata = fmt.Sprintf("%d options", len(opt.Option))
+		}
+	}
+
+	return []span{
+		{off, start, section, "name: " + name},
+		{start, start + 2, "", "type: " + dns.Type(hdr.Rrtype).String()},
+		{start + 2, start + 4, "", class},
+		{start + 4, start + 8, "", ttl},
+		{start + 8, start + 10, "", "rdlength: " + strconv.Itoa(int(hdr.Rdlength))},
+		{start + 10, end, "", "rdata: " + rdata},
+	}, nil
+}
+
+// flagNames lists the flags set in a header, as dig does.
+func flagNames(hdr dns.MsgHdr) string {
+	var flags []string
+
+	for _, flag := range []struct {
+		set  bool
+		name string
+	}{
+		{hdr.Response, "qr"},
+		{hdr.Authoritative, "aa"},
+		{hdr.Truncated, "tc"},
+		{hdr.RecursionDesired, "rd"},
+		{hdr.RecursionAvailable, "ra"},
+		{hdr.Zero, "z"},
+		{hdr.AuthenticatedData, "ad"},
+		{hdr.CheckingDisabled, "cd"},
+	} {
+		if flag.set {
+			flags = append(flags, flag.name)
+		}
+	}
+
+	return strings.Join(flags, " ")
+}
+
+// hexBytes prints bytes in hex, separated by spaces.
+func hexBytes(buf []byte) string {
+	ret := make([]string, 0, len(buf))
+	for _, b := range buf {
+		ret = append(ret, fmt.Sprintf("%02x", b))
+	}
+
+	return strings.Join(ret, " ")
+}
+
+func orShort(err error) error {
+	if err != nil {
+		return err
+	}
+
+	return dns.ErrShortRead
+}
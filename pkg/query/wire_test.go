@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package query_test
+
+import (
+	"strings"
+	"testing"
+
+	"dns.froth.zone/awl/pkg/query"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+func wireMsgs(t *testing.T) (*dns.Msg, *dns.Msg) {
+	t.Helper()
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.com.", dns.TypeA)
+	req.Id = 0x1234
+	req.SetEdns0(1232, true)
+
+	res := new(dns.Msg)
+	res.SetReply(req)
+
+	rr, err := dns.NewRR("example.com. 300 IN A 192.0.2.1")
+	assert.NilError(t, err)
+
+	res.Answer = []dns.RR{rr}
+
+	return req, res
+}
+
+func TestHexdump(t *testing.T) {
+	t.Parallel()
+
+	req, _ := wireMsgs(t)
+
+	str, err := query.Hexdump(req, false)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.HasPrefix(str, "00000000  12 34 01 00 00 01 00 00  00 00 00 01 07 65 78 61  |.4...........exa|"), str)
+
+	str, err = query.Hexdump(req, true)
+	assert.NilError(t, err)
+
+	for _, line := range []string{
+		"; header",
+		"0000  12 34                    ; id: 4660",
+		"0002  01 00                    ; flags: rd, opcode: QUERY, rcode: NOERROR",
+		"000a  00 01                    ; arcount: 1",
+		"; question",
+		"000c  07 65 78 61 6d 70 6c 65  ; name: example.com.",
+		"0014  03 63 6f 6d 00",
+		"0019  00 01                    ; type: A",
+		"; additional",
+		"001d  00                       ; name: .",
+		"0020  04 d0                    ; udp size: 1232",
+		"0022  00 00 80 00              ; extended rcode and flags: 0x00008000",
+		"0028                           ; rdata: 0 options",
+	} {
+		assert.Assert(t, strings.Contains(str, line+"\n") || strings.HasSuffix(str, line), "%q not in\n%s", line, str)
+	}
+}
+
+func TestWire(t *testing.T) {
+	t.Parallel()
+
+	req, res := wireMsgs(t)
+
+	opts := &util.Options{Logger: util.InitLogger(0), Display: util.Display{Wire: true}}
+
+	str, err := query.ToString(util.Response{DNS: res, Query: req}, opts)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(str, ";; QUERY, 40 bytes:\n00000000  12 34 01 00"), str)
+	assert.Assert(t, strings.Contains(str, ";; RESPONSE, 56 bytes:\n00000000  12 34 81 00"), str)
+	assert.Assert(t, !strings.Contains(str, "DoQ"), str)
+
+	// The bytes as sent are dumped instead of packing the query again
+	raw, err := req.Pack()
+	assert.NilError(t, err)
+
+	raw[0], raw[1] = 0xab, 0xcd
+
+	str, err = query.ToString(util.Response{DNS: res, Query: req, Wire: &util.Wire{Query: raw}}, opts)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(str, ";; QUERY, 40 bytes:\n00000000  ab cd 01 00"), str)
+	assert.Assert(t, strings.Contains(str, ";; RESPONSE, 56 bytes:\n00000000  12 34 81 00"), str)
+
+	opts.QUIC = true
+	opts.Display.Annotate = true
+
+	str, err = query.ToString(util.Response{DNS: res, Query: req}, opts)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(str, ";; QUERY, 40 bytes:\n;; DoQ length prefix: 00 28\n; header\n"), str)
+	assert.Assert(t, strings.Contains(str, "; answer\n"), str)
+	assert.Assert(t, strings.Contains(str, "; rdata: 192.0.2.1\n"), str)
+
+	msg, err := query.MakePrintable(util.Response{DNS: res, Query: req}, opts)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.HasPrefix(msg.MessageOctetsHEX, "123481000001000100000000"), msg.MessageOctetsHEX)
+	assert.Assert(t, strings.HasPrefix(msg.QueryOctetsHEX, "123401000001000000000001"), msg.QueryOctetsHEX)
+
+	opts.Display.Wire, opts.Display.Annotate = false, false
+
+	msg, err = query.MakePrintable(util.Response{DNS: res, Query: req}, opts)
+	assert.NilError(t, err)
+	assert.Assert(t, msg.MessageOctetsHEX != "")
+	assert.Equal(t, msg.QueryOctetsHEX, "")
+}
@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package query
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+)
+
+// zoneFormatter prints responses as an RFC 1035 master file.
+type zoneFormatter struct{}
+
+func (f *zoneFormatter) FormatResponse(res util.Response, opts *util.Options) (string, error) {
+	opts.Logger.Info("Printing as a zone file")
+
+	if res.DNS == nil {
+		return "", errNoMessage
+	}
+
+	return ToZone(res.DNS), nil
+}
+
+func (f *zoneFormatter) FormatValue(any, *util.Options) (string, error) {
+	return "", fmt.Errorf("zone: %w", ErrOnlyResponses)
+}
+
+func (f *zoneFormatter) onlyResponses() {}
+
+// ToZone prints the records of a message in master file syntax, which can be
+// read by [dns.ZoneParser] or BIND.
+//
+// $ORIGIN is the SOA owner if there is one, otherwise the question name, and
+// names under it are made relative. $TTL is the most common TTL.
+// The question is kept as a comment.
+func ToZone(msg *dns.Msg) string {
+	var (
+		s   strings.Builder
+		rrs []dns.RR
+	)
+
+	for _, section := range [][]dns.RR{msg.Answer, msg.Ns, msg.Extra} {
+		for _, rr := range section {
+			if rr != nil && rr.Header().Rrtype != dns.TypeOPT {
+				rrs = append(rrs, rr)
+			}
+		}
+	}
+
+	// A zone transfer ends with the SOA it starts with
+	if len(rrs) > 1 && rrs[0].Header().Rrtype == dns.TypeSOA && dns.IsDuplicate(rrs[0], rrs[len(rrs)-1]) {
+		rrs = rrs[:len(rrs)-1]
+	}
+
+	origin := "."
+	if len(msg.Question) > 0 {
+		origin = dns.CanonicalName(msg.Question[0].Name)
+	}
+
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == dns.TypeSOA {
+			origin = dns.CanonicalName(rr.Header().Name)
+
+			break
+		}
+	}
+
+	for _, q := range msg.Question {
+		s.WriteString("; " + strings.TrimPrefix(q.String(), ";") + "\n")
+	}
+
+	s.WriteString("; status: " + dns.RcodeToString[msg.Rcode] + "\n")
+	s.WriteString("$ORIGIN " + origin + "\n")
+
+	ttl, hasTTL := commonTTL(rrs)
+	if hasTTL {
+		s.WriteString("$TTL " + strconv.FormatUint(uint64(ttl), 10) + "\n")
+	}
+
+	for _, rr := range rrs {
+		rel := dns.Copy(rr)
+		relative(rel, origin)
+
+		// The name, TTL, class, type and RDATA are separated by tabs
+		fields := strings.SplitN(rel.String(), "\t", 5)
+		if len(fields) == 5 && hasTTL && rr.Header().Ttl == ttl {
+			fields[1] = ""
+		}
+
+		if dns.CanonicalName(rr.Header().Name) == origin {
+			fields[0] = "@"
+		}
+
+		s.WriteString(strings.Join(fields, "\t") + "\n")
+	}
+
+	return strings.TrimSuffix(s.String(), "\n")
+}
+
+// commonTTL finds the most common TTL, preferring the one seen first.
+func commonTTL(rrs []dns.RR) (uint32, bool) {
+	var (
+		counts = make(map[uint32]int)
+		best   uint32
+	)
+
+	for _, rr := range rrs {
+		ttl := rr.Header().Ttl
+		counts[ttl]++
+
+		if counts[ttl] > counts[best] {
+			best = ttl
+		}
+	}
+
+	return best, len(rrs) > 0
+}
+
+// relative makes the owner name and the domain names in the RDATA of a record
+// relative to the origin, where possible.
+//
+// Names that are the origin itself are left alone, as "@" would be escaped.
+func relative(rr dns.RR, origin string) {
+	hdr := rr.Header()
+	hdr.Name = relativeName(hdr.Name, origin)
+
+	relativeFields(reflect.ValueOf(rr).Elem(), origin)
+}
+
+func relativeFields(val reflect.Value, origin string) {
+	for i := range val.NumField() {
+		field := val.Type().Field(i)
+
+		switch {
+		case !field.IsExported() || field.Type == reflect.TypeOf(dns.RR_Header{}):
+		case field.Anonymous && field.Type.Kind() == reflect.Struct:
+			relativeFields(val.Field(i), origin)
+		case field.Type.Kind() == reflect.String && strings.HasSuffix(field.Tag.Get("dns"), "domain-name"):
+			val.Field(i).SetString(relativeName(val.Field(i).String(), origin))
+		}
+	}
+}
+
+// relativeName makes a name relative to the origin.
+func relativeName(name, origin string) string {
+	if origin == "." || !dns.IsSubDomain(origin, name) || dns.CanonicalName(name) == origin {
+		return name
+	}
+
+	return strings.TrimSuffix(name[:len(name)-len(origin)], ".")
+}
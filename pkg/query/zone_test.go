@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package query_test
+
+import (
+	"strings"
+	"testing"
+
+	"dns.froth.zone/awl/pkg/query"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+func TestZone(t *testing.T) {
+	t.Parallel()
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.com.", dns.TypeAXFR)
+
+	for _, str := range []string{
+		"example.com. 3600 IN SOA ns.example.com. hostmaster.example.com. 1 7200 3600 1209600 300",
+		"example.com. 3600 IN NS ns.example.com.",
+		"example.com. 3600 IN NS ns.example.net.",
+		"ns.example.com. 3600 IN A 192.0.2.1",
+		"www.example.com. 300 IN CNAME example.com.",
+		`example.com. 3600 IN TXT "v=spf1 -all"`,
+		"example.com. 3600 IN SOA ns.example.com. hostmaster.example.com. 1 7200 3600 1209600 300",
+	} {
+		rr, err := dns.NewRR(str)
+		assert.NilError(t, err)
+
+		msg.Answer = append(msg.Answer, rr)
+	}
+
+	opts := &util.Options{Logger: util.InitLogger(0), Format: "zone"}
+
+	str, err := query.PrintSpecial(util.Response{DNS: msg}, opts)
+	assert.NilError(t, err)
+	assert.Equal(t, str, "; example.com.\tIN\t AXFR\n"+
+		"; status: NOERROR\n"+
+		"$ORIGIN example.com.\n"+
+		"$TTL 3600\n"+
+		"@\t\tIN\tSOA\tns hostmaster 1 7200 3600 1209600 300\n"+
+		"@\t\tIN\tNS\tns\n"+
+		"@\t\tIN\tNS\tns.example.net.\n"+
+		"ns\t\tIN\tA\t192.0.2.1\n"+
+		"www\t300\tIN\tCNAME\texample.com.\n"+
+		"@\t\tIN\tTXT\t\"v=spf1 -all\"")
+
+	// It has to be read back the same
+	parser := dns.NewZoneParser(strings.NewReader(str), "", "")
+
+	i := 0
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		assert.Equal(t, rr.String(), msg.Answer[i].String())
+		i++
+	}
+
+	assert.NilError(t, parser.Err())
+	assert.Equal(t, i, len(msg.Answer)-1)
+
+	_, err = query.Marshal([]string{"a"}, opts)
+	assert.ErrorContains(t, err, "only DNS responses")
+}
+
+func TestZoneAnswer(t *testing.T) {
+	t.Parallel()
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("www.example.com.", dns.TypeA)
+	msg.Rcode = dns.RcodeNameError
+
+	rr, err := dns.NewRR("example.com. 300 IN SOA ns.example.net. hostmaster.example.com. 1 7200 3600 1209600 300")
+	assert.NilError(t, err)
+
+	msg.Ns = []dns.RR{rr}
+
+	assert.Equal(t, query.ToZone(msg), "; www.example.com.\tIN\t A\n"+
+		"; status: NXDOMAIN\n"+
+		"$ORIGIN example.com.\n"+
+		"$TTL 300\n"+
+		"@\t\tIN\tSOA\tns.example.net. hostmaster 1 7200 3600 1209600 300")
+}
@@ -0,0 +1,8 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+/*
+Package replay sends the queries seen in a pcap or dnstap capture to a server,
+and compares the responses with the ones recorded, eg. to test a new resolver
+against the traffic of an old one.
+*/
+package replay
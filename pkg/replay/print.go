@@ -0,0 +1,40 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package replay
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ToString prints the queries that were not answered as recorded, followed by a summary.
+func ToString(res *Result) string {
+	var s strings.Builder
+
+	for _, q := range res.Queries {
+		// No response may have been recorded
+		if q.Recorded == "" {
+			q.Recorded = "-"
+		}
+
+		switch q.Status {
+		case Different:
+			fmt.Fprintf(&s, "%s\t%s\t%s -> %s\n", q.Name, q.Type, q.Recorded, q.Replayed)
+
+			for _, rr := range q.Missing {
+				s.WriteString("- " + rr + "\n")
+			}
+
+			for _, rr := range q.Extra {
+				s.WriteString("+ " + rr + "\n")
+			}
+		case Failed:
+			fmt.Fprintf(&s, "%s\t%s\t%s -> failed: %s\n", q.Name, q.Type, q.Recorded, q.Error)
+		}
+	}
+
+	fmt.Fprintf(&s, "; %d queries replayed against %s: %d same, %d different, %d failed, %d unrecorded",
+		len(res.Queries), res.Server, res.Same, res.Different, res.Failed, res.Unrecorded)
+
+	return s.String()
+}
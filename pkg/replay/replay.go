@@ -0,0 +1,280 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package replay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"dns.froth.zone/awl/pkg/dnstap"
+	"dns.froth.zone/awl/pkg/pcap"
+	"dns.froth.zone/awl/pkg/resolvers"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+)
+
+// Status of a replayed query.
+const (
+	// Same means the rcode and answers are the same as recorded.
+	Same = "same"
+	// Different means the rcode or answers differ from what was recorded.
+	Different = "different"
+	// Failed means no response came to the replayed query.
+	Failed = "failed"
+	// Unrecorded means no response was recorded, so there is nothing to compare.
+	Unrecorded = "unrecorded"
+)
+
+// Result is the outcome of replaying every query of a capture.
+//
+//nolint:govet // Better looking output is worth a few bytes.
+type Result struct {
+	XMLName xml.Name `json:"-" xml:"replay" yaml:"-"`
+	// Server the queries were replayed against
+	Server string `json:"server" xml:"server" yaml:"server" example:"1.1.1.1"`
+	// Amount of queries in every state
+	Same       int `json:"same" xml:"same" yaml:"same" example:"10"`
+	Different  int `json:"different" xml:"different" yaml:"different" example:"1"`
+	Failed     int `json:"failed" xml:"failed" yaml:"failed" example:"0"`
+	Unrecorded int `json:"unrecorded" xml:"unrecorded" yaml:"unrecorded" example:"0"`
+	// Every query replayed, in the order they were sent
+	Queries []Query `json:"queries" xml:"query" yaml:"queries"`
+}
+
+// Query is a single query replayed.
+//
+//nolint:govet // Better looking output is worth a few bytes.
+type Query struct {
+	Name string `json:"name" xml:"name" yaml:"name" example:"example.com."`
+	Type string `json:"type" xml:"type" yaml:"type" example:"A"`
+	// Same, different, failed or unrecorded
+	Status string `json:"status" xml:"status" yaml:"status" example:"same"`
+	// Rcode of the response recorded and the one to the replayed query
+	Recorded string `json:"recorded,omitempty" xml:"recorded,omitempty" yaml:"recorded,omitempty" example:"NOERROR"`
+	Replayed string `json:"replayed,omitempty" xml:"replayed,omitempty" yaml:"replayed,omitempty" example:"NOERROR"`
+	// Answers recorded but not given to the replayed query, without TTLs
+	Missing []string `json:"missing,omitempty" xml:"missing,omitempty" yaml:"missing,omitempty"`
+	// Answers given to the replayed query but not recorded, without TTLs
+	Extra []string `json:"extra,omitempty" xml:"extra,omitempty" yaml:"extra,omitempty"`
+	// Why the query failed
+	Error string `json:"error,omitempty" xml:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Load reads the exchanges in a capture, which is either pcap or dnstap.
+func Load(path string) ([]util.Exchange, error) {
+	file, err := os.Open(path) //nolint:gosec // Reading files is the point.
+	if err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+
+	// dnstap starts with a control frame, so with zeroes
+	if magic, err := r.Peek(4); err == nil && binary.BigEndian.Uint32(magic) == 0 {
+		exs, err := dnstap.Read(r)
+		if err != nil {
+			return nil, fmt.Errorf("replay: %w", err)
+		}
+
+		return exs, nil
+	}
+
+	exs, err := pcap.Read(r)
+	if err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+
+	return exs, nil
+}
+
+// Replayer replays queries.
+type Replayer struct {
+	opts     *util.Options
+	resolver resolvers.Resolver
+}
+
+// New creates a new Replayer, sending queries to the server and with the transport in the options.
+func New(opts *util.Options) (*Replayer, error) {
+	resolver, err := resolvers.LoadResolver(opts)
+	if err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+
+	return NewWith(opts, resolver), nil
+}
+
+// NewWith creates a new Replayer sending queries with the resolver given.
+func NewWith(opts *util.Options, resolver resolvers.Resolver) *Replayer {
+	return &Replayer{
+		opts:     opts,
+		resolver: resolver,
+	}
+}
+
+// Replay replays the queries of the capture in opts.
+func Replay(opts *util.Options) (*Result, error) {
+	exs, err := Load(opts.Replay)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := New(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Replay(exs), nil
+}
+
+// Replay sends the query of every exchange, comparing the responses with the recorded ones.
+//
+// Queries are sent one at a time, as fast as they are answered, at the rate
+// in the options or at the times they were recorded at.
+func (r *Replayer) Replay(exs []util.Exchange) *Result {
+	res := &Result{Server: r.opts.Request.Server}
+	start := time.Now()
+
+	var first time.Time
+
+	sent := 0
+
+	for _, ex := range exs {
+		if ex.Query == nil {
+			continue
+		}
+
+		if first.IsZero() {
+			first = ex.QueryTime
+		}
+
+		var due time.Duration
+
+		switch {
+		case r.opts.ReplayTiming:
+			due = ex.QueryTime.Sub(first)
+		case r.opts.ReplayRate > 0:
+			due = time.Duration(float64(sent) * float64(time.Second) / r.opts.ReplayRate)
+		}
+
+		if wait := due - time.Since(start); wait > 0 {
+			time.Sleep(wait)
+		}
+
+		sent++
+
+		q := r.replay(ex)
+
+		switch q.Status {
+		case Same:
+			res.Same++
+		case Different:
+			res.Different++
+		case Failed:
+			res.Failed++
+		case Unrecorded:
+			res.Unrecorded++
+		}
+
+		res.Queries = append(res.Queries, q)
+	}
+
+	return res
+}
+
+// replay sends a single query, retrying as the options say.
+func (r *Replayer) replay(ex util.Exchange) Query {
+	var q Query
+
+	if len(ex.Query.Question) > 0 {
+		q.Name = ex.Query.Question[0].Name
+		q.Type = dns.TypeToString[ex.Query.Question[0].Qtype]
+	}
+
+	if ex.Response != nil {
+		q.Recorded = dns.RcodeToString[ex.Response.Rcode]
+	}
+
+	var (
+		resp util.Response
+		err  error
+	)
+
+	for i := 0; i <= r.opts.Request.Retries; i++ {
+		msg := ex.Query.Copy()
+
+		// RFC 9250 requires the ID to be 0
+		if r.opts.QUIC {
+			msg.Id = 0
+		}
+
+		resp, err = r.resolver.LookUp(msg)
+		if err == nil {
+			break
+		}
+
+		r.opts.Logger.Info("Replaying", q.Name, q.Type, "failed:", err)
+	}
+
+	if err != nil {
+		q.Status = Failed
+		q.Error = err.Error()
+
+		return q
+	}
+
+	q.Replayed = dns.RcodeToString[resp.DNS.Rcode]
+
+	if ex.Response == nil {
+		q.Status = Unrecorded
+
+		return q
+	}
+
+	q.Missing, q.Extra = compare(ex.Response.Answer, resp.DNS.Answer)
+
+	q.Status = Same
+	if q.Recorded != q.Replayed || len(q.Missing) > 0 || len(q.Extra) > 0 {
+		q.Status = Different
+	}
+
+	return q
+}
+
+// compare finds the records only in one set of answers or the other, ignoring
+// TTLs and the order of the records.
+func compare(recorded, replayed []dns.RR) (missing, extra []string) {
+	count := make(map[string]int)
+
+	for _, rr := range recorded {
+		count[util.WithoutTTL(rr)]++
+	}
+
+	for _, rr := range replayed {
+		str := util.WithoutTTL(rr)
+		if count[str] > 0 {
+			count[str]--
+		} else {
+			extra = append(extra, str)
+		}
+	}
+
+	for _, rr := range recorded {
+		str := util.WithoutTTL(rr)
+		if count[str] > 0 {
+			count[str]--
+
+			missing = append(missing, str)
+		}
+	}
+
+	sort.Strings(missing)
+	sort.Strings(extra)
+
+	return missing, extra
+}
@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package replay_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dns.froth.zone/awl/pkg/dnstap"
+	"dns.froth.zone/awl/pkg/pcap"
+	"dns.froth.zone/awl/pkg/replay"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+func reply(t *testing.T, query *dns.Msg, rcode int, answers ...string) *dns.Msg {
+	t.Helper()
+
+	msg := new(dns.Msg)
+	msg.SetRcode(query, rcode)
+
+	for _, str := range answers {
+		rr, err := dns.NewRR(str)
+		assert.NilError(t, err)
+
+		msg.Answer = append(msg.Answer, rr)
+	}
+
+	return msg
+}
+
+func question(name string, qtype uint16) *dns.Msg {
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, qtype)
+
+	return msg
+}
+
+// recording makes exchanges as recorded, one every 100ms.
+func recording(t *testing.T) []util.Exchange {
+	t.Helper()
+
+	same := question("same.example.", dns.TypeA)
+	rcode := question("rcode.example.", dns.TypeA)
+	answers := question("answers.example.", dns.TypeA)
+	failed := question("failed.example.", dns.TypeA)
+	unrecorded := question("unrecorded.example.", dns.TypeA)
+
+	start := time.Unix(1700000000, 0)
+
+	exs := []util.Exchange{
+		{Query: same, Response: reply(t, same, dns.RcodeSuccess, "same.example. 300 IN A 192.0.2.1", "same.example. 300 IN A 192.0.2.2")},
+		{Query: rcode, Response: reply(t, rcode, dns.RcodeSuccess)},
+		{Query: answers, Response: reply(t, answers, dns.RcodeSuccess, "answers.example. 300 IN A 192.0.2.1")},
+		{Query: failed, Response: reply(t, failed, dns.RcodeSuccess)},
+		{Query: unrecorded},
+	}
+
+	for i := range exs {
+		exs[i].QueryTime = start.Add(time.Duration(i) * 100 * time.Millisecond)
+		exs[i].Protocol = "UDP"
+	}
+
+	return exs
+}
+
+// server answers replayed queries differently to the recording.
+type server struct {
+	t *testing.T
+}
+
+func (s server) LookUp(msg *dns.Msg) (util.Response, error) {
+	var resp *dns.Msg
+
+	switch msg.Question[0].Name {
+	case "same.example.":
+		// Other TTLs and order
+		resp = reply(s.t, msg, dns.RcodeSuccess, "same.example. 60 IN A 192.0.2.2", "same.example. 60 IN A 192.0.2.1")
+	case "rcode.example.":
+		resp = reply(s.t, msg, dns.RcodeNameError)
+	case "answers.example.":
+		resp = reply(s.t, msg, dns.RcodeSuccess, "answers.example. 300 IN A 192.0.2.3")
+	case "failed.example.":
+		return util.Response{}, errors.New("timeout")
+	default:
+		resp = reply(s.t, msg, dns.RcodeSuccess)
+	}
+
+	return util.Response{DNS: resp}, nil
+}
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+
+	opts := &util.Options{
+		Logger:  util.InitLogger(0),
+		Request: util.Request{Server: "192.0.2.53", Retries: 1},
+	}
+
+	res := replay.NewWith(opts, server{t}).Replay(recording(t))
+
+	assert.Equal(t, len(res.Queries), 5)
+	assert.Equal(t, res.Same, 1)
+	assert.Equal(t, res.Different, 2)
+	assert.Equal(t, res.Failed, 1)
+	assert.Equal(t, res.Unrecorded, 1)
+
+	q := res.Queries[1]
+	assert.Equal(t, q.Status, replay.Different)
+	assert.Equal(t, q.Recorded, "NOERROR")
+	assert.Equal(t, q.Replayed, "NXDOMAIN")
+
+	q = res.Queries[2]
+	assert.Equal(t, q.Status, replay.Different)
+	assert.DeepEqual(t, q.Missing, []string{"answers.example.\tIN\tA\t192.0.2.1"})
+	assert.DeepEqual(t, q.Extra, []string{"answers.example.\tIN\tA\t192.0.2.3"})
+
+	assert.Equal(t, res.Queries[3].Error, "timeout")
+
+	str := replay.ToString(res)
+	assert.Assert(t, strings.Contains(str, "rcode.example.\tA\tNOERROR -> NXDOMAIN\n"))
+	assert.Assert(t, strings.Contains(str, "+ answers.example.\tIN\tA\t192.0.2.3\n"))
+	assert.Assert(t, strings.Contains(str, "failed.example.\tA\tNOERROR -> failed: timeout\n"))
+	assert.Assert(t, !strings.Contains(str, "same.example."))
+	assert.Assert(t, strings.HasSuffix(str, "1 same, 2 different, 1 failed, 1 unrecorded"))
+}
+
+func TestTiming(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		name string
+		opts util.Options
+		// When the last query is due
+		last time.Duration
+	}{
+		{"rate", util.Options{ReplayRate: 20}, 200 * time.Millisecond},
+		{"recorded", util.Options{ReplayTiming: true}, 400 * time.Millisecond},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := test.opts
+			opts.Logger = util.InitLogger(0)
+
+			start := time.Now()
+
+			res := replay.NewWith(&opts, server{t}).Replay(recording(t))
+
+			assert.Equal(t, len(res.Queries), 5)
+			assert.Assert(t, time.Since(start) >= test.last)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	exs := recording(t)
+	dir := t.TempDir()
+
+	capture, err := pcap.Create(filepath.Join(dir, "in.pcap"))
+	assert.NilError(t, err)
+
+	tap, err := dnstap.Create(filepath.Join(dir, "in.dnstap"))
+	assert.NilError(t, err)
+
+	for _, ex := range exs {
+		ex.ResponseTime = ex.QueryTime
+
+		assert.NilError(t, capture.Record(ex))
+		assert.NilError(t, tap.Record(ex))
+	}
+
+	assert.NilError(t, capture.Close())
+	assert.NilError(t, tap.Close())
+
+	for _, file := range []string{"in.pcap", "in.dnstap"} {
+		loaded, err := replay.Load(filepath.Join(dir, file))
+		assert.NilError(t, err, file)
+		assert.Equal(t, len(loaded), len(exs), file)
+
+		for i := range exs {
+			assert.Equal(t, loaded[i].Query.Question[0].Name, exs[i].Query.Question[0].Name, file)
+			assert.Equal(t, loaded[i].Response == nil, exs[i].Response == nil, file)
+		}
+	}
+
+	_, err = replay.Load(filepath.Join(dir, "missing"))
+	assert.ErrorContains(t, err, "replay")
+}
@@ -4,6 +4,8 @@ package resolvers
 
 import (
 	"fmt"
+	"net"
+	"net/netip"
 	"time"
 
 	"dns.froth.zone/awl/pkg/util"
@@ -49,6 +51,24 @@ func (resolver *DNSCryptResolver) LookUp(msg *dns.Msg) (resp util.Response, err
 	res, err := client.Exchange(msg, resolverInf)
 	rtt := time.Since(now)
 
+	ex := util.Exchange{
+		Query:        msg,
+		Response:     res,
+		QueryTime:    now,
+		ResponseTime: now.Add(rtt),
+		Protocol:     "DNSCrypt",
+	}
+
+	// The connection is made inside the library, so only the server is known
+	if addr, err := netip.ParseAddrPort(resolverInf.ServerAddress); err == nil {
+		ex.Remote = net.UDPAddrFromAddrPort(addr)
+		if netProtocol(client.Net) == "TCP" {
+			ex.Remote = net.TCPAddrFromAddrPort(addr)
+		}
+	}
+
+	util.Record(resolver.opts, ex)
+
 	if err != nil {
 		return resp, fmt.Errorf("dnscrypt: exchange: %w", err)
 	}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"time"
 
 	"dns.froth.zone/awl/pkg/util"
@@ -35,6 +36,7 @@ func (resolver *HTTPSResolver) LookUp(msg *dns.Msg) (resp util.Response, err err
 				//nolint:gosec // This is intentional if the user requests it
 				InsecureSkipVerify: resolver.opts.TLSNoVerify,
 				ServerName:         resolver.opts.TLSHost,
+				KeyLogWriter:       resolver.opts.KeyLogWriter,
 			},
 		},
 	}
@@ -61,7 +63,17 @@ func (resolver *HTTPSResolver) LookUp(msg *dns.Msg) (resp util.Response, err err
 	req.Header.Set("Content-Type", "application/dns-message")
 	req.Header.Set("Accept", "application/dns-message")
 
+	ex := util.Exchange{Query: msg, Protocol: "HTTPS"}
+	defer func() { util.Record(resolver.opts, ex) }()
+
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			ex.Local, ex.Remote = info.Conn.LocalAddr(), info.Conn.RemoteAddr()
+		},
+	}))
+
 	now := time.Now()
+	ex.QueryTime = now
 	res, err := resolver.client.Do(req)
 	resp.RTT = time.Since(now)
 
@@ -93,6 +105,7 @@ func (resolver *HTTPSResolver) LookUp(msg *dns.Msg) (resp util.Response, err err
 
 	resolver.opts.Logger.Debug("https: unpacking response")
 
+	resp.Wire = &util.Wire{Query: buf, Response: fullRes}
 	resp.DNS = &dns.Msg{}
 
 	err = resp.DNS.Unpack(fullRes)
@@ -100,5 +113,7 @@ func (resolver *HTTPSResolver) LookUp(msg *dns.Msg) (resp util.Response, err err
 		return resp, fmt.Errorf("doh: dns message unpack: %w", err)
 	}
 
+	ex.Response, ex.ResponseTime = resp.DNS, now.Add(resp.RTT)
+
 	return resp, nil
 }
@@ -31,6 +31,7 @@ func (resolver *QUICResolver) LookUp(msg *dns.Msg) (resp util.Response, err erro
 		ServerName:         resolver.opts.TLSHost,
 		MinVersion:         tls.VersionTLS12,
 		NextProtos:         []string{"doq"},
+		KeyLogWriter:       resolver.opts.KeyLogWriter,
 	}
 
 	// Make sure that TLSHost is ALWAYS set
@@ -53,7 +54,6 @@ func (resolver *QUICResolver) LookUp(msg *dns.Msg) (resp util.Response, err erro
 
 	resolver.opts.Logger.Debug("quic: packing query")
 
-	msg.Id = 0
 	// Compress request to over-the-wire
 	buf, err := msg.Pack()
 	if err != nil {
@@ -62,6 +62,15 @@ func (resolver *QUICResolver) LookUp(msg *dns.Msg) (resp util.Response, err erro
 
 	t := time.Now()
 
+	ex := util.Exchange{
+		Query:     msg,
+		QueryTime: t,
+		Protocol:  "QUIC",
+		Local:     connection.LocalAddr(),
+		Remote:    connection.RemoteAddr(),
+	}
+	defer func() { util.Record(resolver.opts, ex) }()
+
 	resolver.opts.Logger.Debug("quic: creating stream")
 
 	stream, err := connection.OpenStream()
@@ -104,11 +113,15 @@ func (resolver *QUICResolver) LookUp(msg *dns.Msg) (resp util.Response, err erro
 	resolver.opts.Logger.Debug("quic: unpacking response")
 
 	// Unpack response and lop off the first two bytes (RFC 9250 moment)
-	err = resp.DNS.Unpack(fullRes[2:])
+	resp.Wire = &util.Wire{Query: buf, Response: fullRes[2:]}
+
+	err = resp.DNS.Unpack(resp.Wire.Response)
 	if err != nil {
 		return resp, fmt.Errorf("doq: unpack: %w", err)
 	}
 
+	ex.Response, ex.ResponseTime = resp.DNS, t.Add(resp.RTT)
+
 	return
 }
 
@@ -6,6 +6,8 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net"
+	"strings"
+	"time"
 
 	"dns.froth.zone/awl/pkg/util"
 	"github.com/miekg/dns"
@@ -20,6 +22,10 @@ var _ Resolver = (*StandardResolver)(nil)
 
 // LookUp performs a DNS query.
 func (resolver *StandardResolver) LookUp(msg *dns.Msg) (resp util.Response, err error) {
+	if isTransfer(msg) {
+		return resolver.transfer(msg)
+	}
+
 	dnsClient := new(dns.Client)
 	dnsClient.Dialer = &net.Dialer{
 		Timeout: resolver.opts.Request.Timeout,
@@ -44,12 +50,13 @@ func (resolver *StandardResolver) LookUp(msg *dns.Msg) (resp util.Response, err
 			//nolint:gosec // This is intentional if the user requests it
 			InsecureSkipVerify: resolver.opts.TLSNoVerify,
 			ServerName:         resolver.opts.TLSHost,
+			KeyLogWriter:       resolver.opts.KeyLogWriter,
 		}
 	}
 
 	resolver.opts.Logger.Info("Using", dnsClient.Net, "for making the request")
 
-	resp.DNS, resp.RTT, err = dnsClient.Exchange(msg, resolver.opts.Request.Server)
+	err = resolver.exchange(dnsClient, msg, &resp)
 	if err != nil {
 		return resp, fmt.Errorf("standard: DNS exchange: %w", err)
 	}
@@ -60,8 +67,9 @@ func (resolver *StandardResolver) LookUp(msg *dns.Msg) (resp util.Response, err
 			fmt.Printf(";; BADCOOKIE, retrying.\n\n")
 
 			msg.Extra = resp.DNS.Extra
+			resp.BadCookie = true
 
-			resp.DNS, resp.RTT, err = dnsClient.Exchange(msg, resolver.opts.Request.Server)
+			err = resolver.exchange(dnsClient, msg, &resp)
 			if err != nil {
 				return resp, fmt.Errorf("badcookie: DNS exchange: %w", err)
 			}
@@ -77,6 +85,7 @@ func (resolver *StandardResolver) LookUp(msg *dns.Msg) (resp util.Response, err
 		fmt.Printf(";; Truncated, retrying with TCP\n\n")
 
 		dnsClient.Net = tcp
+		resp.TCPFallback = true
 
 		switch {
 		case resolver.opts.IPv4:
@@ -85,7 +94,7 @@ func (resolver *StandardResolver) LookUp(msg *dns.Msg) (resp util.Response, err
 			dnsClient.Net += "6"
 		}
 
-		resp.DNS, resp.RTT, err = dnsClient.Exchange(msg, resolver.opts.Request.Server)
+		err = resolver.exchange(dnsClient, msg, &resp)
 	}
 
 	if err != nil {
@@ -94,3 +103,80 @@ func (resolver *StandardResolver) LookUp(msg *dns.Msg) (resp util.Response, err
 
 	return
 }
+
+// exchange sends a query over a new connection, recording it and the response.
+//
+// This is [dns.Client.ExchangeWithConn], keeping the messages as they were
+// sent and received.
+func (resolver *StandardResolver) exchange(client *dns.Client, msg *dns.Msg, resp *util.Response) error {
+	resp.DNS, resp.Wire = nil, nil
+
+	conn, err := client.Dial(resolver.opts.Request.Server)
+	if err != nil {
+		return err //nolint:wrapcheck // Wrapped by the caller
+	}
+	defer conn.Close()
+
+	if opt := msg.IsEdns0(); opt != nil && opt.UDPSize() >= dns.MinMsgSize {
+		conn.UDPSize = opt.UDPSize()
+	}
+
+	buf, err := msg.Pack()
+	if err != nil {
+		return err //nolint:wrapcheck // Wrapped by the caller
+	}
+
+	resp.Wire = &util.Wire{Query: buf}
+
+	start := time.Now()
+
+	err = conn.SetDeadline(start.Add(resolver.opts.Request.Timeout))
+	if err == nil {
+		_, err = conn.Write(buf)
+	}
+
+	for err == nil {
+		resp.Wire.Response, err = conn.ReadMsgHeader(nil)
+		if err != nil {
+			break
+		}
+
+		resp.DNS = new(dns.Msg)
+		err = resp.DNS.Unpack(resp.Wire.Response)
+
+		// Responses to earlier queries that timed out can still come over UDP
+		if err != nil || resp.DNS.Id == msg.Id || strings.HasPrefix(client.Net, tcp) {
+			break
+		}
+	}
+
+	resp.RTT = time.Since(start)
+
+	if err == nil && resp.DNS.Id != msg.Id {
+		err = dns.ErrId
+	}
+
+	util.Record(resolver.opts, util.Exchange{
+		Query:        msg,
+		Response:     resp.DNS,
+		QueryTime:    start,
+		ResponseTime: start.Add(resp.RTT),
+		Protocol:     netProtocol(client.Net),
+		Local:        conn.LocalAddr(),
+		Remote:       conn.RemoteAddr(),
+	})
+
+	return err //nolint:wrapcheck // Wrapped by the caller
+}
+
+// netProtocol names the transport of a network given to a [dns.Client], eg. tcp4-tls.
+func netProtocol(network string) string {
+	switch {
+	case strings.HasSuffix(network, "-tls"):
+		return "TLS"
+	case strings.HasPrefix(network, tcp):
+		return "TCP"
+	default:
+		return "UDP"
+	}
+}
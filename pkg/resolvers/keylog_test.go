@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package resolvers_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"dns.froth.zone/awl/pkg/query"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+// selfSigned makes a certificate for 127.0.0.1.
+func selfSigned(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NilError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestKeyLog(t *testing.T) {
+	t.Parallel()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{selfSigned(t)},
+		MinVersion:   tls.VersionTLS12,
+	})
+	assert.NilError(t, err)
+
+	server := &dns.Server{
+		Listener: listener,
+		Net:      "tcp-tls",
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			res := new(dns.Msg)
+			res.SetReply(req)
+
+			//nolint:errcheck,gosec // Only for tests
+			w.WriteMsg(res)
+		}),
+	}
+
+	go server.ActivateAndServe() //nolint:errcheck // Only for tests
+
+	t.Cleanup(func() {
+		server.Shutdown() //nolint:errcheck,gosec // Only for tests
+	})
+
+	keys := new(bytes.Buffer)
+
+	opts := &util.Options{
+		Logger:       util.InitLogger(0),
+		TLS:          true,
+		TLSNoVerify:  true,
+		KeyLogWriter: keys,
+		Request: util.Request{
+			Server:  "127.0.0.1",
+			Port:    listener.Addr().(*net.TCPAddr).Port,
+			Type:    dns.TypeA,
+			Class:   dns.ClassINET,
+			Name:    "example.com.",
+			Timeout: time.Second,
+		},
+	}
+
+	_, err = query.CreateQuery(opts)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(keys.String(), "CLIENT_TRAFFIC_SECRET_0 "), keys.String())
+}
@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package resolvers_test
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"dns.froth.zone/awl/pkg/query"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+// recorder keeps every exchange it is given.
+type recorder struct {
+	mu  sync.Mutex
+	exs []util.Exchange
+}
+
+func (r *recorder) Record(ex util.Exchange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.exs = append(r.exs, ex)
+
+	return nil
+}
+
+func TestRecord(t *testing.T) {
+	t.Parallel()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NilError(t, err)
+
+	server := &dns.Server{
+		PacketConn: conn,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			res := new(dns.Msg)
+			res.SetReply(req)
+
+			//nolint:errcheck,gosec // Only for tests
+			w.WriteMsg(res)
+		}),
+	}
+
+	go server.ActivateAndServe() //nolint:errcheck // Only for tests
+
+	t.Cleanup(func() {
+		server.Shutdown() //nolint:errcheck,gosec // Only for tests
+	})
+
+	rec := new(recorder)
+
+	opts := &util.Options{
+		Logger:    util.InitLogger(0),
+		Recorders: []util.Recorder{rec},
+		Request: util.Request{
+			Server:  "127.0.0.1",
+			Port:    conn.LocalAddr().(*net.UDPAddr).Port,
+			Type:    dns.TypeA,
+			Class:   dns.ClassINET,
+			Name:    "example.com.",
+			Timeout: time.Second,
+		},
+	}
+
+	res, err := query.CreateQuery(opts)
+	assert.NilError(t, err)
+
+	assert.Equal(t, len(rec.exs), 1)
+
+	ex := rec.exs[0]
+	assert.Equal(t, ex.Protocol, "UDP")
+	assert.Equal(t, ex.Query.Id, res.DNS.Id)
+	assert.Equal(t, ex.Response, res.DNS)
+	assert.Equal(t, ex.Remote.String(), conn.LocalAddr().String())
+	assert.Assert(t, ex.Local != nil)
+	assert.Assert(t, !ex.ResponseTime.Before(ex.QueryTime))
+}
@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package resolvers
+
+import (
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+)
+
+// isTransfer checks if a query is a zone transfer, which can take more than one message.
+func isTransfer(msg *dns.Msg) bool {
+	if len(msg.Question) == 0 {
+		return false
+	}
+
+	qtype := msg.Question[0].Qtype
+
+	return qtype == dns.TypeAXFR || qtype == dns.TypeIXFR
+}
+
+// transfer makes a zone transfer, putting every record received into a single response.
+func (resolver *StandardResolver) transfer(msg *dns.Msg) (resp util.Response, err error) {
+	transfer := &dns.Transfer{
+		DialTimeout:  resolver.opts.Request.Timeout,
+		ReadTimeout:  resolver.opts.Request.Timeout,
+		WriteTimeout: resolver.opts.Request.Timeout,
+	}
+
+	if resolver.opts.TLS {
+		transfer.TLS = &tls.Config{
+			//nolint:gosec // This is intentional if the user requests it
+			InsecureSkipVerify: resolver.opts.TLSNoVerify,
+			ServerName:         resolver.opts.TLSHost,
+			KeyLogWriter:       resolver.opts.KeyLogWriter,
+		}
+	}
+
+	resolver.opts.Logger.Info("Making a zone transfer")
+
+	start := time.Now()
+
+	envelopes, err := transfer.In(msg, resolver.opts.Request.Server)
+	if err != nil {
+		return resp, fmt.Errorf("transfer: %w", err)
+	}
+
+	resp.DNS = new(dns.Msg)
+	resp.DNS.SetReply(msg)
+
+	ex := util.Exchange{
+		Query:     msg,
+		QueryTime: start,
+		Protocol:  "TCP",
+		Local:     transfer.LocalAddr(),
+		Remote:    transfer.RemoteAddr(),
+	}
+
+	if resolver.opts.TLS {
+		ex.Protocol = "TLS"
+	}
+
+	for env := range envelopes {
+		if env.Error != nil {
+			return resp, fmt.Errorf("transfer: %w", env.Error)
+		}
+
+		resp.DNS.Answer = append(resp.DNS.Answer, env.RR...)
+
+		// Only the records of each message are given, so it is made again
+		ex.Response = new(dns.Msg)
+		ex.Response.SetReply(msg)
+		ex.Response.Answer = env.RR
+		ex.ResponseTime = time.Now()
+
+		util.Record(resolver.opts, ex)
+
+		// Later messages are only responses
+		ex.Query = nil
+	}
+
+	resp.RTT = time.Since(start)
+
+	resolver.opts.Logger.Info("Transfer successful,", len(resp.DNS.Answer), "records received")
+
+	return resp, nil
+}
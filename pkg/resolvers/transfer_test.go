@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package resolvers_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"dns.froth.zone/awl/pkg/query"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+func TestTransfer(t *testing.T) {
+	t.Parallel()
+
+	var zone []dns.RR
+
+	for _, str := range []string{
+		"example.com. 3600 IN SOA ns.example.com. hostmaster.example.com. 1 7200 3600 1209600 300",
+		"example.com. 3600 IN NS ns.example.com.",
+		"ns.example.com. 3600 IN A 192.0.2.1",
+		"example.com. 3600 IN SOA ns.example.com. hostmaster.example.com. 1 7200 3600 1209600 300",
+	} {
+		rr, err := dns.NewRR(str)
+		assert.NilError(t, err)
+
+		zone = append(zone, rr)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+
+	server := &dns.Server{
+		Listener: listener,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			ch := make(chan *dns.Envelope)
+			tr := new(dns.Transfer)
+
+			go func() {
+				// Send the zone over more than one message
+				ch <- &dns.Envelope{RR: zone[:2]}
+				ch <- &dns.Envelope{RR: zone[2:]}
+				close(ch)
+			}()
+
+			//nolint:errcheck,gosec // Only for tests
+			tr.Out(w, req, ch)
+			w.Hijack()
+		}),
+	}
+
+	go server.ActivateAndServe() //nolint:errcheck // Only for tests
+
+	t.Cleanup(func() {
+		server.Shutdown() //nolint:errcheck,gosec // Only for tests
+	})
+
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	rec := new(recorder)
+
+	opts := &util.Options{
+		Logger:    util.InitLogger(0),
+		Recorders: []util.Recorder{rec},
+		TCP:       true,
+		Request: util.Request{
+			Server:  "127.0.0.1",
+			Port:    port,
+			Type:    dns.TypeAXFR,
+			Class:   dns.ClassINET,
+			Name:    "example.com.",
+			Timeout: time.Second,
+		},
+	}
+
+	res, err := query.CreateQuery(opts)
+	assert.NilError(t, err)
+	assert.Equal(t, len(res.DNS.Answer), len(zone))
+	assert.Equal(t, res.DNS.Answer[2].String(), zone[2].String())
+
+	// Every message is recorded, the query only with the first
+	assert.Equal(t, len(rec.exs), 2)
+	assert.Equal(t, rec.exs[0].Protocol, "TCP")
+	assert.Assert(t, rec.exs[0].Query != nil)
+	assert.Assert(t, rec.exs[1].Query == nil)
+	assert.Equal(t, len(rec.exs[1].Response.Answer), 2)
+}
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package resolvers_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"dns.froth.zone/awl/pkg/query"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+func TestWire(t *testing.T) {
+	t.Parallel()
+
+	for _, tcp := range []bool{false, true} {
+		sent := make(chan []byte, 1)
+
+		server := &dns.Server{
+			Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+				res := new(dns.Msg)
+				res.SetReply(req)
+
+				rr, _ := dns.NewRR("example.com. 300 IN A 192.0.2.1")
+				res.Answer = []dns.RR{rr}
+
+				// Compressed, unlike when packed again
+				res.Compress = true
+
+				buf, _ := res.Pack()
+				sent <- buf
+
+				//nolint:errcheck,gosec // Only for tests
+				w.Write(buf)
+			}),
+		}
+
+		var port int
+
+		if tcp {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NilError(t, err)
+
+			server.Listener = listener
+			port = listener.Addr().(*net.TCPAddr).Port
+		} else {
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			assert.NilError(t, err)
+
+			server.PacketConn = conn
+			port = conn.LocalAddr().(*net.UDPAddr).Port
+		}
+
+		go server.ActivateAndServe() //nolint:errcheck // Only for tests
+
+		t.Cleanup(func() {
+			server.Shutdown() //nolint:errcheck,gosec // Only for tests
+		})
+
+		opts := &util.Options{
+			Logger: util.InitLogger(0),
+			TCP:    tcp,
+			Request: util.Request{
+				Server:  "127.0.0.1",
+				Port:    port,
+				Type:    dns.TypeA,
+				Class:   dns.ClassINET,
+				Name:    "example.com.",
+				Timeout: time.Second,
+			},
+		}
+
+		res, err := query.CreateQuery(opts)
+		assert.NilError(t, err)
+		assert.Assert(t, res.Wire != nil)
+
+		buf, err := res.Query.Pack()
+		assert.NilError(t, err)
+		assert.DeepEqual(t, res.Wire.Query, buf)
+		assert.DeepEqual(t, res.Wire.Response, <-sent)
+
+		repacked, err := res.DNS.Pack()
+		assert.NilError(t, err)
+		assert.Assert(t, len(repacked) != len(res.Wire.Response))
+	}
+}
@@ -0,0 +1,7 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+/*
+Package trace is an iterative resolver, used to trace the path of a query
+from the root down to the authoritative servers.
+*/
+package trace
@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package trace
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"dns.froth.zone/awl/pkg/query"
+	"dns.froth.zone/awl/pkg/util"
+)
+
+// ToString prints every step of a trace, much like dig +trace.
+func ToString(steps []Step, opts *util.Options) (string, error) {
+	var s strings.Builder
+
+	for i, step := range steps {
+		str, err := query.ToString(step.Response, opts)
+		if err != nil {
+			return "", fmt.Errorf("step %d: %w", i, err)
+		}
+
+		s.WriteString(str)
+
+		if !opts.Short {
+			addr := step.Address
+			if step.Port != 0 {
+				addr += "#" + strconv.Itoa(step.Port)
+			}
+
+			fmt.Fprintf(&s, ";; Received %d bytes from %s(%s) in %s\n",
+				step.Response.DNS.Len(), addr, step.Server, step.Response.RTT)
+		}
+
+		if i != len(steps)-1 {
+			s.WriteString("\n")
+		}
+	}
+
+	return s.String(), nil
+}
+
+// Result is a full trace, made printable.
+type Result struct {
+	XMLName xml.Name `json:"-" xml:"trace" yaml:"-"`
+	// Every step taken, in order
+	Hops []Hop `json:"hops" xml:"hop" yaml:"hops"`
+}
+
+// Hop is a single step of a trace, made printable.
+type Hop struct {
+	// Zone the server was queried as being authoritative for
+	Zone string `json:"zone" xml:"zone" yaml:"zone" example:"com."`
+	// Name of the server queried
+	Server string `json:"server" xml:"server" yaml:"server" example:"a.gtld-servers.net."`
+	// Address of the server queried
+	Address string `json:"address" xml:"address" yaml:"address" example:"192.5.6.30"`
+	// Port of the server queried
+	Port int `json:"port,omitempty" xml:"port,omitempty" yaml:"port,omitempty" example:"53"`
+	// Protocol used to make the query
+	Protocol string `json:"protocol" xml:"protocol" yaml:"protocol" example:"UDP"`
+	// The time it took to get a response
+	RTT time.Duration `json:"rtt" xml:"rtt" yaml:"rtt" example:"2000000"`
+	// The response given by the server
+	Message *query.Message `json:"message" xml:"message" yaml:"message"`
+}
+
+// MakePrintable makes every step of a trace printable as JSON, XML or YAML.
+func MakePrintable(steps []Step, opts *util.Options) (*Result, error) {
+	ret := &Result{Hops: make([]Hop, 0, len(steps))}
+
+	for i, step := range steps {
+		msg, err := query.MakePrintable(step.Response, opts)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i, err)
+		}
+
+		ret.Hops = append(ret.Hops, Hop{
+			Zone:     step.Zone,
+			Server:   step.Server,
+			Address:  step.Address,
+			Port:     step.Port,
+			Protocol: step.Protocol,
+			RTT:      step.Response.RTT,
+			Message:  msg,
+		})
+	}
+
+	return ret, nil
+}
+
+// PrintSpecial prints a trace as a single JSON, XML or YAML document.
+func PrintSpecial(steps []Step, opts *util.Options) (string, error) {
+	formatted, err := MakePrintable(steps, opts)
+	if err != nil {
+		return "", err
+	}
+
+	//nolint:wrapcheck // Error wrapping not needed here
+	return query.Marshal(formatted, opts)
+}
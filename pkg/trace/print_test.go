@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package trace_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"dns.froth.zone/awl/pkg/trace"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestPrintSpecial(t *testing.T) {
+	t.Parallel()
+
+	tracer := newTracer("www.example.net.", dns.TypeA)
+
+	steps, err := tracer.Trace()
+	assert.NilError(t, err)
+
+	opts := &util.Options{
+		Logger: util.InitLogger(0),
+		Format: "json",
+		Display: util.Display{
+			Question: true,
+			Answer:   true,
+			TTL:      true,
+		},
+	}
+
+	str, err := trace.PrintSpecial(steps, opts)
+	assert.NilError(t, err)
+
+	var res trace.Result
+
+	// Everything has to be a single document
+	assert.NilError(t, json.Unmarshal([]byte(str), &res))
+	assert.Assert(t, cmp.Len(res.Hops, len(steps)))
+	assert.Equal(t, res.Hops[len(res.Hops)-1].Address, "192.0.2.3")
+	assert.Equal(t, res.Hops[len(res.Hops)-1].Message.AnswerRRs[0].Value, "192.0.2.20")
+
+	opts.Format = "xml"
+
+	str, err = trace.PrintSpecial(steps, opts)
+	assert.NilError(t, err)
+	assert.NilError(t, xml.Unmarshal([]byte(str), &res))
+}
+
+func TestToString(t *testing.T) {
+	t.Parallel()
+
+	steps, err := newTracer("www.example.net.", dns.TypeA).Trace()
+	assert.NilError(t, err)
+
+	opts := &util.Options{
+		Logger: util.InitLogger(0),
+		Display: util.Display{
+			Answer:    true,
+			Authority: true,
+			TTL:       true,
+			ShowClass: true,
+		},
+	}
+
+	str, err := trace.ToString(steps, opts)
+	assert.NilError(t, err)
+	assert.Assert(t, cmp.Contains(str, "from 192.0.2.3#53(ns.example.net.)"))
+}
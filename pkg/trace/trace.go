@@ -0,0 +1,619 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package trace
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+
+	"dns.froth.zone/awl/pkg/query"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+)
+
+const (
+	// Maximum amount of queries made for a single name, including aliases
+	maxHops = 32
+	// Maximum amount of CNAME/DNAME redirections to follow
+	maxAliases = 8
+	// Maximum nesting when resolving glueless name servers
+	maxDepth = 4
+)
+
+// Server is a name server and the addresses it can be reached at.
+type Server struct {
+	// Name of the server, eg. a.root-servers.net.
+	Name string `json:"name" example:"a.root-servers.net."`
+	// Known addresses of the server, empty when no glue was given
+	Addrs []string `json:"addresses" example:"198.41.0.4"`
+}
+
+// Step is a single query made while tracing.
+type Step struct {
+	// Zone the server was queried as being authoritative for
+	Zone string `json:"zone" example:"com."`
+	// Name of the server queried
+	Server string `json:"server" example:"a.gtld-servers.net."`
+	// Address of the server queried
+	Address string `json:"address" example:"192.5.6.30"`
+	// Port of the server queried
+	Port int `json:"port" example:"53"`
+	// Protocol used to make the query
+	Protocol string `json:"protocol" example:"UDP"`
+	// Name that was queried, which differs from the request when following aliases
+	Name string `json:"name" example:"example.com."`
+	// Type that was queried
+	Type uint16 `json:"type" example:"1"`
+	// The response given by the server
+	Response util.Response `json:"-"`
+}
+
+// Tracer is an iterative resolver.
+type Tracer struct {
+	opts *util.Options
+	// Makes a single query
+	exchange func(*util.Options) (util.Response, error)
+	// Addresses of name servers that have already been resolved
+	addrs map[string][]string
+	// Current nesting of glueless name server resolution
+	depth int
+}
+
+// New creates a new Tracer from the options given.
+func New(opts *util.Options) *Tracer {
+	return NewWith(opts, query.CreateQuery)
+}
+
+// NewWith creates a new Tracer making every query with exchange instead of
+// [query.CreateQuery], eg. to answer them from a fake network in tests.
+func NewWith(opts *util.Options, exchange func(*util.Options) (util.Response, error)) *Tracer {
+	return &Tracer{
+		opts:     opts,
+		exchange: exchange,
+		addrs:    make(map[string][]string),
+	}
+}
+
+// Trace traces the query in opts from the root.
+func Trace(opts *util.Options) ([]Step, error) {
+	return New(opts).Trace()
+}
+
+// Trace traces the requested name from the root.
+//
+// The root servers are first found using the server and transport requested,
+// every query after that is made directly to the authoritative servers.
+func (t *Tracer) Trace() ([]Step, error) {
+	o := *t.opts
+	o.Request.Name = "."
+	o.Request.Type = dns.TypeNS
+
+	resp, err := t.exchange(&o)
+	if err != nil {
+		return nil, fmt.Errorf("root query: %w", err)
+	}
+
+	// Servers given as URLs already include the port
+	port := t.opts.Request.Port
+	if t.opts.HTTPS || t.opts.DNSCrypt {
+		port = 0
+	}
+
+	steps := []Step{{
+		Zone:     ".",
+		Server:   t.opts.Request.Server,
+		Address:  t.opts.Request.Server,
+		Port:     port,
+		Protocol: util.Protocol(t.opts),
+		Name:     ".",
+		Type:     dns.TypeNS,
+		Response: resp,
+	}}
+
+	roots := servers(resp.DNS, resp.DNS.Answer, ".")
+	for i := range roots {
+		if len(roots[i].Addrs) == 0 {
+			roots[i].Addrs = rootHints[strings.ToLower(roots[i].Name)]
+		}
+	}
+
+	if len(roots) == 0 {
+		t.opts.Logger.Warn("No root servers given by", t.opts.Request.Server, "using built-in hints")
+
+		roots = t.roots()
+	}
+
+	more, err := t.iterate(t.opts.Request.Name, t.opts.Request.Type, ".", roots)
+
+	return append(steps, more...), err
+}
+
+// Resolve iteratively resolves a name, starting from the built-in root hints.
+func (t *Tracer) Resolve(name string, qtype uint16) ([]Step, error) {
+	return t.iterate(dns.Fqdn(name), qtype, ".", t.roots())
+}
+
+// Authoritative finds the zone name is in, along with every name server of the zone
+// and their addresses.
+func (t *Tracer) Authoritative(name string) (zone string, srvs []Server, err error) {
+	name = dns.Fqdn(name)
+
+	steps, err := t.Resolve(name, dns.TypeSOA)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Aliases are followed by Resolve, but the zone of the name itself is wanted
+	for _, step := range steps {
+		if !util.EqualNames(step.Name, name) {
+			break
+		}
+
+		zone = step.Zone
+
+		msg := step.Response.DNS
+		for _, rr := range append(msg.Answer, msg.Ns...) {
+			// Parent and child zones can be served by the same servers
+			if soa, ok := rr.(*dns.SOA); ok && dns.IsSubDomain(soa.Hdr.Name, name) {
+				zone = soa.Hdr.Name
+			}
+		}
+	}
+
+	if zone == "" {
+		return "", nil, errNoServers
+	}
+
+	steps, err = t.Resolve(zone, dns.TypeNS)
+	if err != nil {
+		return zone, nil, err
+	}
+
+	msg := steps[len(steps)-1].Response.DNS
+	srvs = servers(msg, msg.Answer, zone)
+
+	for i := range srvs {
+		if len(t.Filter(srvs[i].Addrs)) > 0 {
+			continue
+		}
+
+		addrs, err := t.Addrs(srvs[i].Name)
+		if err != nil {
+			t.opts.Logger.Warn("Unable to resolve", srvs[i].Name, "error:", err)
+
+			continue
+		}
+
+		srvs[i].Addrs = addrs
+	}
+
+	if len(srvs) == 0 {
+		return zone, nil, fmt.Errorf("%s: %w", zone, errNoServers)
+	}
+
+	return zone, srvs, nil
+}
+
+// Addrs returns the addresses of a name server, resolving it if required.
+func (t *Tracer) Addrs(host string) ([]string, error) {
+	host = strings.ToLower(dns.Fqdn(host))
+
+	if addrs, ok := t.addrs[host]; ok {
+		return addrs, nil
+	}
+
+	if addrs, ok := rootHints[host]; ok {
+		return addrs, nil
+	}
+
+	if t.depth >= maxDepth {
+		return nil, &errTooDeep{host}
+	}
+
+	t.depth++
+	defer func() { t.depth-- }()
+
+	t.opts.Logger.Info("Resolving glueless name server", host)
+
+	var (
+		addrs []string
+		err   error
+	)
+
+	for _, qtype := range t.families() {
+		var steps []Step
+
+		steps, err = t.iterate(host, qtype, ".", t.roots())
+		if len(steps) == 0 {
+			continue
+		}
+
+		for _, rr := range steps[len(steps)-1].Response.DNS.Answer {
+			switch rr := rr.(type) {
+			case *dns.A:
+				addrs = append(addrs, rr.A.String())
+			case *dns.AAAA:
+				addrs = append(addrs, rr.AAAA.String())
+			}
+		}
+	}
+
+	if len(addrs) == 0 {
+		if err == nil {
+			err = &errNoAddress{host}
+		}
+
+		return nil, err
+	}
+
+	t.addrs[host] = addrs
+
+	return addrs, nil
+}
+
+// Query queries the servers given in order, falling back to the next server when one
+// fails or gives an unusable answer.
+func (t *Tracer) Query(srvs []Server, zone, name string, qtype uint16) (Step, error) {
+	var (
+		last    Step
+		gotResp bool
+		lastErr error = errNoServers
+	)
+
+	for try := 0; try <= t.opts.Request.Retries; try++ {
+		for _, srv := range srvs {
+			// Glue may be missing, or not be for the address family wanted
+			addrs := t.Filter(srv.Addrs)
+			if len(addrs) == 0 {
+				resolved, err := t.Addrs(srv.Name)
+				if err != nil {
+					t.opts.Logger.Warn("Unable to resolve", srv.Name, "error:", err)
+
+					lastErr = err
+
+					continue
+				}
+
+				addrs = t.Filter(resolved)
+			}
+
+			for _, addr := range addrs {
+				step, err := t.queryAddr(srv.Name, addr, zone, name, qtype)
+				if err != nil {
+					t.opts.Logger.Warn("Query to", srv.Name, "("+addr+")", "failed, trying next server. Error:", err)
+
+					lastErr = err
+
+					continue
+				}
+
+				if usable(step.Response.DNS, zone, name) {
+					return step, nil
+				}
+
+				t.opts.Logger.Info(srv.Name, "("+addr+")", "gave an unusable response, trying next server")
+
+				last, gotResp = step, true
+			}
+		}
+
+		// Every server answered, just not usefully
+		if gotResp {
+			return last, nil
+		}
+
+		if try != t.opts.Request.Retries {
+			t.opts.Logger.Warn("Retrying request, error:", lastErr)
+		}
+	}
+
+	return Step{}, fmt.Errorf("%s: %w", zone, lastErr)
+}
+
+// iterate follows referrals and aliases until an answer is found.
+func (t *Tracer) iterate(name string, qtype uint16, zone string, srvs []Server) ([]Step, error) {
+	var (
+		steps   []Step
+		aliases int
+	)
+
+	for hop := 0; hop < maxHops; hop++ {
+		step, err := t.Query(srvs, zone, name, qtype)
+		if err != nil {
+			return steps, err
+		}
+
+		steps = append(steps, step)
+		msg := step.Response.DNS
+
+		if msg.Rcode != dns.RcodeSuccess {
+			return steps, nil
+		}
+
+		if len(msg.Answer) > 0 {
+			target := chase(msg.Answer, name, qtype)
+			if target == "" {
+				return steps, nil
+			}
+
+			aliases++
+			if aliases > maxAliases {
+				return steps, errAliasLoop
+			}
+
+			t.opts.Logger.Info(name, "is an alias for", target, "restarting from the root")
+
+			name, zone, srvs = target, ".", t.roots()
+
+			continue
+		}
+
+		cut, next := Referral(msg, zone, name)
+		if cut == "" {
+			// NODATA, or a lame server that gave nothing else
+			return steps, nil
+		}
+
+		zone, srvs = cut, next
+	}
+
+	return steps, errTooManyHops
+}
+
+// QueryAddr makes a non-recursive query to an address of a name server,
+// retrying as the options say.
+func (t *Tracer) QueryAddr(addr, name string, qtype uint16) (resp util.Response, err error) {
+	o := t.direct(addr, name, qtype)
+
+	t.opts.Logger.Info("Querying", addr, "for", name, dns.TypeToString[qtype])
+
+	for i := 0; i <= t.opts.Request.Retries; i++ {
+		resp, err = t.exchange(o)
+		if err == nil {
+			return resp, nil
+		}
+	}
+
+	return resp, err
+}
+
+// queryAddr makes a single non-recursive query to a name server.
+func (t *Tracer) queryAddr(server, addr, zone, name string, qtype uint16) (Step, error) {
+	o := t.direct(addr, name, qtype)
+
+	t.opts.Logger.Info("Querying", server, "("+addr+")", "for", name, dns.TypeToString[qtype])
+
+	resp, err := t.exchange(o)
+	if err != nil {
+		return Step{}, err
+	}
+
+	return Step{
+		Zone:     zone,
+		Server:   server,
+		Address:  addr,
+		Port:     o.Request.Port,
+		Protocol: util.Protocol(o),
+		Name:     name,
+		Type:     qtype,
+		Response: resp,
+	}, nil
+}
+
+// direct returns the options to query an address directly, over plain DNS without recursion.
+func (t *Tracer) direct(addr, name string, qtype uint16) *util.Options {
+	o := *t.opts
+	o.Request.Name = name
+	o.Request.Type = qtype
+	o.Request.Port = 53
+	o.Request.Server = net.JoinHostPort(addr, strconv.Itoa(o.Request.Port))
+	o.TLS, o.HTTPS, o.QUIC, o.DNSCrypt = false, false, false, false
+	o.RD = false
+	o.Display.ShowQuery = false
+
+	return &o
+}
+
+// roots returns the root servers from the built-in hints in a random order.
+func (t *Tracer) roots() []Server {
+	roots := make([]Server, 0, len(rootHints))
+	for name, addrs := range rootHints {
+		roots = append(roots, Server{Name: name, Addrs: addrs})
+	}
+
+	return shuffle(roots)
+}
+
+// families returns the address record types to use, depending on -4 and -6.
+func (t *Tracer) families() []uint16 {
+	switch {
+	case t.opts.IPv4:
+		return []uint16{dns.TypeA}
+	case t.opts.IPv6:
+		return []uint16{dns.TypeAAAA}
+	default:
+		return []uint16{dns.TypeA, dns.TypeAAAA}
+	}
+}
+
+// Filter removes addresses not allowed by -4 and -6, with IPv4 addresses first.
+func (t *Tracer) Filter(addrs []string) []string {
+	var v4, v6 []string
+
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+
+		switch {
+		case ip == nil:
+			continue
+		case ip.To4() != nil:
+			v4 = append(v4, addr)
+		default:
+			v6 = append(v6, addr)
+		}
+	}
+
+	switch {
+	case t.opts.IPv4:
+		return v4
+	case t.opts.IPv6:
+		return v6
+	default:
+		return append(v4, v6...)
+	}
+}
+
+// Referral returns the zone cut and the servers for it if msg is a referral
+// below zone that leads towards name. The returned cut is empty when msg
+// is not a referral.
+func Referral(msg *dns.Msg, zone, name string) (cut string, srvs []Server) {
+	for _, rr := range msg.Ns {
+		ns, ok := rr.(*dns.NS)
+		if !ok {
+			continue
+		}
+
+		owner := ns.Hdr.Name
+
+		// Upwards or sideways referrals are not followed
+		if util.EqualNames(owner, zone) || !dns.IsSubDomain(zone, owner) || !dns.IsSubDomain(owner, name) {
+			continue
+		}
+
+		if cut == "" || dns.CountLabel(owner) > dns.CountLabel(cut) {
+			cut = owner
+		}
+	}
+
+	if cut == "" {
+		return "", nil
+	}
+
+	return cut, servers(msg, msg.Ns, cut)
+}
+
+// servers collects the NS records for zone out of rrs, along with any glue.
+func servers(msg *dns.Msg, rrs []dns.RR, zone string) (srvs []Server) {
+	for _, rr := range rrs {
+		ns, ok := rr.(*dns.NS)
+		if !ok || !util.EqualNames(ns.Hdr.Name, zone) {
+			continue
+		}
+
+		srv := Server{Name: ns.Ns}
+
+		for _, extra := range msg.Extra {
+			if !util.EqualNames(extra.Header().Name, ns.Ns) {
+				continue
+			}
+
+			switch extra := extra.(type) {
+			case *dns.A:
+				srv.Addrs = append(srv.Addrs, extra.A.String())
+			case *dns.AAAA:
+				srv.Addrs = append(srv.Addrs, extra.AAAA.String())
+			}
+		}
+
+		srvs = append(srvs, srv)
+	}
+
+	return shuffle(srvs)
+}
+
+// usable reports whether a response can be used to continue resolution.
+func usable(msg *dns.Msg, zone, name string) bool {
+	if msg.Rcode != dns.RcodeSuccess && msg.Rcode != dns.RcodeNameError {
+		return false
+	}
+
+	if msg.Authoritative || len(msg.Answer) > 0 {
+		return true
+	}
+
+	cut, _ := Referral(msg, zone, name)
+
+	return cut != ""
+}
+
+// chase follows CNAME and DNAME records in an answer, returning the name that
+// still needs to be resolved. An empty string is returned when the answer is
+// complete.
+func chase(answer []dns.RR, name string, qtype uint16) string {
+	if qtype == dns.TypeCNAME || qtype == dns.TypeANY {
+		return ""
+	}
+
+	orig := name
+
+	// Bound the amount of redirections to the size of the answer to prevent loops
+	for range answer {
+		next := ""
+
+		for _, rr := range answer {
+			hdr := rr.Header()
+
+			if hdr.Rrtype == qtype && util.EqualNames(hdr.Name, name) {
+				return ""
+			}
+
+			switch rr := rr.(type) {
+			case *dns.CNAME:
+				if util.EqualNames(hdr.Name, name) {
+					next = rr.Target
+				}
+			case *dns.DNAME:
+				if qtype != dns.TypeDNAME && !util.EqualNames(hdr.Name, name) && dns.IsSubDomain(hdr.Name, name) {
+					next = name[:len(name)-len(hdr.Name)] + rr.Target
+				}
+			}
+		}
+
+		if next == "" {
+			break
+		}
+
+		name = next
+	}
+
+	if util.EqualNames(name, orig) {
+		return ""
+	}
+
+	return name
+}
+
+//nolint:gosec // Secure source not needed
+func shuffle(srvs []Server) []Server {
+	rand.Shuffle(len(srvs), func(i, j int) {
+		srvs[i], srvs[j] = srvs[j], srvs[i]
+	})
+
+	return srvs
+}
+
+var (
+	errNoServers   = errors.New("no servers to query")
+	errAliasLoop   = errors.New("too many CNAME/DNAME redirections")
+	errTooManyHops = errors.New("too many referrals")
+)
+
+type errTooDeep struct {
+	host string
+}
+
+func (e *errTooDeep) Error() string {
+	return fmt.Sprintf("too much nesting resolving glueless server %s", e.host)
+}
+
+type errNoAddress struct {
+	host string
+}
+
+func (e *errNoAddress) Error() string {
+	return fmt.Sprintf("no addresses found for %s", e.host)
+}
@@ -0,0 +1,142 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package trace_test
+
+import (
+	"testing"
+
+	"dns.froth.zone/awl/pkg/dnstest"
+	"dns.froth.zone/awl/pkg/trace"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+var root = dnstest.Zone{Origin: ".", Records: []string{
+	". 518400 IN NS a.root-servers.net.",
+	"a.root-servers.net. 518400 IN A 198.41.0.4",
+	"com. 172800 IN NS a.gtld-servers.net.",
+	"com. 172800 IN NS b.gtld-servers.net.",
+	"a.gtld-servers.net. 172800 IN A 192.0.2.1",
+	// Never answers
+	"b.gtld-servers.net. 172800 IN A 192.0.2.99",
+	"net. 172800 IN NS a.gtld-servers.net.",
+	"org. 172800 IN NS a.gtld-servers.net.",
+}}
+
+var network = dnstest.Network{
+	// The resolver asked for the root servers
+	"192.0.2.53": root,
+	"198.41.0.4": root,
+	// Serves com., net. and org. alike
+	"192.0.2.1": {Origin: ".", Records: []string{
+		"example.com. 172800 IN NS ns1.example.net.",
+		"example.net. 172800 IN NS ns.example.net.",
+		"ns.example.net. 172800 IN A 192.0.2.3",
+		"example.org. 300 IN DNAME example.net.",
+	}},
+	"192.0.2.3": {Origin: "example.net.", Records: []string{
+		"example.net. 3600 IN NS ns.example.net.",
+		"ns.example.net. 3600 IN A 192.0.2.3",
+		"ns1.example.net. 3600 IN A 192.0.2.10",
+		"www.example.net. 3600 IN A 192.0.2.20",
+	}},
+	"192.0.2.10": {Origin: "example.com.", Records: []string{
+		"example.com. 3600 IN NS ns1.example.net.",
+		"www.example.com. 3600 IN CNAME www.example.net.",
+	}},
+}
+
+func newTracer(name string, qtype uint16) *trace.Tracer {
+	opts := &util.Options{
+		Logger: util.InitLogger(0),
+		IPv4:   true,
+		Request: util.Request{
+			Server:  "192.0.2.53",
+			Port:    53,
+			Name:    name,
+			Type:    qtype,
+			Retries: 0,
+		},
+	}
+
+	return trace.NewWith(opts, network.Exchange)
+}
+
+func TestTrace(t *testing.T) {
+	t.Parallel()
+
+	steps, err := newTracer("www.example.com.", dns.TypeA).Trace()
+	assert.NilError(t, err)
+
+	// resolver, root, com., example.com., then again from the root for the CNAME target
+	var zones []string
+	for _, step := range steps {
+		zones = append(zones, step.Zone)
+	}
+
+	assert.DeepEqual(t, zones, []string{".", ".", "com.", "example.com.", ".", "net.", "example.net."})
+
+	last := steps[len(steps)-1]
+	assert.Equal(t, last.Name, "www.example.net.")
+	assert.Equal(t, last.Address, "192.0.2.3")
+	assert.Equal(t, last.Response.DNS.Answer[0].(*dns.A).A.String(), "192.0.2.20")
+}
+
+func TestNXDomain(t *testing.T) {
+	t.Parallel()
+
+	steps, err := newTracer("nope.example.net.", dns.TypeA).Trace()
+	assert.NilError(t, err)
+	assert.Equal(t, steps[len(steps)-1].Response.DNS.Rcode, dns.RcodeNameError)
+}
+
+func TestGlueless(t *testing.T) {
+	t.Parallel()
+
+	addrs, err := newTracer("", 0).Addrs("ns1.example.net")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, addrs, []string{"192.0.2.10"})
+}
+
+func TestChase(t *testing.T) {
+	t.Parallel()
+
+	// Through the DNAME, then the CNAME it makes
+	steps, err := newTracer("www.example.org.", dns.TypeA).Trace()
+	assert.NilError(t, err)
+
+	last := steps[len(steps)-1]
+	assert.Equal(t, last.Name, "www.example.net.")
+	assert.Equal(t, last.Response.DNS.Answer[0].(*dns.A).A.String(), "192.0.2.20")
+
+	for name, qtype := range map[string]uint16{"www.example.org.": dns.TypeCNAME, "example.org.": dns.TypeDNAME} {
+		steps, err = newTracer(name, qtype).Trace()
+		assert.NilError(t, err)
+		assert.Equal(t, steps[len(steps)-1].Name, name)
+	}
+}
+
+func TestAuthoritative(t *testing.T) {
+	t.Parallel()
+
+	zone, srvs, err := newTracer("", 0).Authoritative("www.example.net")
+	assert.NilError(t, err)
+	assert.Equal(t, zone, "example.net.")
+	assert.DeepEqual(t, srvs, []trace.Server{{Name: "ns.example.net.", Addrs: []string{"192.0.2.3"}}})
+}
+
+func TestQueryAddr(t *testing.T) {
+	t.Parallel()
+
+	tracer := newTracer("", 0)
+
+	resp, err := tracer.QueryAddr("192.0.2.3", "www.example.net.", dns.TypeA)
+	assert.NilError(t, err)
+	assert.Assert(t, resp.DNS.Authoritative)
+
+	_, err = tracer.QueryAddr("192.0.2.99", "www.example.net.", dns.TypeA)
+	assert.ErrorIs(t, err, dnstest.ErrTimeout)
+
+	assert.DeepEqual(t, tracer.Filter([]string{"2001:db8::1", "192.0.2.1", "nope"}), []string{"192.0.2.1"})
+}
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package util
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DNSSECStatus is the outcome of validating a response, as defined in RFC 4033 section 5.
+type DNSSECStatus int
+
+const (
+	// Indeterminate means that validation could not be completed.
+	Indeterminate DNSSECStatus = iota
+	// Secure means that there is a full chain of trust to the response.
+	Secure
+	// Insecure means that there is proof that the response is not signed.
+	Insecure
+	// Bogus means that the response should have been signed, but it could not be validated.
+	Bogus
+)
+
+// String returns the name of the status.
+func (s DNSSECStatus) String() string {
+	switch s {
+	case Secure:
+		return "Secure"
+	case Insecure:
+		return "Insecure"
+	case Bogus:
+		return "Bogus"
+	default:
+		return "Indeterminate"
+	}
+}
+
+// MarshalText makes the status print as its name in JSON, XML and YAML.
+func (s DNSSECStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText parses the name of a status.
+func (s *DNSSECStatus) UnmarshalText(text []byte) error {
+	for _, status := range []DNSSECStatus{Indeterminate, Secure, Insecure, Bogus} {
+		if strings.EqualFold(string(text), status.String()) {
+			*s = status
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", errDNSSECStatus, text)
+}
+
+// DNSSEC is the result of validating a response.
+type DNSSEC struct {
+	// The validation status
+	Status DNSSECStatus `json:"status" xml:"status" yaml:"status" example:"Secure"`
+	// Why the status was given
+	Reason string `json:"reason,omitempty" xml:"reason,omitempty" yaml:"reason,omitempty" example:"example.com. A signed by example.com. with key 12345"`
+	// The chain of trust, when requested
+	Chain []*DNSSECLink `json:"chain,omitempty" xml:"chain,omitempty" yaml:"chain,omitempty"`
+}
+
+// DNSSECLink is a zone in the chain of trust, along with the zones below it.
+//
+//nolint:govet // Better looking output is worth a few bytes.
+type DNSSECLink struct {
+	// Name of the zone
+	Zone string `json:"zone" xml:"zone" yaml:"zone" example:"example.com."`
+	// Status of the zone
+	Status DNSSECStatus `json:"status" xml:"status" yaml:"status" example:"Secure"`
+	// Why the status was given
+	Reason string `json:"reason,omitempty" xml:"reason,omitempty" yaml:"reason,omitempty"`
+	// DS records in the parent, or the trust anchors of the zone
+	DS []DNSSECDS `json:"ds,omitempty" xml:"ds,omitempty" yaml:"ds,omitempty"`
+	// DNSKEY records of the zone
+	Keys []DNSSECKey `json:"keys,omitempty" xml:"key,omitempty" yaml:"keys,omitempty"`
+	// Signatures checked with the keys of the zone
+	Signatures []DNSSECSignature `json:"signatures,omitempty" xml:"signature,omitempty" yaml:"signatures,omitempty"`
+	// Zones delegated from this one
+	Children []*DNSSECLink `json:"children,omitempty" xml:"child,omitempty" yaml:"children,omitempty"`
+}
+
+// DNSSECDS is a DS record or DS trust anchor.
+type DNSSECDS struct {
+	KeyTag     uint16 `json:"keyTag" xml:"keyTag" yaml:"keyTag" example:"20326"`
+	Algorithm  string `json:"algorithm" xml:"algorithm" yaml:"algorithm" example:"RSASHA256"`
+	DigestType string `json:"digestType" xml:"digestType" yaml:"digestType" example:"SHA256"`
+	Digest     string `json:"digest" xml:"digest" yaml:"digest"`
+	// True when the DS is a trust anchor instead of coming from the parent
+	Anchor bool `json:"anchor,omitempty" xml:"anchor,omitempty" yaml:"anchor,omitempty"`
+}
+
+// DNSSECKey is a DNSKEY record.
+type DNSSECKey struct {
+	KeyTag    uint16 `json:"keyTag" xml:"keyTag" yaml:"keyTag" example:"20326"`
+	Algorithm string `json:"algorithm" xml:"algorithm" yaml:"algorithm" example:"RSASHA256"`
+	Flags     uint16 `json:"flags" xml:"flags" yaml:"flags" example:"257"`
+	// KSK or ZSK
+	Role string `json:"role" xml:"role" yaml:"role" example:"KSK"`
+	// True when the key is referenced by a DS record or trust anchor
+	Trusted bool `json:"trusted,omitempty" xml:"trusted,omitempty" yaml:"trusted,omitempty"`
+}
+
+// DNSSECSignature is an RRSIG that was checked, and what it signed.
+//
+//nolint:govet // Better looking output is worth a few bytes.
+type DNSSECSignature struct {
+	// Owner name of the signed RRset
+	Name string `json:"name" xml:"name" yaml:"name" example:"example.com."`
+	// Type of the signed RRset
+	Type      string    `json:"type" xml:"type" yaml:"type" example:"A"`
+	KeyTag    uint16    `json:"keyTag" xml:"keyTag" yaml:"keyTag" example:"12345"`
+	Algorithm string    `json:"algorithm" xml:"algorithm" yaml:"algorithm" example:"ECDSAP256SHA256"`
+	Inception time.Time `json:"inception" xml:"inception" yaml:"inception"`
+	Expiry    time.Time `json:"expiry" xml:"expiry" yaml:"expiry"`
+	// Time until the signature expires, negative if it has expired
+	Remaining time.Duration `json:"remaining" xml:"remaining" yaml:"remaining" example:"1209600000000000"`
+	// Why the signature did not validate, empty if it did
+	Error string `json:"error,omitempty" xml:"error,omitempty" yaml:"error,omitempty"`
+}
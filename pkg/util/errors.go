@@ -15,5 +15,17 @@ func (e *ErrHTTPStatus) Error() string {
 	return fmt.Sprintf("doh server responded with HTTP %d", e.Code)
 }
 
-// ErrNotError is an error that is not actually an error.
-var ErrNotError = errors.New("not an error")
+var (
+	// ErrNotError is an error that is not actually an error.
+	ErrNotError = errors.New("not an error")
+
+	errDNSSECStatus = errors.New("unknown validation status")
+)
+
+type errCookieLength struct {
+	length int
+}
+
+func (e *errCookieLength) Error() string {
+	return fmt.Sprintf("EDNS cookie parsing: invalid cookie length %d bytes", e.length)
+}
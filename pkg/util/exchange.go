@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package util
+
+import (
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Exchange is a query sent to a server, and the response to it if one came.
+type Exchange struct {
+	// The query sent, nil for the later messages of a zone transfer
+	Query *dns.Msg
+	// The response received, nil if there was none
+	Response *dns.Msg
+	// When the query was sent
+	QueryTime time.Time
+	// When the response was received
+	ResponseTime time.Time
+	// Transport used, as given by Protocol
+	Protocol string
+	// Addresses of either end, nil if not known
+	Local  net.Addr
+	Remote net.Addr
+}
+
+// Recorder is given every exchange made with a server, eg. to write it to a capture.
+type Recorder interface {
+	Record(ex Exchange) error
+}
+
+// Record gives an exchange to every recorder set in the options.
+//
+// Failing to record an exchange is only a warning, as the query itself worked.
+func Record(opts *Options, ex Exchange) {
+	for _, recorder := range opts.Recorders {
+		if err := recorder.Record(ex); err != nil {
+			opts.Logger.Warn("Unable to record exchange:", err)
+		}
+	}
+}
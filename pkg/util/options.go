@@ -3,8 +3,12 @@
 package util
 
 import (
+	"encoding/hex"
 	"fmt"
+	"io"
 	"net"
+	"strings"
+	"time"
 
 	"dns.froth.zone/awl/pkg/logawl"
 	"github.com/miekg/dns"
@@ -14,6 +18,10 @@ import (
 type Options struct {
 	// The logger
 	Logger *logawl.Logger `json:"-"`
+	// Where every exchange with a server is recorded, eg. a pcap file
+	Recorders []Recorder `json:"-"`
+	// Where TLS session keys are written, in the NSS key log format
+	KeyLogWriter io.Writer `json:"-"`
 	// Host to verify TLS cert with
 	TLSHost string `json:"tlsHost" example:""`
 	// EDNS Options
@@ -42,12 +50,10 @@ type Options struct {
 
 	HeaderFlags
 
-	// Display resposne as JSON
-	JSON bool `json:"-" xml:"-" yaml:"-"`
-	// Display response as XML
-	XML bool `json:"-" xml:"-" yaml:"-"`
-	// Display response as YAML
-	YAML bool `json:"-" xml:"-" yaml:"-"`
+	// Output format instead of dig-like text, eg. "json" or "table"
+	Format string `json:"format" example:""`
+	// Go template to print results with, when Format is "template"
+	Template string `json:"template" example:""`
 
 	// Use TCP instead of UDP to make the query
 	TCP bool `json:"tcp" example:"false"`
@@ -70,6 +76,50 @@ type Options struct {
 
 	// Trace from the root
 	Trace bool `json:"trace" example:"false"`
+	// Validate the response with DNSSEC
+	Validate bool `json:"validate" example:"false"`
+	// Show the DNSSEC chain of trust of the response
+	Sigchase bool `json:"sigchase" example:"false"`
+	// Check when the signatures of the zone expire on every authoritative server
+	CheckExpiry bool `json:"checkExpiry" example:"false"`
+	// Warn when a signature expires within this duration
+	ExpiryWarning time.Duration `json:"expiryWarning" example:"604800000000000"`
+	// Go critical when a signature expires within this duration
+	ExpiryCritical time.Duration `json:"expiryCritical" example:"259200000000000"`
+	// Describe DNSKEY records and compute their DS records
+	KeyInfo bool `json:"keyInfo" example:"false"`
+	// Zone file to read DNSKEY records from instead of querying, - for stdin
+	KeyFile string `json:"keyFile" example:""`
+	// Walk the NSEC or NSEC3 chain of the zone
+	Walk bool `json:"walk" example:"false"`
+	// Dictionary of labels to try against NSEC3 hashes
+	WalkDict string `json:"walkDict" example:""`
+	// File to write every query and response to, in pcap format
+	Pcap string `json:"pcap" example:""`
+	// File or unix socket to write every query and response to, as dnstap
+	Dnstap string `json:"dnstap" example:""`
+	// File to append TLS session keys to, defaults to $SSLKEYLOGFILE
+	KeyLog string `json:"keyLog" example:""`
+	// RFC 8427 JSON file to take the question and flags of the query from, - for stdin
+	FromJSON string `json:"fromJSON" example:""`
+	// DNS message to decode and print instead of querying: hex, base64, a DoH URL or a file, - for stdin
+	Decode string `json:"decode" example:""`
+	// pcap or dnstap file to replay the queries of, comparing the responses with the recorded ones
+	Replay string `json:"replay" example:""`
+	// Queries a second to replay at, 0 for as fast as they are answered
+	ReplayRate float64 `json:"replayRate" example:"0"`
+	// Replay queries at the times they were recorded at
+	ReplayTiming bool `json:"replayTiming" example:"false"`
+	// Every server given with @, the last one is queried unless comparing
+	Servers []Server `json:"servers,omitempty"`
+	// Send the query to every server given, comparing the responses
+	Compare bool `json:"compare" example:"false"`
+	// Query every authoritative server of the zone, checking that they agree
+	NSSearch bool `json:"nssearch" example:"false"`
+	// Check that the delegation of the zone from its parent matches the zone
+	Delegation bool `json:"delegation" example:"false"`
+	// File to read DNSSEC trust anchors from, instead of the built-in root anchors
+	TrustAnchor string `json:"trustAnchor" example:""`
 }
 
 // HTTPSOptions are options exclusively for DNS-over-HTTPS queries.
@@ -133,6 +183,16 @@ type Display struct {
 	HumanTTL bool `json:"humanTTL" example:"false"`
 	// Translate Punycode back to Unicode
 	UcodeTranslate bool `json:"unicode" example:"true"`
+	// Annotate records with comments, eg. key tags for DNSKEY records
+	RRComments bool `json:"rrComments" example:"false"`
+	// Print long records over multiple lines with comments
+	Multiline bool `json:"multiline" example:"false"`
+	// Colour the output with ANSI escape codes
+	Color bool `json:"color" example:"false"`
+	// Dump the packed query and response in hex
+	Wire bool `json:"wire" example:"false"`
+	// Annotate every field of the hex dump, implies Wire
+	Annotate bool `json:"annotate" example:"false"`
 }
 
 // EDNS contains toggles for various EDNS options.
@@ -147,6 +207,8 @@ type EDNS struct {
 	EnableEDNS bool `json:"edns" example:"false"`
 	// Sending EDNS cookie
 	Cookie bool `json:"cookie" example:"true"`
+	// Explicit EDNS cookie to send, hex encoded (default: random client cookie)
+	CookieValue string `json:"cookieValue" example:"24a5ac5a4c2de4e4"`
 	// Enabling DNSSEC
 	DNSSEC bool `json:"dnssec" example:"false"`
 	// Sending EDNS Expire
@@ -198,3 +260,22 @@ func ParseSubnet(subnet string, opts *Options) error {
 
 	return nil
 }
+
+// ParseCookie takes a hex encoded client cookie, optionally followed by a server
+// cookie, and validates it against RFC 7873.
+func ParseCookie(cookie string, opts *Options) error {
+	raw, err := hex.DecodeString(cookie)
+	if err != nil {
+		return fmt.Errorf("EDNS cookie parsing: %w", err)
+	}
+
+	// Client cookies are always 8 bytes, server cookies are 8 to 32 bytes
+	if len(raw) != 8 && (len(raw) < 16 || len(raw) > 40) {
+		return &errCookieLength{len(raw)}
+	}
+
+	opts.EDNS.Cookie = true
+	opts.EDNS.CookieValue = strings.ToLower(cookie)
+
+	return nil
+}
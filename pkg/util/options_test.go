@@ -34,3 +34,36 @@ func TestSubnet(t *testing.T) {
 		})
 	}
 }
+
+func TestCookie(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		cookie string
+		err    string
+	}{
+		{"24a5ac5a4c2de4e4", ""},
+		{"24A5AC5A4C2DE4E40100000063f1d6a2e34e4bb0a1b2c3d4", ""},
+		{"24a5ac5a", "invalid cookie length"},
+		{"24a5ac5a4c2de4e401", "invalid cookie length"},
+		{"not hex", "invalid byte"},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.cookie, func(t *testing.T) {
+			t.Parallel()
+
+			opts := new(util.Options)
+			err := util.ParseCookie(test.cookie, opts)
+			if test.err != "" {
+				assert.ErrorContains(t, err, test.err)
+			} else {
+				assert.NilError(t, err)
+				assert.Assert(t, opts.EDNS.Cookie)
+				assert.Equal(t, len(opts.EDNS.CookieValue), len(test.cookie))
+			}
+		})
+	}
+}
@@ -12,8 +12,44 @@ import (
 type Response struct {
 	// The full DNS response
 	DNS *dns.Msg `json:"response"`
+	// The query that was sent
+	Query *dns.Msg `json:"query,omitempty"`
+	// The query and response as they were sent and received, when the transport gives them
+	Wire *Wire `json:"-"`
 	// The time it took to make the DNS query
 	RTT time.Duration `json:"rtt" example:"2000000000"`
+	// The DNSSEC validation result, if validation was requested
+	DNSSEC *DNSSEC `json:"dnssec,omitempty"`
+	// Number of times the query was retried after failing
+	Retries int `json:"retries,omitempty" example:"0"`
+	// The query was retried over TCP after a truncated response
+	TCPFallback bool `json:"tcpFallback,omitempty" example:"false"`
+	// The query was retried with the server cookie after a BADCOOKIE response
+	BadCookie bool `json:"badCookie,omitempty" example:"false"`
+}
+
+// Wire is a query and its response in wire format.
+type Wire struct {
+	Query    []byte
+	Response []byte
+}
+
+// Protocol returns the name of the transport used by the options given.
+func Protocol(opts *Options) string {
+	switch {
+	case opts.TLS:
+		return "TLS"
+	case opts.HTTPS:
+		return "HTTPS"
+	case opts.QUIC:
+		return "QUIC"
+	case opts.DNSCrypt:
+		return "DNSCrypt"
+	case opts.TCP:
+		return "TCP"
+	default:
+		return "UDP"
+	}
 }
 
 // Request is a structure for a DNS query.
@@ -32,4 +68,41 @@ type Request struct {
 	Type uint16 `json:"type" example:"1"`
 	// Request class, eg. IN
 	Class uint16 `json:"class" example:"1"`
+	// Message ID to use when FixedID is set
+	ID uint16 `json:"id" example:"0"`
+	// Use ID instead of a random message ID
+	FixedID bool `json:"fixedID" example:"false"`
+}
+
+// Server is a server given with @, with the transport to query it with.
+type Server struct {
+	// As it was given, eg. tls://dns.google
+	Name string `json:"name" example:"1.1.1.1"`
+	// Address, or URL for HTTPS
+	Address string `json:"address" example:"1.1.1.1"`
+	Port    int    `json:"port" example:"53"`
+	// HTTPS endpoint
+	Endpoint string `json:"endpoint,omitempty" example:"/dns-query"`
+	TCP      bool   `json:"tcp,omitempty" example:"false"`
+	TLS      bool   `json:"dnsOverTLS,omitempty" example:"false"`
+	HTTPS    bool   `json:"dnsOverHTTPS,omitempty" example:"false"`
+	//nolint:tagliatelle // QUIC is an acronym
+	QUIC     bool `json:"dnsOverQUIC,omitempty" example:"false"`
+	DNSCrypt bool `json:"dnscrypt,omitempty" example:"false"`
+}
+
+// ForServer copies the options, to query one of the servers given instead.
+func ForServer(srv Server, opts *Options) *Options {
+	ret := *opts
+
+	ret.Request.Server = srv.Address
+	ret.Request.Port = srv.Port
+	ret.HTTPSOptions.Endpoint = srv.Endpoint
+	ret.TCP = srv.TCP
+	ret.TLS = srv.TLS
+	ret.HTTPS = srv.HTTPS
+	ret.QUIC = srv.QUIC
+	ret.DNSCrypt = srv.DNSCrypt
+
+	return &ret
 }
@@ -0,0 +1,26 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package util
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// EqualNames checks if two domain names are the same, ignoring case and the final dot.
+func EqualNames(a, b string) bool {
+	return dns.CanonicalName(a) == dns.CanonicalName(b)
+}
+
+// WithoutTTL prints a record without its TTL, to compare records given at different times.
+func WithoutTTL(rr dns.RR) string {
+	hdr := rr.Header()
+
+	return strings.Join([]string{
+		hdr.Name,
+		dns.Class(hdr.Class).String(),
+		dns.Type(hdr.Rrtype).String(),
+		strings.TrimPrefix(rr.String(), hdr.String()),
+	}, "\t")
+}
@@ -0,0 +1,7 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+/*
+Package walk enumerates the names in a DNSSEC signed zone by following its
+NSEC chain, or collects the hashes of an NSEC3 chain to be cracked offline.
+*/
+package walk
@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package walk
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ToString prints a walk as a zone-like listing, one name or hash per line.
+func ToString(res *Result) string {
+	var s strings.Builder
+
+	switch {
+	case res.Params != nil:
+		salt := res.Params.Salt
+		if salt == "" {
+			salt = "-"
+		}
+
+		fmt.Fprintf(&s, "; %s NSEC3 chain, hash algorithm %d, %d iterations, salt %s\n",
+			res.Zone, res.Params.Algorithm, res.Params.Iterations, salt)
+
+		cracked := 0
+
+		for _, h := range res.Hashes {
+			flags := 0
+			if h.OptOut {
+				flags = 1
+			}
+
+			fmt.Fprintf(&s, "%s.%s\tNSEC3\t%d %d %d %s %s %s",
+				h.Hash, res.Zone, res.Params.Algorithm, flags, res.Params.Iterations, salt, h.Next, strings.Join(h.Types, " "))
+
+			if h.Name != "" {
+				cracked++
+
+				s.WriteString(" ; " + h.Name)
+			}
+
+			s.WriteString("\n")
+		}
+
+		fmt.Fprintf(&s, "; %d hashes, %d names found, %d queries", len(res.Hashes), cracked, res.Queries)
+	default:
+		for _, name := range res.Names {
+			fmt.Fprintf(&s, "%s\t%s\n", name.Name, strings.Join(name.Types, " "))
+		}
+
+		fmt.Fprintf(&s, "; %d names, %d queries", len(res.Names), res.Queries)
+	}
+
+	if !res.Complete {
+		s.WriteString(", incomplete")
+	}
+
+	return s.String()
+}
@@ -0,0 +1,436 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package walk
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"dns.froth.zone/awl/pkg/query"
+	"dns.froth.zone/awl/pkg/util"
+	"github.com/miekg/dns"
+)
+
+const (
+	// Maximum amount of queries made for a single walk
+	maxQueries = 65536
+	// Maximum amount of names hashed while looking for gaps in an NSEC3 chain
+	maxHashes = 16 * maxQueries
+	// Fraction of the timeout waited between queries
+	pauseDivisor = 10
+)
+
+// Result is everything found by walking a zone.
+//
+//nolint:govet // Better looking output is worth a few bytes.
+type Result struct {
+	XMLName xml.Name `json:"-" xml:"walk" yaml:"-"`
+	// Zone that was walked
+	Zone string `json:"zone" xml:"zone" yaml:"zone" example:"example.com."`
+	// NSEC or NSEC3
+	Denial string `json:"denial" xml:"denial" yaml:"denial" example:"NSEC"`
+	// Whether the whole chain was followed
+	Complete bool `json:"complete" xml:"complete" yaml:"complete" example:"true"`
+	// Amount of queries made
+	Queries int `json:"queries" xml:"queries" yaml:"queries" example:"12"`
+	// Names found by following an NSEC chain
+	Names []Name `json:"names,omitempty" xml:"name,omitempty" yaml:"names,omitempty"`
+	// Parameters of an NSEC3 chain
+	Params *Params `json:"params,omitempty" xml:"params,omitempty" yaml:"params,omitempty"`
+	// Hashes found in an NSEC3 chain
+	Hashes []Hash `json:"hashes,omitempty" xml:"hash,omitempty" yaml:"hashes,omitempty"`
+}
+
+// Name is a name found in an NSEC chain.
+type Name struct {
+	Name string `json:"name" xml:"name" yaml:"name" example:"www.example.com."`
+	// Types that exist at the name
+	Types []string `json:"types" xml:"type" yaml:"types" example:"A"`
+}
+
+// Params are the hashing parameters of an NSEC3 chain.
+type Params struct {
+	Algorithm  uint8  `json:"algorithm" xml:"algorithm" yaml:"algorithm" example:"1"`
+	Iterations uint16 `json:"iterations" xml:"iterations" yaml:"iterations" example:"0"`
+	// Hex encoded salt, empty when there is none
+	Salt string `json:"salt" xml:"salt" yaml:"salt" example:""`
+}
+
+// Hash is a hashed owner name found in an NSEC3 chain.
+type Hash struct {
+	Hash string `json:"hash" xml:"hash" yaml:"hash" example:"2VPTU5TIMAMQTTGL4LUU9KG21E0AOR3S"`
+	// Hash of the next name in the chain
+	Next string `json:"next" xml:"next" yaml:"next" example:"3DKL4VS6BFIU0T6H1KMU8IJCSEKHQJGC"`
+	// Types that exist at the name
+	Types []string `json:"types" xml:"type" yaml:"types" example:"A"`
+	// Whether the opt-out flag is set
+	OptOut bool `json:"optOut,omitempty" xml:"optOut,omitempty" yaml:"optOut,omitempty"`
+	// The name, if it was found in the dictionary
+	Name string `json:"name,omitempty" xml:"name,omitempty" yaml:"name,omitempty" example:"www.example.com."`
+}
+
+// Walker walks zones.
+type Walker struct {
+	opts *util.Options
+	// Makes a single query
+	exchange func(*util.Options) (util.Response, error)
+	queries  int
+}
+
+// New creates a new Walker from the options given.
+func New(opts *util.Options) *Walker {
+	return NewWith(opts, query.CreateQuery)
+}
+
+// NewWith creates a new Walker making every query with exchange instead of
+// [query.CreateQuery], eg. to answer them from a fake network in tests.
+func NewWith(opts *util.Options, exchange func(*util.Options) (util.Response, error)) *Walker {
+	return &Walker{
+		opts:     opts,
+		exchange: exchange,
+	}
+}
+
+// Walk walks the zone in opts.
+func Walk(opts *util.Options) (*Result, error) {
+	return New(opts).Walk()
+}
+
+// Walk walks the requested zone, using the server and transport requested.
+//
+// A partial result is returned along with an error if the walk cannot be finished.
+func (w *Walker) Walk() (*Result, error) {
+	zone := dns.CanonicalName(w.opts.Request.Name)
+	res := &Result{Zone: zone}
+
+	defer func() { res.Queries = w.queries }()
+
+	msg, err := w.query(zone, dns.TypeNSEC)
+	if err != nil {
+		return res, err
+	}
+
+	nsecs, nsec3s := split(append(msg.Answer, msg.Ns...), zone)
+
+	switch {
+	case len(nsecs) > 0:
+		res.Denial = "NSEC"
+
+		return res, w.nsec(res)
+	case len(nsec3s) > 0:
+		res.Denial = "NSEC3"
+		res.Params = &Params{nsec3s[0].Hash, nsec3s[0].Iterations, nsec3s[0].Salt}
+
+		return res, w.nsec3(res, nsec3s)
+	default:
+		return res, fmt.Errorf("%s: %w", zone, errUnsigned)
+	}
+}
+
+// nsec follows an NSEC chain from the zone apex.
+func (w *Walker) nsec(res *Result) error {
+	seen := make(map[string]bool)
+	name := res.Zone
+
+	for !seen[name] {
+		// Servers signing online can make up names forever
+		if w.queries >= maxQueries {
+			return errTooMany
+		}
+
+		seen[name] = true
+
+		msg, err := w.query(name, dns.TypeNSEC)
+		if err != nil {
+			return err
+		}
+
+		nsecs, _ := split(append(msg.Answer, msg.Ns...), res.Zone)
+
+		var found *dns.NSEC
+
+		for _, nsec := range nsecs {
+			if util.EqualNames(nsec.Hdr.Name, name) {
+				found = nsec
+			}
+		}
+
+		if found == nil {
+			return fmt.Errorf("%s: %w", name, errNoNSEC)
+		}
+
+		next := dns.CanonicalName(found.NextDomain)
+
+		// Minimally covering NSEC records, see RFC 4470
+		if strings.HasPrefix(next, `\000.`) {
+			return fmt.Errorf("%s: %w", name, errMinimal)
+		}
+
+		res.Names = append(res.Names, Name{name, types(found.TypeBitMap)})
+		w.opts.Logger.Info("Found", name, "next is", next)
+
+		if !dns.IsSubDomain(res.Zone, next) {
+			return fmt.Errorf("%s: %w: %s", name, errOutside, next)
+		}
+
+		name = next
+	}
+
+	res.Complete = name == res.Zone
+
+	return nil
+}
+
+// nsec3 collects the hashes of an NSEC3 chain, by querying for names that hash
+// into gaps of the chain until it is closed.
+func (w *Walker) nsec3(res *Result, first []*dns.NSEC3) error {
+	chain := make(map[string]*dns.NSEC3)
+	add := func(nsec3s []*dns.NSEC3) {
+		for _, nsec3 := range nsec3s {
+			chain[owner(nsec3)] = nsec3
+		}
+	}
+
+	add(first)
+
+	for i := 0; !closed(chain); i++ {
+		if i >= maxHashes || w.queries >= maxQueries {
+			res.Hashes = hashes(chain)
+
+			return errTooMany
+		}
+
+		name := strconv.FormatInt(int64(i), 36) + "." + res.Zone
+		hash := dns.HashName(name, res.Params.Algorithm, res.Params.Iterations, res.Params.Salt)
+
+		// Only query for names in gaps that are not known yet
+		if covered(chain, hash) {
+			continue
+		}
+
+		msg, err := w.query(name, dns.TypeA)
+		if err != nil {
+			res.Hashes = hashes(chain)
+
+			return err
+		}
+
+		_, nsec3s := split(msg.Ns, res.Zone)
+		if len(nsec3s) == 0 {
+			res.Hashes = hashes(chain)
+
+			return fmt.Errorf("%s: %w", name, errNoNSEC)
+		}
+
+		add(nsec3s)
+	}
+
+	res.Complete = true
+	res.Hashes = hashes(chain)
+
+	return nil
+}
+
+// query makes a single query with the DO bit set.
+//
+// To not flood the server, a tenth of the timeout is waited before every query
+// but the first, doubling with every retry.
+func (w *Walker) query(name string, qtype uint16) (*dns.Msg, error) {
+	o := *w.opts
+	o.Request.Name = name
+	o.Request.Type = qtype
+	o.EDNS.EnableEDNS = true
+	o.EDNS.DNSSEC = true
+	o.Display.ShowQuery = false
+
+	w.opts.Logger.Info("Querying", name, dns.TypeToString[qtype])
+
+	var (
+		resp util.Response
+		err  error
+	)
+
+	pause := w.opts.Request.Timeout / pauseDivisor
+
+	for i := 0; i <= w.opts.Request.Retries; i++ {
+		if w.queries > 0 {
+			time.Sleep(pause << i)
+		}
+
+		w.queries++
+
+		resp, err = w.exchange(&o)
+		if err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", name, dns.TypeToString[qtype], err)
+	}
+
+	if resp.DNS.Rcode != dns.RcodeSuccess && resp.DNS.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("%s %s: %w: %s", name, dns.TypeToString[qtype], errRcode, dns.RcodeToString[resp.DNS.Rcode])
+	}
+
+	return resp.DNS, nil
+}
+
+// Crack hashes every word in a dictionary, naming the hashes that match.
+// Each word is a label to try in the zone, and the zone apex is always tried.
+func Crack(res *Result, dict io.Reader) (found int, err error) {
+	if res.Params == nil {
+		return 0, nil
+	}
+
+	byHash := make(map[string]*Hash, len(res.Hashes))
+	for i := range res.Hashes {
+		byHash[res.Hashes[i].Hash] = &res.Hashes[i]
+	}
+
+	try := func(name string) {
+		hash := dns.HashName(name, res.Params.Algorithm, res.Params.Iterations, res.Params.Salt)
+		if h, ok := byHash[hash]; ok && h.Name == "" {
+			h.Name = name
+			found++
+		}
+	}
+
+	try(res.Zone)
+
+	scanner := bufio.NewScanner(dict)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+
+		try(dns.CanonicalName(word + "." + res.Zone))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return found, fmt.Errorf("dictionary: %w", err)
+	}
+
+	return found, nil
+}
+
+// CrackFile cracks the hashes in a result with a dictionary file.
+func CrackFile(res *Result, file string) (int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, fmt.Errorf("dictionary: %w", err)
+	}
+	defer f.Close()
+
+	return Crack(res, f)
+}
+
+// split returns the NSEC and NSEC3 records in the zone out of rrs.
+func split(rrs []dns.RR, zone string) (nsecs []*dns.NSEC, nsec3s []*dns.NSEC3) {
+	for _, rr := range rrs {
+		if !dns.IsSubDomain(zone, rr.Header().Name) {
+			continue
+		}
+
+		switch rr := rr.(type) {
+		case *dns.NSEC:
+			nsecs = append(nsecs, rr)
+		case *dns.NSEC3:
+			// Only the hashed label and the zone
+			if dns.CountLabel(rr.Hdr.Name) == dns.CountLabel(zone)+1 {
+				nsec3s = append(nsec3s, rr)
+			}
+		}
+	}
+
+	return nsecs, nsec3s
+}
+
+// owner returns the hash an NSEC3 record is for.
+func owner(nsec3 *dns.NSEC3) string {
+	return strings.ToUpper(dns.SplitDomainName(nsec3.Hdr.Name)[0])
+}
+
+// covered checks if a hash is the owner of, or falls between the owner and next
+// hash of any record in the chain.
+func covered(chain map[string]*dns.NSEC3, hash string) bool {
+	if _, ok := chain[hash]; ok {
+		return true
+	}
+
+	for own, nsec3 := range chain {
+		next := strings.ToUpper(nsec3.NextDomain)
+
+		if own < next {
+			if own < hash && hash < next {
+				return true
+			}
+		} else if hash > own || hash < next {
+			// The last record wraps around to the first
+			return true
+		}
+	}
+
+	return false
+}
+
+// closed checks if every record in the chain leads to another, ending where it started.
+func closed(chain map[string]*dns.NSEC3) bool {
+	for _, nsec3 := range chain {
+		if _, ok := chain[strings.ToUpper(nsec3.NextDomain)]; !ok {
+			return false
+		}
+	}
+
+	return len(chain) > 0
+}
+
+// hashes returns the chain in hash order.
+func hashes(chain map[string]*dns.NSEC3) []Hash {
+	ret := make([]Hash, 0, len(chain))
+
+	for own, nsec3 := range chain {
+		ret = append(ret, Hash{
+			Hash:   own,
+			Next:   strings.ToUpper(nsec3.NextDomain),
+			Types:  types(nsec3.TypeBitMap),
+			OptOut: nsec3.Flags&1 == 1,
+		})
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Hash < ret[j].Hash })
+
+	return ret
+}
+
+func types(bitmap []uint16) []string {
+	ret := make([]string, 0, len(bitmap))
+
+	for _, t := range bitmap {
+		if name, ok := dns.TypeToString[t]; ok {
+			ret = append(ret, name)
+		} else {
+			ret = append(ret, "TYPE"+strconv.Itoa(int(t)))
+		}
+	}
+
+	return ret
+}
+
+var (
+	errUnsigned = errors.New("no NSEC or NSEC3 records given, the zone may not be signed")
+	errNoNSEC   = errors.New("no NSEC or NSEC3 record given")
+	errMinimal  = errors.New("the server gives minimally covering NSEC records, so the zone cannot be walked")
+	errOutside  = errors.New("the NSEC chain leaves the zone")
+	errTooMany  = errors.New("too many queries, stopping")
+	errRcode    = errors.New("bad response code")
+)
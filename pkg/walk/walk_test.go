@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: BSD-3-Clause
+
+package walk_test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"dns.froth.zone/awl/pkg/dnstest"
+	"dns.froth.zone/awl/pkg/util"
+	"dns.froth.zone/awl/pkg/walk"
+	"github.com/miekg/dns"
+	"gotest.tools/v3/assert"
+)
+
+// Waited between queries, a tenth of the timeout
+const pause = time.Millisecond
+
+func nsecZone(names ...string) dnstest.Zone {
+	z := dnstest.Zone{Origin: names[0]}
+
+	for i, name := range names {
+		next := names[(i+1)%len(names)]
+
+		z.Records = append(z.Records, fmt.Sprintf("%s 300 IN NSEC %s A RRSIG NSEC", name, next))
+	}
+
+	return z
+}
+
+func nsec3Zone(zone string, names ...string) dnstest.Zone {
+	z := dnstest.Zone{Origin: zone}
+
+	hashes := make([]string, 0, len(names))
+	for _, name := range names {
+		hashes = append(hashes, dns.HashName(name, dns.SHA1, 1, "AABB"))
+	}
+
+	sort.Strings(hashes)
+
+	for i, hash := range hashes {
+		next := hashes[(i+1)%len(hashes)]
+
+		z.Records = append(z.Records, fmt.Sprintf("%s.%s 300 IN NSEC3 1 0 1 AABB %s A RRSIG", hash, zone, next))
+	}
+
+	return z
+}
+
+func newWalker(zone string, z dnstest.Zone) *walk.Walker {
+	opts := &util.Options{
+		Logger:  util.InitLogger(0),
+		Request: util.Request{Server: "192.0.2.53", Name: zone, Timeout: 10 * pause},
+	}
+
+	return walk.NewWith(opts, dnstest.Network{"192.0.2.53": z}.Exchange)
+}
+
+func TestNSEC(t *testing.T) {
+	t.Parallel()
+
+	z := nsecZone("example.", "a.example.", "mail.example.", "www.example.")
+
+	start := time.Now()
+
+	res, err := newWalker("example", z).Walk()
+	assert.NilError(t, err)
+	assert.Assert(t, res.Complete)
+	assert.Equal(t, res.Denial, "NSEC")
+
+	// Nothing waits before the first query
+	assert.Assert(t, time.Since(start) >= time.Duration(res.Queries-1)*pause)
+
+	names := make([]string, 0, len(res.Names))
+	for _, name := range res.Names {
+		names = append(names, name.Name)
+	}
+
+	assert.DeepEqual(t, names, []string{"example.", "a.example.", "mail.example.", "www.example."})
+	assert.DeepEqual(t, res.Names[0].Types, []string{"A", "RRSIG", "NSEC"})
+
+	str := walk.ToString(res)
+	assert.Assert(t, strings.HasPrefix(str, "example.\tA RRSIG NSEC\na.example.\tA RRSIG NSEC\n"))
+	assert.Assert(t, strings.HasSuffix(str, "; 4 names, 5 queries"))
+}
+
+func TestMinimal(t *testing.T) {
+	t.Parallel()
+
+	z := nsecZone("example.", `\000.example.`)
+
+	res, err := newWalker("example.", z).Walk()
+	assert.ErrorContains(t, err, "minimally covering")
+	assert.Assert(t, !res.Complete)
+}
+
+func TestEndless(t *testing.T) {
+	t.Parallel()
+
+	// Made up records, each leading to a new name
+	made := 0
+	exchange := func(opts *util.Options) (util.Response, error) {
+		made++
+
+		msg := new(dns.Msg)
+		msg.SetQuestion(opts.Request.Name, opts.Request.Type)
+
+		rr, err := dns.NewRR(fmt.Sprintf("%s 300 IN NSEC n%d.example. A RRSIG NSEC", opts.Request.Name, made))
+		assert.NilError(t, err)
+
+		msg.Answer = []dns.RR{rr}
+
+		return util.Response{DNS: msg}, nil
+	}
+
+	// No timeout, so no waiting
+	opts := &util.Options{
+		Logger:  util.InitLogger(0),
+		Request: util.Request{Name: "example."},
+	}
+
+	res, err := walk.NewWith(opts, exchange).Walk()
+	assert.ErrorContains(t, err, "too many queries")
+	assert.Assert(t, !res.Complete)
+	assert.Equal(t, res.Queries, 65536)
+}
+
+func TestNSEC3(t *testing.T) {
+	t.Parallel()
+
+	z := nsec3Zone("example.", "example.", "a.example.", "www.example.", "mail.example.")
+
+	res, err := newWalker("example.", z).Walk()
+	assert.NilError(t, err)
+	assert.Assert(t, res.Complete)
+	assert.Equal(t, res.Denial, "NSEC3")
+	assert.Equal(t, len(res.Hashes), 4)
+	assert.DeepEqual(t, *res.Params, walk.Params{Algorithm: 1, Iterations: 1, Salt: "AABB"})
+
+	found, err := walk.Crack(res, strings.NewReader("www\n# comment\na\nnope\n"))
+	assert.NilError(t, err)
+	// The apex, www and a
+	assert.Equal(t, found, 3)
+
+	str := walk.ToString(res)
+	assert.Assert(t, strings.Contains(str, " ; www.example.\n"))
+	assert.Assert(t, strings.Contains(str, "; 4 hashes, 3 names found, "))
+}